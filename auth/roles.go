@@ -0,0 +1,32 @@
+package auth
+
+// Roles of the API keys, from the least to the most privileged. Requests without an API key are anonymous
+const (
+	RoleAnonymous = ""
+	RoleUser      = "user"
+	RoleTrusted   = "trusted"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists the roles an API key may have, from the least to the most privileged
+var Roles = []string{RoleUser, RoleTrusted, RoleModerator, RoleAdmin}
+
+// ValidRole tells whether an API key may have a role
+func ValidRole(role string) bool {
+	return level(role) > 0
+}
+
+// AtLeast tells whether a role has every privilege of the minimum role
+func AtLeast(role string, minimum string) bool {
+	return level(role) >= level(minimum)
+}
+
+func level(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtLeast(t *testing.T) {
+	t.Log("Roles should include the privileges of the roles below them")
+
+	assert.True(t, AtLeast(RoleAdmin, RoleModerator))
+	assert.True(t, AtLeast(RoleModerator, RoleModerator))
+	assert.False(t, AtLeast(RoleTrusted, RoleModerator))
+	assert.False(t, AtLeast(RoleAnonymous, RoleUser))
+	assert.True(t, AtLeast(RoleAnonymous, RoleAnonymous))
+}
+
+func TestValidRole(t *testing.T) {
+	t.Log("Only the known roles should be valid for an API key")
+
+	assert.True(t, ValidRole(RoleUser))
+	assert.False(t, ValidRole(RoleAnonymous))
+	assert.False(t, ValidRole("root"))
+}
+
+func TestRolesMatchConfig(t *testing.T) {
+	t.Log("The config should take the roles an API key may have")
+
+	assert.Equal(t, Roles, config.APIKeyRoles)
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is an LRU cache whose entries also expire after a TTL. It's safe for concurrent use
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	entries    *list.List // Most recently used first
+	index      map[string]*list.Element
+	stats      Stats
+	now        func() time.Time
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// Stats are the counters of a cache since it was created
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+	MaxEntries    int    `json:"max_entries"`
+}
+
+// New creates a cache holding at most maxEntries entries, each for at most ttl
+func New(maxEntries int, ttl time.Duration) *Cache {
+	return &Cache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    list.New(),
+		index:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get returns the value of a key, if it's cached and not expired
+func (c *Cache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.index[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if c.now().After(e.expires) {
+		c.remove(element)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	c.stats.Hits++
+	return e.value, true
+}
+
+// Set caches the value of a key, evicting the least recently used entry when the cache is full
+func (c *Cache) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if element, ok := c.index[key]; ok {
+		e := element.Value.(*entry)
+		e.value = value
+		e.expires = expires
+		c.entries.MoveToFront(element)
+		return
+	}
+
+	c.index[key] = c.entries.PushFront(&entry{key: key, value: value, expires: expires})
+	for c.entries.Len() > c.maxEntries {
+		c.remove(c.entries.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Invalidate removes the keys
+func (c *Cache) Invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := c.index[key]; ok {
+			c.remove(element)
+			c.stats.Invalidations++
+		}
+	}
+}
+
+// InvalidatePrefix removes every key starting with the prefix
+func (c *Cache) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.index {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+			c.stats.Invalidations++
+		}
+	}
+}
+
+// Flush removes every entry
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Invalidations += uint64(c.entries.Len())
+	c.entries.Init()
+	c.index = make(map[string]*list.Element)
+}
+
+// Stats returns the counters of the cache
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.entries.Len()
+	stats.MaxEntries = c.maxEntries
+	return stats
+}
+
+func (c *Cache) remove(element *list.Element) {
+	c.entries.Remove(element)
+	delete(c.index, element.Value.(*entry).key)
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Log("A full cache should evict the entry used least recently")
+
+	c := New(2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, uint64(1), c.Stats().Evictions)
+}
+
+func TestCacheExpiresEntries(t *testing.T) {
+	t.Log("Entries should not be returned after their TTL")
+
+	now := time.Now()
+	c := New(10, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Set("a", 1)
+
+	c.now = func() time.Time { return now.Add(2 * time.Minute) }
+	_, ok := c.Get("a")
+
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Stats().Entries)
+}
+
+func TestCacheInvalidatePrefix(t *testing.T) {
+	t.Log("Invalidating a prefix should only remove the keys starting with it")
+
+	c := New(10, time.Minute)
+	c.Set("character:1:self", 1)
+	c.Set("character:1:phrases", 2)
+	c.Set("character:10:self", 3)
+
+	c.InvalidatePrefix("character:1:")
+
+	_, ok := c.Get("character:1:phrases")
+	assert.False(t, ok)
+	_, ok = c.Get("character:10:self")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), c.Stats().Invalidations)
+}
+
+func TestCacheStats(t *testing.T) {
+	t.Log("Hits and misses should be counted")
+
+	c := New(10, time.Minute)
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("b")
+	c.Flush()
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 0, stats.Entries)
+	assert.Equal(t, 10, stats.MaxEntries)
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"net/http"
+
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+)
+
+var repositoryCache *Cache
+
+// Initialize sets the cache managed by the admin endpoints. It's nil when the cache is disabled
+func Initialize(c *Cache) {
+	repositoryCache = c
+}
+
+// GetStats returns the hit, miss and size counters of the cache
+func GetStats(c *gin.Context) {
+	rest.ErrorWrapper(getStats, c)
+}
+
+func getStats(c *gin.Context) error {
+	if repositoryCache == nil {
+		return rest.NewResourceNotFound("the cache is disabled")
+	}
+
+	c.JSON(http.StatusOK, repositoryCache.Stats())
+	return nil
+}
+
+// Flush removes every entry of the cache
+func Flush(c *gin.Context) {
+	rest.ErrorWrapper(flush, c)
+}
+
+func flush(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	if repositoryCache == nil {
+		return rest.NewResourceNotFound("the cache is disabled")
+	}
+
+	logger.Info("Flushing the cache")
+	repositoryCache.Flush()
+	c.Status(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+)
+
+// Every key of a character starts with its prefix, so a write invalidates the character and its phrases at once
+const allCharactersKey = "characters"
+
+func characterPrefix(characterId int64) string {
+	return fmt.Sprintf("character:%d:", characterId)
+}
+
+func characterKey(characterId int64) string {
+	return characterPrefix(characterId) + "self"
+}
+
+func aliasesKey(characterId int64) string {
+	return characterPrefix(characterId) + "aliases"
+}
+
+func phrasesKey(characterId int64) string {
+	return characterPrefix(characterId) + "phrases"
+}
+
+func phraseKey(characterId int64, id int64) string {
+	return fmt.Sprintf("%sphrase:%d", characterPrefix(characterId), id)
+}
+
+// CharacterRepository caches the reads of a CharacterRepository. Writes invalidate the character list and
+// every entry of the character they touch
+type CharacterRepository struct {
+	repository.CharacterRepository
+	cache *Cache
+}
+
+// NewCharacterRepository wraps a CharacterRepository with a cache
+func NewCharacterRepository(repo repository.CharacterRepository, cache *Cache) CharacterRepository {
+	return CharacterRepository{
+		CharacterRepository: repo,
+		cache:               cache,
+	}
+}
+
+func (repo CharacterRepository) Get(ctx context.Context, id int64) (model.Character, bool, error) {
+	if cached, ok := repo.cache.Get(characterKey(id)); ok {
+		return cached.(model.Character), true, nil
+	}
+
+	ch, found, err := repo.CharacterRepository.Get(ctx, id)
+	if err == nil && found {
+		repo.cache.Set(characterKey(id), ch)
+	}
+	return ch, found, err
+}
+
+func (repo CharacterRepository) GetAll(ctx context.Context) ([]model.Character, error) {
+	if cached, ok := repo.cache.Get(allCharactersKey); ok {
+		return append([]model.Character{}, cached.([]model.Character)...), nil
+	}
+
+	chs, err := repo.CharacterRepository.GetAll(ctx)
+	if err == nil {
+		repo.cache.Set(allCharactersKey, append([]model.Character{}, chs...))
+	}
+	return chs, err
+}
+
+func (repo CharacterRepository) Save(ctx context.Context, chCmd model.CharacterCommand) (model.Character, error) {
+	ch, err := repo.CharacterRepository.Save(ctx, chCmd)
+	if err == nil {
+		repo.invalidate(ch.ID)
+	}
+	return ch, err
+}
+
+func (repo CharacterRepository) Update(ctx context.Context, id int64, chCmd model.CharacterCommand, precondition model.Precondition) (model.Character, bool, error) {
+	// Invalidated even on errors: a failed precondition means the cached character is stale
+	defer repo.invalidate(id)
+	return repo.CharacterRepository.Update(ctx, id, chCmd, precondition)
+}
+
+func (repo CharacterRepository) SetHidden(ctx context.Context, id int64, hidden bool) (model.Character, bool, error) {
+	defer repo.invalidate(id)
+	return repo.CharacterRepository.SetHidden(ctx, id, hidden)
+}
+
+func (repo CharacterRepository) Delete(ctx context.Context, id int64, precondition model.Precondition) error {
+	defer repo.invalidate(id)
+	return repo.CharacterRepository.Delete(ctx, id, precondition)
+}
+
+func (repo CharacterRepository) GetAliases(ctx context.Context, id int64) ([]model.Alias, bool, error) {
+	if cached, ok := repo.cache.Get(aliasesKey(id)); ok {
+		return append([]model.Alias{}, cached.([]model.Alias)...), true, nil
+	}
+
+	aliases, found, err := repo.CharacterRepository.GetAliases(ctx, id)
+	if err == nil && found {
+		repo.cache.Set(aliasesKey(id), append([]model.Alias{}, aliases...))
+	}
+	return aliases, found, err
+}
+
+func (repo CharacterRepository) AddAlias(ctx context.Context, id int64, alCmd model.AliasCommand) (model.Alias, bool, error) {
+	alias, found, err := repo.CharacterRepository.AddAlias(ctx, id, alCmd)
+	if err == nil && found {
+		repo.cache.Invalidate(aliasesKey(id))
+	}
+	return alias, found, err
+}
+
+func (repo CharacterRepository) DeleteAlias(ctx context.Context, id int64, aliasId int64) error {
+	defer repo.cache.Invalidate(aliasesKey(id))
+	return repo.CharacterRepository.DeleteAlias(ctx, id, aliasId)
+}
+
+func (repo CharacterRepository) Merge(ctx context.Context, targetId int64, sourceId int64, precondition model.Precondition) (model.MergePlan, bool, error) {
+	defer repo.invalidate(sourceId)
+	defer repo.invalidate(targetId)
+	return repo.CharacterRepository.Merge(ctx, targetId, sourceId, precondition)
+}
+
+func (repo CharacterRepository) invalidate(characterId int64) {
+	repo.cache.Invalidate(allCharactersKey)
+	repo.cache.InvalidatePrefix(characterPrefix(characterId))
+}
+
+// PhraseRepository caches the reads of a PhraseRepository. Writes invalidate the phrase and the phrase list
+// of its character
+type PhraseRepository struct {
+	repository.PhraseRepository
+	cache *Cache
+}
+
+// NewPhraseRepository wraps a PhraseRepository with a cache
+func NewPhraseRepository(repo repository.PhraseRepository, cache *Cache) PhraseRepository {
+	return PhraseRepository{
+		PhraseRepository: repo,
+		cache:            cache,
+	}
+}
+
+func (repo PhraseRepository) Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error) {
+	if cached, ok := repo.cache.Get(phraseKey(characterId, id)); ok {
+		return cached.(model.Phrase), true, nil
+	}
+
+	phrase, found, err := repo.PhraseRepository.Get(ctx, characterId, id)
+	if err == nil && found {
+		repo.cache.Set(phraseKey(characterId, id), phrase)
+	}
+	return phrase, found, err
+}
+
+func (repo PhraseRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Phrase, bool, error) {
+	if cached, ok := repo.cache.Get(phrasesKey(characterId)); ok {
+		return append([]model.Phrase{}, cached.([]model.Phrase)...), true, nil
+	}
+
+	phrases, found, err := repo.PhraseRepository.GetAllForCharacter(ctx, characterId)
+	if err == nil && found {
+		repo.cache.Set(phrasesKey(characterId), append([]model.Phrase{}, phrases...))
+	}
+	return phrases, found, err
+}
+
+func (repo PhraseRepository) Save(ctx context.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
+	phrase, err := repo.PhraseRepository.Save(ctx, phCmd)
+	if err == nil {
+		repo.cache.Invalidate(phrasesKey(phCmd.CharacterId), phraseKey(phCmd.CharacterId, phrase.ID))
+	}
+	return phrase, err
+}
+
+func (repo PhraseRepository) Delete(ctx context.Context, characterId int64, id int64, precondition model.Precondition) error {
+	defer repo.cache.Invalidate(phrasesKey(characterId), phraseKey(characterId, id))
+	return repo.PhraseRepository.Delete(ctx, characterId, id, precondition)
+}
+
+func (repo PhraseRepository) DeleteAllForCharacter(ctx context.Context, characterId int64) error {
+	defer repo.cache.InvalidatePrefix(characterPrefix(characterId))
+	return repo.PhraseRepository.DeleteAllForCharacter(ctx, characterId)
+}
+
+func (repo PhraseRepository) SetSource(ctx context.Context, characterId int64, id int64, appearance *model.AppearanceCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	defer repo.cache.Invalidate(phrasesKey(characterId), phraseKey(characterId, id))
+	return repo.PhraseRepository.SetSource(ctx, characterId, id, appearance, precondition)
+}
+
+func (repo PhraseRepository) SetRating(ctx context.Context, characterId int64, id int64, ratingCmd model.RatingCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	defer repo.cache.Invalidate(phrasesKey(characterId), phraseKey(characterId, id))
+	return repo.PhraseRepository.SetRating(ctx, characterId, id, ratingCmd, precondition)
+}
+
+func (repo PhraseRepository) Vote(ctx context.Context, characterId int64, id int64, voter string, value int) (model.Phrase, bool, error) {
+	defer repo.cache.Invalidate(phrasesKey(characterId), phraseKey(characterId, id))
+	return repo.PhraseRepository.Vote(ctx, characterId, id, voter, value)
+}
+
+func (repo PhraseRepository) Moderate(ctx context.Context, ids []int64, status string, moderator string, reason *string) ([]model.Phrase, error) {
+	phrases, err := repo.PhraseRepository.Moderate(ctx, ids, status, moderator, reason)
+	for _, phrase := range phrases {
+		repo.cache.Invalidate(phrasesKey(phrase.CharacterId), phraseKey(phrase.CharacterId, phrase.ID))
+	}
+	return phrases, err
+}
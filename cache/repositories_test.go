@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type characterMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *characterMockRepository) Get(ctx context.Context, id int64) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id)
+	return args.Get(0).(model.Character), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) GetAll(ctx context.Context) ([]model.Character, error) {
+	args := repoMock.Called(ctx)
+	return args.Get(0).([]model.Character), args.Error(1)
+}
+
+func (repoMock *characterMockRepository) Save(ctx context.Context, chCmd model.CharacterCommand) (model.Character, error) {
+	args := repoMock.Called(ctx, chCmd)
+	return args.Get(0).(model.Character), args.Error(1)
+}
+
+func (repoMock *characterMockRepository) Update(ctx context.Context, id int64, chCmd model.CharacterCommand, precondition model.Precondition) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id, chCmd, precondition)
+	return args.Get(0).(model.Character), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) SetHidden(ctx context.Context, id int64, hidden bool) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id, hidden)
+	return args.Get(0).(model.Character), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) Delete(ctx context.Context, id int64, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, id, precondition)
+	return args.Error(0)
+}
+
+func (repoMock *characterMockRepository) GetByName(ctx context.Context, name string) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, name)
+	return args.Get(0).(model.Character), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) GetBySlug(ctx context.Context, slug string) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, slug)
+	return args.Get(0).(model.Character), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) PreviewMerge(ctx context.Context, targetId int64, sourceId int64) (model.MergePlan, bool, error) {
+	args := repoMock.Called(ctx, targetId, sourceId)
+	return args.Get(0).(model.MergePlan), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) Merge(ctx context.Context, targetId int64, sourceId int64, precondition model.Precondition) (model.MergePlan, bool, error) {
+	args := repoMock.Called(ctx, targetId, sourceId, precondition)
+	return args.Get(0).(model.MergePlan), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) GetAliases(ctx context.Context, id int64) ([]model.Alias, bool, error) {
+	args := repoMock.Called(ctx, id)
+	return args.Get(0).([]model.Alias), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) AddAlias(ctx context.Context, id int64, alCmd model.AliasCommand) (model.Alias, bool, error) {
+	args := repoMock.Called(ctx, id, alCmd)
+	return args.Get(0).(model.Alias), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) DeleteAlias(ctx context.Context, id int64, aliasId int64) error {
+	args := repoMock.Called(ctx, id, aliasId)
+	return args.Error(0)
+}
+
+type phrasesMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *phrasesMockRepository) Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id)
+	return args.Get(0).(model.Phrase), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId)
+	return args.Get(0).([]model.Phrase), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetByID(ctx context.Context, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, id)
+	return args.Get(0).(model.Phrase), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetBySlug(ctx context.Context, characterId int64, slug string) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, slug)
+	return args.Get(0).(model.Phrase), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) SetSource(ctx context.Context, characterId int64, id int64, appearance *model.AppearanceCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, appearance, precondition)
+	return args.Get(0).(model.Phrase), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) Vote(ctx context.Context, characterId int64, id int64, voter string, value int) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, voter, value)
+	return args.Get(0).(model.Phrase), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) SetRating(ctx context.Context, characterId int64, id int64, ratingCmd model.RatingCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, ratingCmd, precondition)
+	return args.Get(0).(model.Phrase), args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetTop(ctx context.Context, since time.Time, limit int, maxRating string) ([]model.RankedPhrase, error) {
+	args := repoMock.Called(ctx, since, limit, maxRating)
+	return args.Get(0).([]model.RankedPhrase), args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetByStatus(ctx context.Context, status string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, status)
+	return args.Get(0).([]model.Phrase), args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetSubmissions(ctx context.Context, submitter string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, submitter)
+	return args.Get(0).([]model.Phrase), args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) Moderate(ctx context.Context, ids []int64, status string, moderator string, reason *string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, ids, status, moderator, reason)
+	return args.Get(0).([]model.Phrase), args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetAll(ctx context.Context) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx)
+	return args.Get(0).([]model.Phrase), args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) Save(ctx context.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
+	args := repoMock.Called(ctx, phCmd)
+	return args.Get(0).(model.Phrase), args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) DeleteAllForCharacter(ctx context.Context, characterId int64) error {
+	args := repoMock.Called(ctx, characterId)
+	return args.Error(0)
+}
+
+func (repoMock *phrasesMockRepository) Delete(ctx context.Context, characterId int64, id int64, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, characterId, id, precondition)
+	return args.Error(0)
+}
+
+func TestCachedCharacterReadsOnce(t *testing.T) {
+	t.Log("A cached character should only be read once from the repository")
+
+	now := time.Now()
+	repoMock := &characterMockRepository{}
+	repoMock.On("Get", mock.Anything, int64(1)).Return(model.NewCharacter(1, "Fort", now, now), true, nil)
+	repo := NewCharacterRepository(repoMock, New(10, time.Minute))
+
+	repo.Get(context.Background(), 1)
+	ch, found, err := repo.Get(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Fort", ch.Name)
+	repoMock.AssertNumberOfCalls(t, "Get", 1)
+}
+
+func TestCachedCharacterUpdateInvalidates(t *testing.T) {
+	t.Log("Updating a character should invalidate it, the character list and its phrases")
+
+	now := time.Now()
+	c := New(10, time.Minute)
+	characterMock := &characterMockRepository{}
+	characterMock.On("GetAll", mock.Anything).Return([]model.Character{model.NewCharacter(1, "Fort", now, now)}, nil)
+	characterMock.On("Update", mock.Anything, int64(1), mock.Anything, mock.Anything).Return(model.NewCharacter(1, "Ricardo Fort", now, now), true, nil)
+	phrasesMock := &phrasesMockRepository{}
+	phrasesMock.On("GetAllForCharacter", mock.Anything, mock.Anything).Return([]model.Phrase{}, true, nil)
+	characters := NewCharacterRepository(characterMock, c)
+	phrases := NewPhraseRepository(phrasesMock, c)
+
+	characters.GetAll(context.Background())
+	phrases.GetAllForCharacter(context.Background(), 1)
+	phrases.GetAllForCharacter(context.Background(), 2)
+	characters.Update(context.Background(), 1, model.NewCharacterCommand("Ricardo Fort"), model.Precondition{})
+	characters.GetAll(context.Background())
+	phrases.GetAllForCharacter(context.Background(), 1)
+	phrases.GetAllForCharacter(context.Background(), 2)
+
+	characterMock.AssertNumberOfCalls(t, "GetAll", 2)
+	phrasesMock.AssertNumberOfCalls(t, "GetAllForCharacter", 3)
+}
+
+func TestCachedPhraseSaveInvalidatesList(t *testing.T) {
+	t.Log("Saving a phrase should invalidate the phrase list of its character")
+
+	now := time.Now()
+	phrasesMock := &phrasesMockRepository{}
+	phrasesMock.On("GetAllForCharacter", mock.Anything, int64(1)).Return([]model.Phrase{}, true, nil)
+	phrasesMock.On("Save", mock.Anything, mock.Anything).Return(model.NewPhrase(5, 1, nil, "miameeee", now, now), nil)
+	phrases := NewPhraseRepository(phrasesMock, New(10, time.Minute))
+
+	phrases.GetAllForCharacter(context.Background(), 1)
+	phrases.Save(context.Background(), model.PhraseCommand{CharacterId: 1, Content: "miameeee"})
+	phrases.GetAllForCharacter(context.Background(), 1)
+
+	phrasesMock.AssertNumberOfCalls(t, "GetAllForCharacter", 2)
+}
@@ -1,7 +1,9 @@
 package character
 
 import (
+	"context"
 	"fmt"
+	"github.com/airabinovich/memequotes_back/auth"
 	commonContext "github.com/airabinovich/memequotes_back/context"
 	"github.com/airabinovich/memequotes_back/model"
 	"github.com/airabinovich/memequotes_back/repository"
@@ -9,21 +11,25 @@ import (
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var characterRepository repository.CharacterRepository
 var phraseRepository repository.PhraseRepository
+var dialogueRepository repository.DialogueRepository
 
-func Initialize(chRepo repository.CharacterRepository, phRepo repository.PhraseRepository) {
+func Initialize(chRepo repository.CharacterRepository, phRepo repository.PhraseRepository, dlgRepo repository.DialogueRepository) {
 	characterRepository = chRepo
 	phraseRepository = phRepo
+	dialogueRepository = dlgRepo
 }
 
 func GetCharacter(c *gin.Context) {
 	rest.ErrorWrapper(getCharacter, c)
 }
 
-func getCharacter(c *gin.Context) *rest.APIError {
+func getCharacter(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
@@ -34,17 +40,43 @@ func getCharacter(c *gin.Context) *rest.APIError {
 	}
 
 	logger.Debug(fmt.Sprintf("Getting character with id %d", id))
-	ch, found, err := characterRepository.Get(c, id)
+	ch, found, err := characterRepository.Get(ctx, id)
 	if err != nil {
 		logger.Error("get character by id", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
 	}
-	if !found {
+	if !found || !visible(ctx, ch) {
 		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", id))
 	}
 
-	c.JSON(http.StatusOK, model.CharacterResultFromCharacter(ch))
-	return nil
+	return rest.VersionedJSON(c, ch.Version, ch.LastUpdated, model.CharacterResultFromCharacter(ch))
+}
+
+// GetCharacterByName returns the character going by a name, either its own or an alias
+func GetCharacterByName(c *gin.Context) {
+	rest.ErrorWrapper(getCharacterByName, c)
+}
+
+func getCharacterByName(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	name := strings.TrimSpace(c.Param("name"))
+	if name == "" {
+		return rest.NewBadRequest("name must not be empty")
+	}
+
+	logger.Debug(fmt.Sprintf("Getting character with name %s", name))
+	ch, found, err := characterRepository.GetByName(ctx, name)
+	if err != nil {
+		logger.Error("get character by name", err)
+		return err
+	}
+	if !found || !visible(ctx, ch) {
+		return rest.NewResourceNotFound(fmt.Sprintf("no character goes by the name %s", name))
+	}
+
+	return rest.VersionedJSON(c, ch.Version, ch.LastUpdated, model.CharacterResultFromCharacter(ch))
 }
 
 // SaveCharacter saves a new character
@@ -52,50 +84,50 @@ func SaveCharacter(c *gin.Context) {
 	rest.ErrorWrapper(saveCharacter, c)
 }
 
-func saveCharacter(c *gin.Context) *rest.APIError {
+func saveCharacter(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
 	var chCmd model.CharacterCommand
 	if err := c.ShouldBindJSON(&chCmd); err != nil {
 		logger.Error("creating character bad body format", err)
-		return rest.NewBadRequest(err.Error())
+		return rest.NewValidationError(err)
 	}
 
-	ch, err := characterRepository.Save(c, chCmd)
+	ch, err := characterRepository.Save(ctx, chCmd)
 	if err != nil {
 		logger.Error("error creating character", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
 	}
 
-	c.JSON(http.StatusOK, model.CharacterResultFromCharacter(ch))
-	return nil
+	return rest.VersionedJSON(c, ch.Version, ch.LastUpdated, model.CharacterResultFromCharacter(ch))
 }
 
 func GetAllCharacters(c *gin.Context) {
 	rest.ErrorWrapper(getAllCharacters, c)
 }
 
-func getAllCharacters(c *gin.Context) *rest.APIError {
+func getAllCharacters(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
 	logger.Debug("Getting all characters")
-	chs, err := characterRepository.GetAll(c)
+	chs, err := characterRepository.GetAll(ctx)
 	if err != nil {
 		logger.Error("get all character", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
 	}
 
 	chResults := make([]model.CharacterResult, len(chs))
+	var lastModified time.Time
 	for i, ch := range chs {
 		chResults[i] = model.CharacterResultFromCharacter(ch)
+		lastModified = rest.LatestUpdate(lastModified, ch.LastUpdated)
 	}
 
-	c.JSON(http.StatusOK, map[string]interface{}{
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
 		"results": chResults,
 	})
-	return nil
 }
 
 // UpdateCharacter updates an existing character
@@ -103,7 +135,7 @@ func UpdateCharacter(c *gin.Context) {
 	rest.ErrorWrapper(updateCharacter, c)
 }
 
-func updateCharacter(c *gin.Context) *rest.APIError {
+func updateCharacter(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
@@ -116,28 +148,32 @@ func updateCharacter(c *gin.Context) *rest.APIError {
 	var chCmd model.CharacterCommand
 	if err := c.ShouldBindJSON(&chCmd); err != nil {
 		logger.Error("updating character bad body format", err)
-		return rest.NewBadRequest(err.Error())
+		return rest.NewValidationError(err)
+	}
+
+	precondition, err := rest.IfMatch(c)
+	if err != nil {
+		return err
 	}
 
 	logger.Debug(fmt.Sprintf("Updating character with id %d", id))
-	ch, found, err := characterRepository.Update(c, id, chCmd)
+	ch, found, err := characterRepository.Update(ctx, id, chCmd, precondition)
 	if err != nil {
 		logger.Error("update character by id", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
 	}
 	if !found {
 		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", id))
 	}
 
-	c.JSON(http.StatusOK, model.CharacterResultFromCharacter(ch))
-	return nil
+	return rest.VersionedJSON(c, ch.Version, ch.LastUpdated, model.CharacterResultFromCharacter(ch))
 }
 
 func DeleteCharacter(c *gin.Context) {
 	rest.ErrorWrapper(deleteCharacter, c)
 }
 
-func deleteCharacter(c *gin.Context) *rest.APIError {
+func deleteCharacter(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
@@ -147,26 +183,199 @@ func deleteCharacter(c *gin.Context) *rest.APIError {
 		return rest.NewBadRequest(err.Error())
 	}
 
-	phrases, found, err := phraseRepository.GetAllForCharacter(c, characterId)
+	precondition, err := rest.IfMatch(c)
 	if err != nil {
-		logger.Error("cannot get phrases for character", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
 	}
-	if found && len(phrases) > 0 {
-		for _, ph := range phrases {
-			if err := phraseRepository.Delete(c, characterId, ph.ID); err != nil {
-				logger.Error("cannot delete phrase from character", err)
-				return rest.NewInternalServerError(err.Error())
-			}
+	// The character's version is checked before its phrases are removed, so a stale delete removes nothing
+	if !precondition.Unconditional() {
+		ch, found, err := characterRepository.Get(ctx, characterId)
+		if err != nil {
+			logger.Error("cannot get character", err)
+			return err
 		}
+		if found && !precondition.Allows(ch.Version) {
+			return rest.NewPreconditionFailed(fmt.Sprintf("character %d was modified, its version is %d", characterId, ch.Version))
+		}
+	}
+
+	// Dialogues would lose the character's lines, so they have to be changed first
+	dialogues, _, err := dialogueRepository.GetAllForCharacter(ctx, characterId)
+	if err != nil {
+		logger.Error("cannot get dialogues of character", err)
+		return err
+	}
+	if len(dialogues) > 0 {
+		return rest.NewConflict(fmt.Sprintf("character %d has lines in %d dialogues", characterId, len(dialogues)))
+	}
+
+	// Every phrase goes, the ones still pending or rejected too
+	if err := phraseRepository.DeleteAllForCharacter(ctx, characterId); err != nil {
+		logger.Error("cannot delete phrases from character", err)
+		return err
 	}
 
-	err = characterRepository.Delete(c, characterId)
+	err = characterRepository.Delete(ctx, characterId, precondition)
 	if err != nil {
 		logger.Error("error deleting character", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
+	}
+
+	c.Status(http.StatusGone)
+	return nil
+}
+
+// GetAliases returns the aliases of a character wrapped in a json object
+func GetAliases(c *gin.Context) {
+	rest.ErrorWrapper(getAliases, c)
+}
+
+func getAliases(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	aliases, found, err := characterRepository.GetAliases(ctx, id)
+	if err != nil {
+		logger.Error("get aliases of character", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", id))
+	}
+
+	aliasResults := make([]model.AliasResult, len(aliases))
+	for i, alias := range aliases {
+		aliasResults[i] = model.AliasResultFromAlias(alias)
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"results": aliasResults,
+	})
+	return nil
+}
+
+// AddAlias adds an alias to a character
+func AddAlias(c *gin.Context) {
+	rest.ErrorWrapper(addAlias, c)
+}
+
+func addAlias(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	var alCmd model.AliasCommand
+	if err := c.ShouldBindJSON(&alCmd); err != nil {
+		logger.Error("adding alias bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	alCmd.Alias = strings.TrimSpace(alCmd.Alias)
+	if alCmd.Alias == "" {
+		return rest.NewBadRequest("alias must not be empty")
+	}
+
+	alias, found, err := characterRepository.AddAlias(ctx, id, alCmd)
+	if err != nil {
+		logger.Error("add alias to character", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", id))
+	}
+
+	c.JSON(http.StatusCreated, model.AliasResultFromAlias(alias))
+	return nil
+}
+
+// DeleteAlias removes an alias from a character
+func DeleteAlias(c *gin.Context) {
+	rest.ErrorWrapper(deleteAlias, c)
+}
+
+func deleteAlias(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	aliasId, err := strconv.ParseInt(c.Param("alias-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting alias with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	if err := characterRepository.DeleteAlias(ctx, id, aliasId); err != nil {
+		logger.Error("delete alias of character", err)
+		return err
 	}
 
 	c.Status(http.StatusGone)
 	return nil
 }
+
+// MergeCharacter merges another character into this one, or previews the merge when it's a dry run
+func MergeCharacter(c *gin.Context) {
+	rest.ErrorWrapper(mergeCharacter, c)
+}
+
+func mergeCharacter(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	var mergeCmd model.MergeCommand
+	if err := c.ShouldBindJSON(&mergeCmd); err != nil {
+		logger.Error("merging character bad body format", err)
+		return rest.NewValidationError(err)
+	}
+
+	var plan model.MergePlan
+	var found bool
+	if mergeCmd.DryRun {
+		plan, found, err = characterRepository.PreviewMerge(ctx, id, mergeCmd.SourceId)
+	} else {
+		var precondition model.Precondition
+		if precondition, err = rest.IfMatch(c); err != nil {
+			return err
+		}
+		logger.Debug(fmt.Sprintf("Merging character %d into character %d", mergeCmd.SourceId, id))
+		plan, found, err = characterRepository.Merge(ctx, id, mergeCmd.SourceId, precondition)
+	}
+	if err != nil {
+		logger.Error("merge characters", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", id))
+	}
+
+	if mergeCmd.DryRun {
+		c.JSON(http.StatusOK, model.MergeResultFromPlan(plan, true))
+		return nil
+	}
+	return rest.VersionedJSON(c, plan.Target.Version, plan.Target.LastUpdated, model.MergeResultFromPlan(plan, false))
+}
+
+// visible tells whether the client of a request may see a character. Hidden characters are only seen by moderators
+func visible(ctx context.Context, ch model.Character) bool {
+	return ch.VisibleTo(auth.AtLeast(commonContext.Role(ctx), auth.RoleModerator))
+}
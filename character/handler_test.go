@@ -2,8 +2,12 @@ package character
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"github.com/airabinovich/memequotes_back/auth"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
 	"github.com/airabinovich/memequotes_back/model"
 	"github.com/airabinovich/memequotes_back/utils"
 	"github.com/gin-gonic/gin"
@@ -18,6 +22,7 @@ import (
 var (
 	characterMockRepo characterMockRepository
 	phraseMockRepo    phrasesMockRepository
+	dialogueMockRepo  dialoguesMockRepository
 )
 
 func TestGetCharacterNonNumericIdShouldFail(t *testing.T) {
@@ -97,6 +102,34 @@ func TestGetCharacterFound(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestGetCharacterHidden(t *testing.T) {
+	t.Log("A hidden character should only be found by moderators")
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Comandante Fort", now, now)
+	ch.Hidden = true
+
+	for role, status := range map[string]int{
+		auth.RoleTrusted:   http.StatusNotFound,
+		auth.RoleModerator: http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+
+		resetMocks()
+
+		characterMockRepo.On("Get", mock.Anything, int64(1)).Return(ch, true, nil)
+
+		r := utils.TestRouter()
+		r.Use(func(c *gin.Context) {
+			commonContext.WithRequestContext(commonContext.WithRole(commonContext.RequestContext(c), role), c)
+		})
+		r.GET("/character/:character-id", GetCharacter)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/character/1", nil))
+
+		assert.Equal(t, status, w.Code, role)
+	}
+}
+
 func TestGetAllCharactersDBFails(t *testing.T) {
 	t.Log("DB error should return Internal Server Error")
 
@@ -170,6 +203,27 @@ func TestSaveCharacterDBFails(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
+func TestSaveCharacterDuplicateName(t *testing.T) {
+	t.Log("Duplicate character name should return Conflict")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	characterMockRepo.On("Save", mock.Anything, mock.Anything).
+		Return(model.Character{}, customErrors.NewConflictError("character with name Comandante Fort already exists"))
+
+	chCmd := model.NewCharacterCommand("Comandante Fort")
+	body, _ := json.Marshal(chCmd)
+	req := httptest.NewRequest(http.MethodPost, "/character", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/character", SaveCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
 func TestSaveCharacterBadBodyFormat(t *testing.T) {
 	t.Log("Bad body format should return Bad Request")
 
@@ -251,7 +305,7 @@ func TestUpdateCharacterDBFails(t *testing.T) {
 
 	resetMocks()
 
-	characterMockRepo.On("Update", mock.Anything, mock.Anything, mock.Anything).Return(model.Character{}, false, errors.New("DB error"))
+	characterMockRepo.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Character{}, false, errors.New("DB error"))
 
 	chCmd := model.NewCharacterCommand("Comandante Fort")
 	body, _ := json.Marshal(chCmd)
@@ -271,7 +325,7 @@ func TestUpdateCharacterNotFound(t *testing.T) {
 
 	resetMocks()
 
-	characterMockRepo.On("Update", mock.Anything, mock.Anything, mock.Anything).Return(model.Character{}, false, nil)
+	characterMockRepo.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Character{}, false, nil)
 
 	chCmd := model.NewCharacterCommand("Comandante Fort")
 	body, _ := json.Marshal(chCmd)
@@ -293,7 +347,7 @@ func TestUpdateCharacterOK(t *testing.T) {
 
 	now := time.Now()
 	ch := model.NewCharacter(1, "Comandante Fort", now, now)
-	characterMockRepo.On("Update", mock.Anything, mock.Anything, mock.Anything).Return(ch, true, nil)
+	characterMockRepo.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(ch, true, nil)
 
 	chCmd := model.NewCharacterCommand("Comandante Fort")
 	body, _ := json.Marshal(chCmd)
@@ -331,8 +385,8 @@ func TestDeleteCharacterPhrasesDBError(t *testing.T) {
 
 	resetMocks()
 
-	phraseMockRepo.On("GetAllForCharacter", mock.Anything, mock.Anything).
-		Return([]model.Phrase{}, false, errors.New("DB error"))
+	phraseMockRepo.On("DeleteAllForCharacter", mock.Anything, int64(1)).
+		Return(errors.New("DB error"))
 
 	w := httptest.NewRecorder()
 
@@ -343,29 +397,19 @@ func TestDeleteCharacterPhrasesDBError(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	characterMockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestDeleteCharacterCannotDeletePhrases(t *testing.T) {
-	t.Log("Phrases repository fails deleting phrases should return an error")
+func TestDeleteCharacterDBError(t *testing.T) {
+	t.Log("Character repository fails should return an error")
 
 	resetMocks()
 
-	now := time.Now()
-	phrases := []model.Phrase{
-		{
-			ID:          1,
-			CharacterId: 1,
-			Character:   nil,
-			Content:     "Jojoojo",
-			DateCreated: now,
-			LastUpdated: now,
-		},
-	}
-	phraseMockRepo.On("GetAllForCharacter", mock.Anything, mock.Anything).
-		Return(phrases, true, nil)
-
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
-		Return(errors.New("cannot delete phrase"))
+	phraseMockRepo.On("DeleteAllForCharacter", mock.Anything, int64(1)).
+		Return(nil)
+
+	characterMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("DB error"))
 
 	w := httptest.NewRecorder()
 
@@ -378,30 +422,16 @@ func TestDeleteCharacterCannotDeletePhrases(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
-func TestDeleteCharacterDBError(t *testing.T) {
-	t.Log("Character repository fails should return an error")
+func TestDeleteCharacterOK(t *testing.T) {
+	t.Log("Delete Character should return Gone")
 
 	resetMocks()
 
-	now := time.Now()
-	phrases := []model.Phrase{
-		{
-			ID:          1,
-			CharacterId: 1,
-			Character:   nil,
-			Content:     "Jojoojo",
-			DateCreated: now,
-			LastUpdated: now,
-		},
-	}
-	phraseMockRepo.On("GetAllForCharacter", mock.Anything, mock.Anything).
-		Return(phrases, true, nil)
-
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
+	phraseMockRepo.On("DeleteAllForCharacter", mock.Anything, int64(1)).
 		Return(nil)
 
-	characterMockRepo.On("Delete", mock.Anything, mock.Anything).
-		Return(errors.New("DB error"))
+	characterMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
 
 	w := httptest.NewRecorder()
 
@@ -411,59 +441,317 @@ func TestDeleteCharacterDBError(t *testing.T) {
 	r.DELETE("/character/:character-id", DeleteCharacter)
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusGone, w.Code)
+	characterMockRepo.AssertCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
 }
 
-func TestDeleteCharacterOK(t *testing.T) {
-	t.Log("Delete Character should return Gone")
+func TestDeleteCharacterWithPendingPhrase(t *testing.T) {
+	t.Log("Deleting a character should first delete its phrases whatever their status, not only the approved ones")
 
 	resetMocks()
 
 	now := time.Now()
-	phrases := []model.Phrase{
-		{
-			ID:          1,
-			CharacterId: 1,
-			Character:   nil,
-			Content:     "Jojoojo",
-			DateCreated: now,
-			LastUpdated: now,
-		},
-	}
-	phraseMockRepo.On("GetAllForCharacter", mock.Anything, mock.Anything).
-		Return(phrases, true, nil)
-
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
-		Return(nil)
+	pending := model.NewPhrase(2, 1, nil, "Jojoojo", now, now)
+	pending.Status = model.PhraseStatusPending
+	phrases := []model.Phrase{pending}
+	phraseMockRepo.On("DeleteAllForCharacter", mock.Anything, int64(1)).Run(func(mock.Arguments) {
+		phrases = nil
+	}).Return(nil)
+	// The phrases reference the character, so it's deleted once they are gone
+	characterMockRepo.On("Delete", mock.Anything, int64(1), mock.Anything).Run(func(mock.Arguments) {
+		assert.Empty(t, phrases)
+	}).Return(nil)
 
-	characterMockRepo.On("Delete", mock.Anything, mock.Anything).
-		Return(nil)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/character/1", nil)
+
+	r := utils.TestRouter()
+	r.DELETE("/character/:character-id", DeleteCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	characterMockRepo.AssertCalled(t, "Delete", mock.Anything, int64(1), mock.Anything)
+	phraseMockRepo.AssertNotCalled(t, "GetAllForCharacter", mock.Anything, mock.Anything)
+}
+
+func TestUpdateCharacterIfMatch(t *testing.T) {
+	t.Log("Update character should pass the If-Match version to the repository and return the new version")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Comandante Fort", now, now)
+	ch.Version = 4
+	characterMockRepo.On("Update", mock.Anything, int64(1), mock.Anything, model.NewPrecondition(3)).Return(ch, true, nil)
+
+	body, _ := json.Marshal(model.NewCharacterCommand("Comandante Fort"))
+	req := httptest.NewRequest(http.MethodPatch, "/character/1", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"v3"`)
+
+	r := utils.TestRouter()
+	r.PATCH("/character/:character-id", UpdateCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v4"`, w.Header().Get("ETag"))
+}
+
+func TestUpdateCharacterStaleVersion(t *testing.T) {
+	t.Log("Update character with a stale version should return Precondition Failed")
 
 	w := httptest.NewRecorder()
 
+	resetMocks()
+
+	characterMockRepo.On("Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(model.Character{}, true, customErrors.NewPreconditionFailedError("character 1 was modified"))
+
+	body, _ := json.Marshal(model.NewCharacterCommand("Comandante Fort"))
+	req := httptest.NewRequest(http.MethodPatch, "/character/1", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"v3"`)
+
+	r := utils.TestRouter()
+	r.PATCH("/character/:character-id", UpdateCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestDeleteCharacterStaleVersionKeepsPhrases(t *testing.T) {
+	t.Log("Delete character with a stale version should return Precondition Failed without removing its phrases")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Comandante Fort", now, now)
+	ch.Version = 4
+	characterMockRepo.On("Get", mock.Anything, mock.Anything).Return(ch, true, nil)
+
 	req := httptest.NewRequest(http.MethodDelete, "/character/1", nil)
+	req.Header.Set("If-Match", `"v3"`)
 
 	r := utils.TestRouter()
 	r.DELETE("/character/:character-id", DeleteCharacter)
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusGone, w.Code)
-	characterMockRepo.AssertCalled(t, "Delete", mock.Anything, mock.Anything)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	phraseMockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	characterMockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetCharacterByName(t *testing.T) {
+	t.Log("A character should be found by one of its aliases")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Ricardo Fort", now, now)
+	characterMockRepo.On("GetByName", mock.Anything, "Comandante Fort").Return(ch, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/characters/by-name/Comandante%20Fort", nil)
+
+	r := utils.TestRouter()
+	r.GET("/characters/by-name/:name", GetCharacterByName)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.CharacterResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1), actualResult.ID)
+	assert.Equal(t, "Ricardo Fort", actualResult.Name)
+}
+
+func TestGetCharacterByNameNotFound(t *testing.T) {
+	t.Log("A name no character goes by should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	characterMockRepo.On("GetByName", mock.Anything, "Fort").Return(model.Character{}, false, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/characters/by-name/Fort", nil)
+
+	r := utils.TestRouter()
+	r.GET("/characters/by-name/:name", GetCharacterByName)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAddAliasCreated(t *testing.T) {
+	t.Log("Adding an alias should return it with Created")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	alias := model.NewAlias(3, 1, "Comandante Fort", time.Now())
+	characterMockRepo.On("AddAlias", mock.Anything, int64(1), model.NewAliasCommand("Comandante Fort")).Return(alias, true, nil)
+
+	body, _ := json.Marshal(model.NewAliasCommand("  Comandante Fort "))
+	req := httptest.NewRequest(http.MethodPost, "/character/1/alias", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/alias", AddAlias)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.AliasResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, int64(3), actualResult.ID)
+	assert.Equal(t, "Comandante Fort", actualResult.Alias)
+}
+
+func TestAddAliasTakenName(t *testing.T) {
+	t.Log("Adding an alias another character goes by should return Conflict")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	characterMockRepo.On("AddAlias", mock.Anything, int64(2), mock.Anything).Return(model.Alias{}, true, customErrors.NewConflictError("character 1 already goes by the name Fort"))
+
+	body, _ := json.Marshal(model.NewAliasCommand("fórt"))
+	req := httptest.NewRequest(http.MethodPost, "/character/2/alias", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/alias", AddAlias)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestMergeCharacterDryRun(t *testing.T) {
+	t.Log("A dry run merge should preview the phrases and collisions without merging")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	plan := model.MergePlan{
+		Target:        model.NewCharacter(1, "Ricardo Fort", now, now),
+		Source:        model.NewCharacter(7, "Comandante Fort", now, now),
+		TargetPhrases: 2,
+		Moved:         []model.Phrase{model.NewPhrase(10, 7, nil, "Tengo un sueño", now, now)},
+		Collisions: []model.PhraseCollision{{
+			SourcePhrase: model.NewPhrase(11, 7, nil, "miameeee!!", now, now),
+			TargetPhrase: model.NewPhrase(2, 1, nil, "Miameeee", now, now),
+		}},
+	}
+	characterMockRepo.On("PreviewMerge", mock.Anything, int64(1), int64(7)).Return(plan, true, nil)
+
+	body, _ := json.Marshal(model.NewMergeCommand(7, true))
+	req := httptest.NewRequest(http.MethodPost, "/character/1/merge", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/merge", MergeCharacter)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.MergeResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, actualResult.DryRun)
+	assert.Equal(t, 2, actualResult.SourcePhrases)
+	assert.Equal(t, 1, actualResult.MovedPhrases)
+	assert.Equal(t, int64(2), actualResult.Collisions[0].TargetPhraseId)
+	characterMockRepo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMergeCharacter(t *testing.T) {
+	t.Log("Merging should pass the If-Match version to the repository and return the new version")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	target := model.NewCharacter(1, "Ricardo Fort", now, now)
+	target.Version = 4
+	plan := model.MergePlan{Target: target, Source: model.NewCharacter(7, "Comandante Fort", now, now)}
+	characterMockRepo.On("Merge", mock.Anything, int64(1), int64(7), model.NewPrecondition(3)).Return(plan, true, nil)
+
+	body, _ := json.Marshal(model.NewMergeCommand(7, false))
+	req := httptest.NewRequest(http.MethodPost, "/character/1/merge", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"v3"`)
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/merge", MergeCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v4"`, w.Header().Get("ETag"))
+}
+
+func TestMergeCharacterSourceNotFound(t *testing.T) {
+	t.Log("Merging a character that doesn't exist should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	characterMockRepo.On("Merge", mock.Anything, int64(1), int64(7), mock.Anything).
+		Return(model.MergePlan{}, true, customErrors.NewNotFoundError("character 7 not found"))
+
+	body, _ := json.Marshal(model.NewMergeCommand(7, false))
+	req := httptest.NewRequest(http.MethodPost, "/character/1/merge", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/merge", MergeCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteCharacterInDialogueShouldConflict(t *testing.T) {
+	t.Log("Delete a character with lines in dialogues should return Conflict without removing its phrases")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	dialogueMockRepo = dialoguesMockRepository{}
+	dialogueMockRepo.On("GetAllForCharacter", mock.Anything, int64(1)).
+		Return([]model.Dialogue{model.NewDialogue(5, "", nil, now, now)}, true, nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/character/1", nil)
+
+	r := utils.TestRouter()
+	r.DELETE("/character/:character-id", DeleteCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	phraseMockRepo.AssertNotCalled(t, "GetAllForCharacter", mock.Anything, mock.Anything)
+	characterMockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func resetMocks() {
 	characterMockRepo = characterMockRepository{}
 	phraseMockRepo = phrasesMockRepository{}
+	dialogueMockRepo = dialoguesMockRepository{}
+	dialogueMockRepo.On("GetAllForCharacter", mock.Anything, mock.Anything).Return([]model.Dialogue{}, true, nil)
 	characterRepository = &characterMockRepo
 	phraseRepository = &phraseMockRepo
+	dialogueRepository = &dialogueMockRepo
 }
 
 type characterMockRepository struct {
 	mock.Mock
 }
 
-func (repoMock *characterMockRepository) Get(c *gin.Context, id int64) (model.Character, bool, error) {
-	args := repoMock.Called(c, id)
+func (repoMock *characterMockRepository) Get(ctx context.Context, id int64) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id)
 
 	ch, ok := args.Get(0).(model.Character)
 	if !ok {
@@ -478,8 +766,8 @@ func (repoMock *characterMockRepository) Get(c *gin.Context, id int64) (model.Ch
 	return ch, found, args.Error(2)
 }
 
-func (repoMock *characterMockRepository) Save(c *gin.Context, chCmd model.CharacterCommand) (model.Character, error) {
-	args := repoMock.Called(c, chCmd)
+func (repoMock *characterMockRepository) Save(ctx context.Context, chCmd model.CharacterCommand) (model.Character, error) {
+	args := repoMock.Called(ctx, chCmd)
 
 	ch, ok := args.Get(0).(model.Character)
 	if !ok {
@@ -489,8 +777,8 @@ func (repoMock *characterMockRepository) Save(c *gin.Context, chCmd model.Charac
 	return ch, args.Error(1)
 }
 
-func (repoMock *characterMockRepository) Update(c *gin.Context, id int64, chCmd model.CharacterCommand) (model.Character, bool, error) {
-	args := repoMock.Called(c, id, chCmd)
+func (repoMock *characterMockRepository) Update(ctx context.Context, id int64, chCmd model.CharacterCommand, precondition model.Precondition) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id, chCmd, precondition)
 
 	ch, ok := args.Get(0).(model.Character)
 	if !ok {
@@ -505,8 +793,8 @@ func (repoMock *characterMockRepository) Update(c *gin.Context, id int64, chCmd
 	return ch, found, args.Error(2)
 }
 
-func (repoMock *characterMockRepository) GetAll(c *gin.Context) ([]model.Character, error) {
-	args := repoMock.Called(c)
+func (repoMock *characterMockRepository) GetAll(ctx context.Context) ([]model.Character, error) {
+	args := repoMock.Called(ctx)
 
 	ch, ok := args.Get(0).([]model.Character)
 	if !ok {
@@ -516,8 +804,126 @@ func (repoMock *characterMockRepository) GetAll(c *gin.Context) ([]model.Charact
 	return ch, args.Error(1)
 }
 
-func (repoMock *characterMockRepository) Delete(c *gin.Context, id int64) error {
-	args := repoMock.Called(c, id)
+func (repoMock *characterMockRepository) SetHidden(ctx context.Context, id int64, hidden bool) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id, hidden)
+
+	ch, ok := args.Get(0).(model.Character)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ch, found, args.Error(2)
+}
+
+func (repoMock *characterMockRepository) Delete(ctx context.Context, id int64, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, id, precondition)
+
+	return args.Error(0)
+}
+
+func (repoMock *characterMockRepository) GetByName(ctx context.Context, name string) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, name)
+
+	ch, ok := args.Get(0).(model.Character)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ch, found, args.Error(2)
+}
+
+func (repoMock *characterMockRepository) GetBySlug(ctx context.Context, slug string) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, slug)
+
+	ch, ok := args.Get(0).(model.Character)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ch, found, args.Error(2)
+}
+
+func (repoMock *characterMockRepository) PreviewMerge(ctx context.Context, targetId int64, sourceId int64) (model.MergePlan, bool, error) {
+	args := repoMock.Called(ctx, targetId, sourceId)
+
+	plan, ok := args.Get(0).(model.MergePlan)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return plan, found, args.Error(2)
+}
+
+func (repoMock *characterMockRepository) Merge(ctx context.Context, targetId int64, sourceId int64, precondition model.Precondition) (model.MergePlan, bool, error) {
+	args := repoMock.Called(ctx, targetId, sourceId, precondition)
+
+	plan, ok := args.Get(0).(model.MergePlan)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return plan, found, args.Error(2)
+}
+
+func (repoMock *characterMockRepository) GetAliases(ctx context.Context, id int64) ([]model.Alias, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	aliases, ok := args.Get(0).([]model.Alias)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return aliases, found, args.Error(2)
+}
+
+func (repoMock *characterMockRepository) AddAlias(ctx context.Context, id int64, alCmd model.AliasCommand) (model.Alias, bool, error) {
+	args := repoMock.Called(ctx, id, alCmd)
+
+	alias, ok := args.Get(0).(model.Alias)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return alias, found, args.Error(2)
+}
+
+func (repoMock *characterMockRepository) DeleteAlias(ctx context.Context, id int64, aliasId int64) error {
+	args := repoMock.Called(ctx, id, aliasId)
 
 	return args.Error(0)
 }
@@ -526,8 +932,8 @@ type phrasesMockRepository struct {
 	mock.Mock
 }
 
-func (repoMock *phrasesMockRepository) Get(c *gin.Context, characterId int64, id int64) (model.Phrase, bool, error) {
-	args := repoMock.Called(c, characterId, id)
+func (repoMock *phrasesMockRepository) Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id)
 
 	ph, ok := args.Get(0).(model.Phrase)
 	if !ok {
@@ -542,8 +948,8 @@ func (repoMock *phrasesMockRepository) Get(c *gin.Context, characterId int64, id
 	return ph, found, args.Error(2)
 }
 
-func (repoMock *phrasesMockRepository) GetAllForCharacter(c *gin.Context, characterId int64) ([]model.Phrase, bool, error) {
-	args := repoMock.Called(c, characterId)
+func (repoMock *phrasesMockRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId)
 
 	ph, ok := args.Get(0).([]model.Phrase)
 	if !ok {
@@ -558,18 +964,232 @@ func (repoMock *phrasesMockRepository) GetAllForCharacter(c *gin.Context, charac
 	return ph, found, args.Error(2)
 }
 
-func (repoMock *phrasesMockRepository) Save(c *gin.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
-	args := repoMock.Called(c, phCmd)
+func (repoMock *phrasesMockRepository) GetByID(ctx context.Context, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetBySlug(ctx context.Context, characterId int64, slug string) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, slug)
 
 	ph, ok := args.Get(0).(model.Phrase)
 	if !ok {
 		panic(errors.New("mock error"))
 	}
 
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) SetSource(ctx context.Context, characterId int64, id int64, appearance *model.AppearanceCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, appearance, precondition)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) SetRating(ctx context.Context, characterId int64, id int64, ratingCmd model.RatingCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, ratingCmd, precondition)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) Vote(ctx context.Context, characterId int64, id int64, voter string, value int) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, voter, value)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetTop(ctx context.Context, since time.Time, limit int, maxRating string) ([]model.RankedPhrase, error) {
+	args := repoMock.Called(ctx, since, limit, maxRating)
+
+	ranked, ok := args.Get(0).([]model.RankedPhrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ranked, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetByStatus(ctx context.Context, status string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, status)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
 	return ph, args.Error(1)
 }
 
-func (repoMock *phrasesMockRepository) Delete(c *gin.Context, characterId int64, id int64) error {
-	args := repoMock.Called(c, characterId, id)
+func (repoMock *phrasesMockRepository) GetSubmissions(ctx context.Context, submitter string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, submitter)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) Moderate(ctx context.Context, ids []int64, status string, moderator string, reason *string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, ids, status, moderator, reason)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetAll(ctx context.Context) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) Save(ctx context.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
+	args := repoMock.Called(ctx, phCmd)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) DeleteAllForCharacter(ctx context.Context, characterId int64) error {
+	args := repoMock.Called(ctx, characterId)
+	return args.Error(0)
+}
+
+func (repoMock *phrasesMockRepository) Delete(ctx context.Context, characterId int64, id int64, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, characterId, id, precondition)
+	return args.Error(0)
+}
+
+type dialoguesMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *dialoguesMockRepository) Get(ctx context.Context, id int64) (model.Dialogue, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	dialogue, ok := args.Get(0).(model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogue, found, args.Error(2)
+}
+
+func (repoMock *dialoguesMockRepository) GetAll(ctx context.Context) ([]model.Dialogue, error) {
+	args := repoMock.Called(ctx)
+
+	dialogues, ok := args.Get(0).([]model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogues, args.Error(1)
+}
+
+func (repoMock *dialoguesMockRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Dialogue, bool, error) {
+	args := repoMock.Called(ctx, characterId)
+
+	dialogues, ok := args.Get(0).([]model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogues, found, args.Error(2)
+}
+
+func (repoMock *dialoguesMockRepository) Save(ctx context.Context, dlgCmd model.DialogueCommand) (model.Dialogue, error) {
+	args := repoMock.Called(ctx, dlgCmd)
+
+	dialogue, ok := args.Get(0).(model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogue, args.Error(1)
+}
+
+func (repoMock *dialoguesMockRepository) Update(ctx context.Context, id int64, dlgCmd model.DialogueCommand, precondition model.Precondition) (model.Dialogue, bool, error) {
+	args := repoMock.Called(ctx, id, dlgCmd, precondition)
+
+	dialogue, ok := args.Get(0).(model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogue, found, args.Error(2)
+}
+
+func (repoMock *dialoguesMockRepository) Delete(ctx context.Context, id int64, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, id, precondition)
 	return args.Error(0)
 }
@@ -1,110 +1,680 @@
 package character
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
 	"github.com/airabinovich/memequotes_back/model"
-	"github.com/gin-gonic/gin"
+	"github.com/airabinovich/memequotes_back/phrase"
+	"github.com/airabinovich/memequotes_back/slug"
 	"github.com/jinzhu/gorm"
 	"time"
 )
 
+// maxSlugLength leaves room in the slug columns for the suffix of a taken slug
+const maxSlugLength = 100
+
 type DBCharacterRepository struct {
-	db *gorm.DB
+	cluster *database.Cluster
 }
 
-func NewDBCharacterRepository(db *gorm.DB) DBCharacterRepository {
+func NewDBCharacterRepository(cluster *database.Cluster) DBCharacterRepository {
 	return DBCharacterRepository{
-		db: db,
+		cluster: cluster,
 	}
 }
 
-func (repo DBCharacterRepository) Get(c *gin.Context, id int64) (model.Character, bool, error) {
-	ctx := commonContext.RequestContext(c)
+func (repo DBCharacterRepository) Get(ctx context.Context, id int64) (model.Character, bool, error) {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Getting Character with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	ch := model.Character{}
+	notFound := false
+	err := database.RetryRead(ctx, "getting character", func() error {
+		result := db.Where("id = ?", id).Find(&ch)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return model.Character{}, false, database.TranslateError(err)
+	}
+	return ch, !notFound, nil
+}
+
+func (repo DBCharacterRepository) GetByName(ctx context.Context, name string) (model.Character, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Character with name %s", name))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
 
 	ch := model.Character{}
-	db := repo.db.Where("id = ?", id).Find(&ch)
-	notFound := db.RecordNotFound()
-	if db.Error != nil && !notFound {
-		return model.Character{}, false, db.Error
+	notFound := false
+	err := database.RetryRead(ctx, "getting character by name", func() error {
+		result := db.Where("name = ?", name).
+			Or("id = (SELECT character_id FROM character_aliases WHERE alias = ?)", name).
+			Find(&ch)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return model.Character{}, false, database.TranslateError(err)
+	}
+	return ch, !notFound, nil
+}
+
+func (repo DBCharacterRepository) GetBySlug(ctx context.Context, characterSlug string) (model.Character, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Character with slug %s", characterSlug))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	ch := model.Character{}
+	notFound := false
+	err := database.RetryRead(ctx, "getting character by slug", func() error {
+		result := db.Where("slug = ?", characterSlug).
+			Or("id = (SELECT character_id FROM character_slug_redirects WHERE slug = ?)", characterSlug).
+			Find(&ch)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return model.Character{}, false, database.TranslateError(err)
 	}
 	return ch, !notFound, nil
 }
 
-func (repo DBCharacterRepository) GetAll(c *gin.Context) ([]model.Character, error) {
-	ctx := commonContext.RequestContext(c)
+func (repo DBCharacterRepository) GetAll(ctx context.Context) ([]model.Character, error) {
 	logger := commonContext.Logger(ctx)
 	logger.Debug("Getting all Characters")
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
 
 	chs := make([]model.Character, 0)
-	db := repo.db.Find(&chs)
-	if db.Error != nil {
-		return []model.Character{}, db.Error
+	err := database.RetryRead(ctx, "getting all characters", func() error {
+		return db.Where("hidden = ?", false).Find(&chs).Error
+	})
+	if err != nil {
+		return []model.Character{}, database.TranslateError(err)
 	}
 
 	return chs, nil
 }
 
-func (repo DBCharacterRepository) Save(c *gin.Context, chCmd model.CharacterCommand) (model.Character, error) {
-	ctx := commonContext.RequestContext(c)
+func (repo DBCharacterRepository) Save(ctx context.Context, chCmd model.CharacterCommand) (model.Character, error) {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Creating Character with name %s", chCmd.Name))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	now := time.Now()
 	ch := model.NewCharacter(0, chCmd.Name, now, now)
-	if !repo.db.NewRecord(ch) {
-		return model.Character{}, errors.New("characters already exists")
-	}
-	if err := repo.db.Create(&ch).Error; err != nil {
+	ch.Version = 1
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "creating character", func(tx *gorm.DB) error {
+		if err := checkNameAvailable(tx, chCmd.Name, 0); err != nil {
+			return err
+		}
+		characterSlug, err := uniqueSlug(tx, chCmd.Name, 0)
+		if err != nil {
+			return err
+		}
+		ch.Slug = characterSlug
+		return tx.Create(&ch).Error
+	})
+	if err != nil {
 		logger.Error("creating character", err)
-		return model.Character{}, err
+		return model.Character{}, translateNameError(err, chCmd.Name)
 	}
 	return ch, nil
 }
 
-func (repo DBCharacterRepository) Update(c *gin.Context, id int64, chCmd model.CharacterCommand) (model.Character, bool, error) {
-	ctx := commonContext.RequestContext(c)
+func (repo DBCharacterRepository) Update(ctx context.Context, id int64, chCmd model.CharacterCommand, precondition model.Precondition) (model.Character, bool, error) {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Updating Character with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	ch := model.Character{}
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "updating character", func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Find(&ch)
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if !precondition.Allows(ch.Version) {
+			return characterModifiedError(ch)
+		}
+		if err := checkNameAvailable(tx, chCmd.Name, id); err != nil {
+			return err
+		}
+		characterSlug, err := uniqueSlug(tx, chCmd.Name, id)
+		if err != nil {
+			return err
+		}
 
-	ch, found, err := repo.Get(c, id)
+		// The version check makes the update fail if another write got in since the character was read
+		read := ch
+		now := time.Now()
+		result = tx.Model(&ch).Where("version = ?", read.Version).Updates(map[string]interface{}{
+			"name":         chCmd.Name,
+			"slug":         characterSlug,
+			"last_updated": now,
+			"version":      read.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return characterModifiedError(read)
+		}
+		if characterSlug != read.Slug {
+			if err := redirectSlug(tx, read, characterSlug, now); err != nil {
+				return err
+			}
+		}
+
+		ch.Name = chCmd.Name
+		ch.Slug = characterSlug
+		ch.LastUpdated = now
+		ch.Version = read.Version + 1
+		return nil
+	})
 	if err != nil {
-		logger.Error("error retrieving character", err)
-		return model.Character{}, false, err
+		logger.Error("updating character", err)
+		return model.Character{}, found, translateNameError(err, chCmd.Name)
 	}
 	if !found {
 		return model.Character{}, false, nil
 	}
 
-	ch.Name = chCmd.Name
-	ch.LastUpdated = time.Now()
-	if err := repo.db.Save(&ch).Error; err != nil {
-		logger.Error("updating character", err)
-		return model.Character{}, true, err
-	}
+	return ch, true, nil
+}
+
+func (repo DBCharacterRepository) SetHidden(ctx context.Context, id int64, hidden bool) (model.Character, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Setting hidden to %t on Character with id %d", hidden, id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
+	ch := model.Character{}
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "hiding character", func(tx *gorm.DB) error {
+		result := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).Find(&ch)
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if ch.Hidden == hidden {
+			return nil
+		}
+
+		read := ch
+		now := time.Now()
+		err := tx.Model(&ch).Updates(map[string]interface{}{
+			"hidden":       hidden,
+			"last_updated": now,
+			"version":      read.Version + 1,
+		}).Error
+		if err != nil {
+			return err
+		}
+		ch.Hidden = hidden
+		ch.LastUpdated = now
+		ch.Version = read.Version + 1
+		return nil
+	})
+	if err != nil {
+		logger.Error("hiding character", err)
+		return model.Character{}, found, database.TranslateError(err)
+	}
+	if !found {
+		return model.Character{}, false, nil
+	}
 	return ch, true, nil
 }
 
-func (repo DBCharacterRepository) Delete(c *gin.Context, id int64) error {
-	ctx := commonContext.RequestContext(c)
+func (repo DBCharacterRepository) Delete(ctx context.Context, id int64, precondition model.Precondition) error {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Deleting Character with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Writer(ctx)
 
-	phrase, found, err := repo.Get(c, id)
+	ch, found, err := repo.Get(ctx, id)
 	if err != nil {
 		return err
 	}
 	if !found {
 		return nil
 	}
+	if precondition.Unconditional() {
+		if err := db.Delete(&ch).Error; err != nil {
+			return database.TranslateError(err)
+		}
+		return nil
+	}
+
+	if !precondition.Allows(ch.Version) {
+		return characterModifiedError(ch)
+	}
+	result := db.Where("version = ?", ch.Version).Delete(&ch)
+	if result.Error != nil {
+		return database.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return characterModifiedError(ch)
+	}
+	return nil
+}
+
+func (repo DBCharacterRepository) GetAliases(ctx context.Context, id int64) ([]model.Alias, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting aliases of Character with id %d", id))
+
+	if _, found, err := repo.Get(ctx, id); err != nil || !found {
+		return nil, found, err
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	aliases := make([]model.Alias, 0)
+	err := database.RetryRead(ctx, "getting aliases", func() error {
+		return db.Where("character_id = ?", id).Order("id").Find(&aliases).Error
+	})
+	if err != nil {
+		return nil, false, database.TranslateError(err)
+	}
+	return aliases, true, nil
+}
+
+func (repo DBCharacterRepository) AddAlias(ctx context.Context, id int64, alCmd model.AliasCommand) (model.Alias, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Adding alias %s to Character with id %d", alCmd.Alias, id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	alias := model.NewAlias(0, id, alCmd.Alias, time.Now())
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "adding alias", func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Find(&model.Character{})
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := checkNameAvailable(tx, alCmd.Alias, 0); err != nil {
+			return err
+		}
+		return tx.Create(&alias).Error
+	})
+	if err != nil {
+		logger.Error("adding alias", err)
+		return model.Alias{}, found, translateNameError(err, alCmd.Alias)
+	}
+	if !found {
+		return model.Alias{}, false, nil
+	}
+	return alias, true, nil
+}
 
-	db := repo.db.Delete(&phrase)
-	if db.Error != nil {
-		return db.Error
+func (repo DBCharacterRepository) DeleteAlias(ctx context.Context, id int64, aliasId int64) error {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Deleting alias %d of Character with id %d", aliasId, id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Writer(ctx)
+
+	if err := db.Where("id = ? AND character_id = ?", aliasId, id).Delete(&model.Alias{}).Error; err != nil {
+		return database.TranslateError(err)
 	}
 	return nil
+}
+
+func (repo DBCharacterRepository) PreviewMerge(ctx context.Context, targetId int64, sourceId int64) (model.MergePlan, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Previewing merge of Character %d into Character %d", sourceId, targetId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	var plan model.MergePlan
+	found := false
+	err := database.RetryRead(ctx, "previewing character merge", func() error {
+		var err error
+		plan, found, err = planMerge(db, targetId, sourceId)
+		return err
+	})
+	if err != nil {
+		return model.MergePlan{}, false, database.TranslateError(err)
+	}
+	return plan, found, nil
+}
+
+func (repo DBCharacterRepository) Merge(ctx context.Context, targetId int64, sourceId int64, precondition model.Precondition) (model.MergePlan, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Merging Character %d into Character %d", sourceId, targetId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var plan model.MergePlan
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "merging characters", func(tx *gorm.DB) error {
+		// Both characters are locked, in the order of their ids so concurrent merges of the same pair can't deadlock
+		var locked []model.Character
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id IN (?)", []int64{targetId, sourceId}).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+
+		var err error
+		plan, found, err = planMerge(tx, targetId, sourceId)
+		if err != nil || !found {
+			return err
+		}
+		read := plan.Target
+		if !precondition.Allows(read.Version) {
+			return characterModifiedError(read)
+		}
+
+		now := time.Now()
+		for _, phrase := range plan.Moved {
+			err := tx.Model(&model.Phrase{}).Where("id = ?", phrase.ID).Updates(map[string]interface{}{
+				"character_id": targetId,
+				"slug":         phrase.Slug,
+				"last_updated": now,
+				"version":      gorm.Expr("version + 1"),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		for _, collision := range plan.Collisions {
+			// Dialogue lines taken from a dropped phrase are taken from the phrase it duplicates instead
+			err := tx.Model(&model.DialogueLine{}).Where("phrase_id = ?", collision.SourcePhrase.ID).
+				Updates(map[string]interface{}{
+					"phrase_id": collision.TargetPhrase.ID,
+					"rating":    model.StrongerRating(collision.TargetPhrase.Rating, model.RatingSafe),
+				}).Error
+			if err != nil {
+				return err
+			}
+			// So are collections, unless they have both phrases. The entries left go with the dropped phrase
+			err = tx.Exec("UPDATE IGNORE collection_phrases SET phrase_id = ? WHERE phrase_id = ?",
+				collision.TargetPhrase.ID, collision.SourcePhrase.ID).Error
+			if err != nil {
+				return err
+			}
+			// And the reports, which stay open on the phrase kept
+			err = moveReports(tx, model.ReportTargetPhrase, collision.SourcePhrase.ID, collision.TargetPhrase.ID, targetId, now)
+			if err != nil {
+				return err
+			}
+			if err := tx.Delete(&model.Phrase{}, "id = ?", collision.SourcePhrase.ID).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&model.Alias{}).Where("character_id = ?", sourceId).Update("character_id", targetId).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.DialogueLine{}).Where("character_id = ?", sourceId).Update("character_id", targetId).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.SlugRedirect{}).Where("character_id = ?", sourceId).Update("character_id", targetId).Error; err != nil {
+			return err
+		}
+		// The reports on the moved phrases go with them, and the ones on the source character to the target
+		err = tx.Model(&model.Report{}).Where("character_id = ? AND target_type = ?", sourceId, model.ReportTargetPhrase).
+			Update("character_id", targetId).Error
+		if err != nil {
+			return err
+		}
+		if err := moveReports(tx, model.ReportTargetCharacter, sourceId, targetId, targetId, now); err != nil {
+			return err
+		}
+
+		// The source is removed before its name and slug are taken over, they are unique
+		if err := tx.Delete(&model.Character{}, "id = ?", sourceId).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.Alias{CharacterId: targetId, Alias: plan.Source.Name, DateCreated: now}).Error; err != nil {
+			return err
+		}
+		if plan.Source.Slug != "" {
+			if err := tx.Create(&model.SlugRedirect{Slug: plan.Source.Slug, CharacterId: targetId, DateCreated: now}).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&plan.Target).Where("version = ?", read.Version).Updates(map[string]interface{}{
+			"last_updated": now,
+			"version":      read.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return characterModifiedError(read)
+		}
+		plan.Target.LastUpdated = now
+		plan.Target.Version = read.Version + 1
+
+		return tx.Create(&model.CharacterMerge{
+			TargetId:       targetId,
+			SourceId:       sourceId,
+			SourceName:     plan.Source.Name,
+			SourceSlug:     plan.Source.Slug,
+			MovedPhrases:   len(plan.Moved),
+			DroppedPhrases: len(plan.Collisions),
+			MergedBy:       commonContext.ClientID(ctx),
+			DateCreated:    now,
+		}).Error
+	})
+	if err != nil {
+		logger.Error("merging characters", err)
+		return model.MergePlan{}, found, database.TranslateError(err)
+	}
+	if !found {
+		return model.MergePlan{}, false, nil
+	}
+	return plan, true, nil
+}
+
+// moveReports points the reports on content dropped by a merge to the content kept. A reporter has one report
+// per target, so the reports of those who had also reported the content kept are closed instead
+func moveReports(tx *gorm.DB, targetType string, fromId int64, toId int64, characterId int64, now time.Time) error {
+	err := tx.Exec("UPDATE IGNORE reports SET target_id = ?, character_id = ?, last_updated = ? WHERE target_type = ? AND target_id = ?",
+		toId, characterId, now, targetType, fromId).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&model.Report{}).Where("target_type = ? AND target_id = ?", targetType, fromId).
+		Update("character_id", characterId).Error
+	if err != nil {
+		return err
+	}
+	note := fmt.Sprintf("The reporter also reported %s %d, which this one was merged into", targetType, toId)
+	return tx.Model(&model.Report{}).Where("target_type = ? AND target_id = ? AND status = ?", targetType, fromId, model.ReportStatusOpen).
+		Updates(map[string]interface{}{
+			"status":       model.ReportStatusDismissed,
+			"note":         note,
+			"closed_by":    model.ClosedByMerge,
+			"date_closed":  now,
+			"last_updated": now,
+		}).Error
+}
+
+// planMerge reads both characters and their phrases to tell what merging the source into the target does.
+// Returns whether the target is found, and a NotFoundError when the source is not
+func planMerge(db *gorm.DB, targetId int64, sourceId int64) (model.MergePlan, bool, error) {
+	if targetId == sourceId {
+		return model.MergePlan{}, false, customErrors.NewValidationError("source_id", "a character can't be merged into itself")
+	}
+
+	plan := model.MergePlan{}
+	result := db.Where("id = ?", targetId).Find(&plan.Target)
+	if result.RecordNotFound() {
+		return model.MergePlan{}, false, nil
+	}
+	if result.Error != nil {
+		return model.MergePlan{}, false, result.Error
+	}
+	result = db.Where("id = ?", sourceId).Find(&plan.Source)
+	if result.RecordNotFound() {
+		return model.MergePlan{}, true, customErrors.NewNotFoundError(fmt.Sprintf("character %d not found", sourceId))
+	}
+	if result.Error != nil {
+		return model.MergePlan{}, true, result.Error
+	}
+
+	targetPhrases := make([]model.Phrase, 0)
+	if err := db.Where("character_id = ?", targetId).Find(&targetPhrases).Error; err != nil {
+		return model.MergePlan{}, true, err
+	}
+	sourcePhrases := make([]model.Phrase, 0)
+	if err := db.Where("character_id = ?", sourceId).Find(&sourcePhrases).Error; err != nil {
+		return model.MergePlan{}, true, err
+	}
+	moved, collisions, err := phrase.PlanMove(targetPhrases, sourcePhrases)
+	if err != nil {
+		return model.MergePlan{}, true, err
+	}
+	plan.TargetPhrases = len(targetPhrases)
+	plan.Moved = moved
+	plan.Collisions = collisions
+	return plan, true, nil
+}
+
+// checkNameAvailable fails with a ConflictError when a character other than exceptId already goes by the name,
+// either as its name or as an alias. The collation of the name columns makes the comparison ignore case and
+// accents, and locking the rows read keeps concurrent writes from taking the name in between
+func checkNameAvailable(tx *gorm.DB, name string, exceptId int64) error {
+	locked := tx.Set("gorm:query_option", "FOR UPDATE")
+
+	ch := model.Character{}
+	result := locked.Where("name = ? AND id <> ?", name, exceptId).Find(&ch)
+	if result.Error == nil {
+		return customErrors.NewConflictError(fmt.Sprintf("character %d already goes by the name %s", ch.ID, name))
+	}
+	if !result.RecordNotFound() {
+		return result.Error
+	}
+
+	alias := model.Alias{}
+	result = locked.Where("alias = ?", name).Find(&alias)
+	if result.Error == nil {
+		return customErrors.NewConflictError(fmt.Sprintf("%s is already an alias of character %d", name, alias.CharacterId))
+	}
+	if !result.RecordNotFound() {
+		return result.Error
+	}
+	return nil
+}
+
+// uniqueSlug makes a slug out of a character's name that no other character than exceptId goes by, now or
+// before a rename. The character keeps its own slugs when its name gives the same one
+func uniqueSlug(tx *gorm.DB, name string, exceptId int64) (string, error) {
+	return slug.Unique(slug.Make(name, maxSlugLength), "character", func(candidate string) (bool, error) {
+		count := 0
+		if err := tx.Model(&model.Character{}).Where("slug = ? AND id <> ?", candidate, exceptId).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+		err := tx.Model(&model.SlugRedirect{}).Where("slug = ? AND character_id <> ?", candidate, exceptId).Count(&count).Error
+		return count > 0, err
+	})
+}
+
+// redirectSlug keeps the previous slug of a renamed character pointing at it. A redirect from the new slug,
+// left by an earlier rename, is no longer needed
+func redirectSlug(tx *gorm.DB, previous model.Character, newSlug string, now time.Time) error {
+	if err := tx.Where("slug = ?", newSlug).Delete(&model.SlugRedirect{}).Error; err != nil {
+		return err
+	}
+	if previous.Slug == "" {
+		return nil
+	}
+	return tx.Create(&model.SlugRedirect{Slug: previous.Slug, CharacterId: previous.ID, DateCreated: now}).Error
+}
+
+// BackfillSlugs gives a slug to the characters created before they had one. Returns how many got one.
+// Every character is updated in its own transaction, so the timeout applies to each of them
+func (repo DBCharacterRepository) BackfillSlugs(ctx context.Context) (int, error) {
+	var pending []struct {
+		ID   int64
+		Name string
+	}
+	readCtx, cancel := database.WithTimeout(ctx)
+	err := repo.cluster.Writer(readCtx).Table("characters").Select("id, name").Where("slug IS NULL").Scan(&pending).Error
+	cancel()
+	if err != nil {
+		return 0, database.TranslateError(err)
+	}
+
+	for i, ch := range pending {
+		if err := repo.backfillSlug(ctx, ch.ID, ch.Name); err != nil {
+			return i, database.TranslateError(err)
+		}
+	}
+	return len(pending), nil
+}
+
+func (repo DBCharacterRepository) backfillSlug(ctx context.Context, id int64, name string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Writer(ctx)
+
+	return database.WithTransaction(ctx, db, "backfilling character slug", func(tx *gorm.DB) error {
+		characterSlug, err := uniqueSlug(tx, name, id)
+		if err != nil {
+			return err
+		}
+		return tx.Table("characters").Where("id = ? AND slug IS NULL", id).Update("slug", characterSlug).Error
+	})
+}
+
+func characterModifiedError(ch model.Character) error {
+	return customErrors.NewPreconditionFailedError(fmt.Sprintf("character %d was modified, its version is no longer %d", ch.ID, ch.Version))
+}
+
+// translateNameError translates a driver error, giving a duplicate name a meaningful message. Conflicts found
+// by checkNameAvailable already have one
+func translateNameError(err error, name string) error {
+	var conflict customErrors.ConflictError
+	if errors.As(err, &conflict) {
+		return err
+	}
+	err = database.TranslateError(err)
+	if errors.As(err, &conflict) {
+		return customErrors.NewConflictError(fmt.Sprintf("character with name %s already exists", name))
+	}
+	return err
 }
\ No newline at end of file
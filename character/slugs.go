@@ -0,0 +1,88 @@
+package character
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/airabinovich/memequotes_back/slug"
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveCharacter lets the routes with a :character-id take the character's slug instead of its id. The
+// slug is replaced by the id before the handlers run, and a slug the character went by before a rename
+// redirects to the URL with its current slug. A hidden character is only found by moderators, by its id too
+func ResolveCharacter(c *gin.Context) {
+	rest.ErrorWrapper(resolveCharacter, c)
+}
+
+func resolveCharacter(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterSlug := c.Param("character-id")
+	if slug.IsID(characterSlug) {
+		// A character that isn't found is left to the handlers, some of them answer for any id
+		if id, err := strconv.ParseInt(characterSlug, 10, 64); err == nil {
+			ch, found, err := characterRepository.Get(ctx, id)
+			if err != nil {
+				logger.Error("get character by id", err)
+				c.Abort()
+				return err
+			}
+			if found && !visible(ctx, ch) {
+				c.Abort()
+				return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", id))
+			}
+		}
+		c.Next()
+		return nil
+	}
+
+	ch, found, err := characterRepository.GetBySlug(ctx, characterSlug)
+	if err != nil {
+		logger.Error("get character by slug", err)
+		c.Abort()
+		return err
+	}
+	if !found || !visible(ctx, ch) {
+		c.Abort()
+		return rest.NewResourceNotFound(fmt.Sprintf("character %s not found", characterSlug))
+	}
+	if ch.Slug != characterSlug {
+		redirect(c, "/character/"+characterSlug, "/character/"+ch.Slug)
+		return nil
+	}
+
+	setParam(c, "character-id", strconv.FormatInt(ch.ID, 10))
+	c.Next()
+	return nil
+}
+
+// redirect sends the client to the same URL with the prefix replaced. Reads are moved permanently, and other
+// methods get 308 so clients repeat them with their body
+func redirect(c *gin.Context, from string, to string) {
+	location := *c.Request.URL
+	location.Path = to + strings.TrimPrefix(location.Path, from)
+	location.RawPath = ""
+
+	status := http.StatusPermanentRedirect
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+	c.Redirect(status, location.RequestURI())
+	c.Abort()
+}
+
+// setParam replaces the value of a path parameter for the handlers that follow
+func setParam(c *gin.Context, key string, value string) {
+	for i, param := range c.Params {
+		if param.Key == key {
+			c.Params[i].Value = value
+			return
+		}
+	}
+}
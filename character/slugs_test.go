@@ -0,0 +1,114 @@
+package character
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/auth"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestResolveCharacterBySlug(t *testing.T) {
+	t.Log("A character should be found by its slug")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Ricardo Fort", now, now)
+	ch.Slug = "ricardo-fort"
+	characterMockRepo.On("GetBySlug", mock.Anything, "ricardo-fort").Return(ch, true, nil)
+	characterMockRepo.On("Get", mock.Anything, int64(1)).Return(ch, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/ricardo-fort", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id", ResolveCharacter, GetCharacter)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.CharacterResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1), actualResult.ID)
+	assert.Equal(t, "ricardo-fort", actualResult.Slug)
+}
+
+func TestResolveCharacterRedirectsOldSlug(t *testing.T) {
+	t.Log("The slug of a renamed character should redirect to its current slug")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Comandante Fort", now, now)
+	ch.Slug = "comandante-fort"
+	characterMockRepo.On("GetBySlug", mock.Anything, "ricardo-fort").Return(ch, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/ricardo-fort/phrases?page=2", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrases", ResolveCharacter, GetCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/character/comandante-fort/phrases?page=2", w.Header().Get("Location"))
+	characterMockRepo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestResolveCharacterUnknownSlug(t *testing.T) {
+	t.Log("A slug no character goes by should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	characterMockRepo.On("GetBySlug", mock.Anything, "fort").Return(model.Character{}, false, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/fort", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id", ResolveCharacter, GetCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	characterMockRepo.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+}
+
+func TestResolveCharacterHiddenById(t *testing.T) {
+	t.Log("The routes of a hidden character should only be found by moderators, by its id too")
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Comandante Fort", now, now)
+	ch.Hidden = true
+
+	for role, status := range map[string]int{
+		auth.RoleTrusted:   http.StatusNotFound,
+		auth.RoleModerator: http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+
+		resetMocks()
+
+		characterMockRepo.On("Get", mock.Anything, int64(1)).Return(ch, true, nil)
+
+		r := utils.TestRouter()
+		r.Use(func(c *gin.Context) {
+			commonContext.WithRequestContext(commonContext.WithRole(commonContext.RequestContext(c), role), c)
+		})
+		r.GET("/character/:character-id/phrases", ResolveCharacter, func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/character/1/phrases", nil))
+
+		assert.Equal(t, status, w.Code, role)
+	}
+}
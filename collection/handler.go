@@ -0,0 +1,336 @@
+package collection
+
+import (
+	"context"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/phrase"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var collectionRepository repository.CollectionRepository
+
+func Initialize(colRepo repository.CollectionRepository) {
+	collectionRepository = colRepo
+}
+
+// GetCollection returns a collection by its id, when it's public or the client owns it
+func GetCollection(c *gin.Context) {
+	rest.ErrorWrapper(getCollection, c)
+}
+
+func getCollection(c *gin.Context) error {
+	collection, err := readCollection(c)
+	if err != nil {
+		return err
+	}
+	return collectionJSON(c, collection)
+}
+
+// GetCollectionPhrases returns the phrases of a collection in order, like any other list of phrases
+func GetCollectionPhrases(c *gin.Context) {
+	rest.ErrorWrapper(getCollectionPhrases, c)
+}
+
+func getCollectionPhrases(c *gin.Context) error {
+	collection, err := readCollection(c)
+	if err != nil {
+		return err
+	}
+	return collectionPhrasesJSON(c, collection)
+}
+
+// GetSharedCollection returns the collection a share token gives access to, whatever its visibility
+func GetSharedCollection(c *gin.Context) {
+	rest.ErrorWrapper(getSharedCollection, c)
+}
+
+func getSharedCollection(c *gin.Context) error {
+	collection, err := readSharedCollection(c)
+	if err != nil {
+		return err
+	}
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	collection = collection.RatedUpTo(maxRating)
+	return rest.VersionedJSON(c, collection.Version, collection.LastUpdated, model.CollectionResultFromCollection(collection))
+}
+
+// GetSharedCollectionPhrases returns the phrases of a shared collection in order, like any other list of phrases
+func GetSharedCollectionPhrases(c *gin.Context) {
+	rest.ErrorWrapper(getSharedCollectionPhrases, c)
+}
+
+func getSharedCollectionPhrases(c *gin.Context) error {
+	collection, err := readSharedCollection(c)
+	if err != nil {
+		return err
+	}
+	return collectionPhrasesJSON(c, collection)
+}
+
+// GetMyCollections returns the collections of the client wrapped in a json object
+func GetMyCollections(c *gin.Context) {
+	rest.ErrorWrapper(getMyCollections, c)
+}
+
+func getMyCollections(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	collections, err := collectionRepository.GetAllForOwner(ctx, commonContext.ClientID(ctx))
+	if err != nil {
+		logger.Error("get collections of client", err)
+		return err
+	}
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+
+	collectionResults := make([]model.CollectionResult, len(collections))
+	var lastModified time.Time
+	for i, collection := range collections {
+		collectionResults[i] = ownerResult(collection.RatedUpTo(maxRating))
+		lastModified = rest.LatestUpdate(lastModified, collection.LastUpdated)
+	}
+
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
+		"results": collectionResults,
+	})
+}
+
+// SaveCollection saves a new collection of the client
+func SaveCollection(c *gin.Context) {
+	rest.ErrorWrapper(saveCollection, c)
+}
+
+func saveCollection(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	colCmd, err := bindCollectionCommand(c)
+	if err != nil {
+		logger.Error("creating collection bad body format", err)
+		return err
+	}
+
+	collection, err := collectionRepository.Save(ctx, commonContext.ClientID(ctx), colCmd)
+	if err != nil {
+		logger.Error("error creating collection", err)
+		return err
+	}
+
+	c.JSON(http.StatusCreated, ownerResult(collection))
+	return nil
+}
+
+// UpdateCollection renames a collection of the client and changes its visibility
+func UpdateCollection(c *gin.Context) {
+	rest.ErrorWrapper(updateCollection, c)
+}
+
+func updateCollection(c *gin.Context) error {
+	colCmd, err := bindCollectionCommand(c)
+	if err != nil {
+		return err
+	}
+	return modifyCollection(c, "update collection", func(ctx context.Context, id int64, owner string, precondition model.Precondition) (model.Collection, bool, error) {
+		return collectionRepository.Update(ctx, id, owner, colCmd, precondition)
+	})
+}
+
+// AddCollectionPhrase puts a phrase in a collection of the client
+func AddCollectionPhrase(c *gin.Context) {
+	rest.ErrorWrapper(addCollectionPhrase, c)
+}
+
+func addCollectionPhrase(c *gin.Context) error {
+	var entryCmd model.CollectionEntryCommand
+	if err := c.ShouldBindJSON(&entryCmd); err != nil {
+		return rest.NewValidationError(err)
+	}
+	return modifyCollection(c, "add phrase to collection", func(ctx context.Context, id int64, owner string, precondition model.Precondition) (model.Collection, bool, error) {
+		return collectionRepository.AddPhrase(ctx, id, owner, entryCmd, precondition)
+	})
+}
+
+// RemoveCollectionPhrase takes a phrase out of a collection of the client
+func RemoveCollectionPhrase(c *gin.Context) {
+	rest.ErrorWrapper(removeCollectionPhrase, c)
+}
+
+func removeCollectionPhrase(c *gin.Context) error {
+	phraseId, err := strconv.ParseInt(c.Param("phrase-id"), 10, 64)
+	if err != nil {
+		return rest.NewBadRequest(err.Error())
+	}
+	return modifyCollection(c, "remove phrase from collection", func(ctx context.Context, id int64, owner string, precondition model.Precondition) (model.Collection, bool, error) {
+		return collectionRepository.RemovePhrase(ctx, id, owner, phraseId, precondition)
+	})
+}
+
+// ReorderCollection puts the phrases of a collection of the client in a new order
+func ReorderCollection(c *gin.Context) {
+	rest.ErrorWrapper(reorderCollection, c)
+}
+
+func reorderCollection(c *gin.Context) error {
+	var orderCmd model.CollectionOrderCommand
+	if err := c.ShouldBindJSON(&orderCmd); err != nil {
+		return rest.NewValidationError(err)
+	}
+	return modifyCollection(c, "reorder collection", func(ctx context.Context, id int64, owner string, precondition model.Precondition) (model.Collection, bool, error) {
+		return collectionRepository.Reorder(ctx, id, owner, orderCmd.PhraseIds, precondition)
+	})
+}
+
+// DeleteCollection removes a collection of the client
+func DeleteCollection(c *gin.Context) {
+	rest.ErrorWrapper(deleteCollection, c)
+}
+
+func deleteCollection(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("collection-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting collection with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	precondition, err := rest.IfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	if err := collectionRepository.Delete(ctx, id, commonContext.ClientID(ctx), precondition); err != nil {
+		logger.Error("error deleting collection", err)
+		return err
+	}
+
+	c.Status(http.StatusGone)
+	return nil
+}
+
+// modifyCollection runs a change to the collection of the request on behalf of the client, and responds with
+// the changed collection
+func modifyCollection(c *gin.Context, operation string,
+	change func(ctx context.Context, id int64, owner string, precondition model.Precondition) (model.Collection, bool, error)) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("collection-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting collection with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	precondition, err := rest.IfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	collection, found, err := change(ctx, id, commonContext.ClientID(ctx), precondition)
+	if err != nil {
+		logger.Error(operation, err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("collection %d not found", id))
+	}
+
+	return rest.VersionedJSON(c, collection.Version, collection.LastUpdated, ownerResult(collection))
+}
+
+// readCollection gets the collection of the request. Private collections of other clients are not found
+func readCollection(c *gin.Context) (model.Collection, error) {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("collection-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting collection with non-numeric id", err)
+		return model.Collection{}, rest.NewBadRequest(err.Error())
+	}
+
+	collection, found, err := collectionRepository.Get(ctx, id)
+	if err != nil {
+		logger.Error("get collection by id", err)
+		return model.Collection{}, err
+	}
+	if !found || (collection.Visibility != model.CollectionPublic && collection.Owner != commonContext.ClientID(ctx)) {
+		return model.Collection{}, rest.NewResourceNotFound(fmt.Sprintf("collection %d not found", id))
+	}
+	return collection, nil
+}
+
+func readSharedCollection(c *gin.Context) (model.Collection, error) {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	collection, found, err := collectionRepository.GetByShareToken(ctx, c.Param("share-token"))
+	if err != nil {
+		logger.Error("get collection by share token", err)
+		return model.Collection{}, err
+	}
+	if !found {
+		return model.Collection{}, rest.NewResourceNotFound("shared collection not found")
+	}
+	return collection, nil
+}
+
+func bindCollectionCommand(c *gin.Context) (model.CollectionCommand, error) {
+	var colCmd model.CollectionCommand
+	if err := c.ShouldBindJSON(&colCmd); err != nil {
+		return model.CollectionCommand{}, rest.NewValidationError(err)
+	}
+	colCmd.Name = strings.TrimSpace(colCmd.Name)
+	if colCmd.Name == "" {
+		return model.CollectionCommand{}, rest.NewBadRequest("name must not be empty")
+	}
+	return colCmd, nil
+}
+
+func collectionJSON(c *gin.Context, collection model.Collection) error {
+	ctx := commonContext.RequestContext(c)
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	collection = collection.RatedUpTo(maxRating)
+	result := model.CollectionResultFromCollection(collection)
+	if collection.Owner == commonContext.ClientID(ctx) {
+		result = ownerResult(collection)
+	}
+	return rest.VersionedJSON(c, collection.Version, collection.LastUpdated, result)
+}
+
+// ownerResult shows a collection to its owner, who can share it
+func ownerResult(collection model.Collection) model.CollectionResult {
+	result := model.CollectionResultFromCollection(collection)
+	result.ShareToken = collection.ShareToken
+	return result
+}
+
+func collectionPhrasesJSON(c *gin.Context, collection model.Collection) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	phrases, err := collectionRepository.GetPhrases(ctx, collection.ID)
+	if err != nil {
+		logger.Error("get phrases of collection", err)
+		return err
+	}
+	return phrase.PhrasesJSON(c, phrases)
+}
@@ -0,0 +1,360 @@
+package collection
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var collectionMockRepo collectionsMockRepository
+
+const owner = "key:fort"
+
+func TestSaveCollectionCreated(t *testing.T) {
+	t.Log("Saving a collection should return it to its owner with the share token and Created")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	collection := model.NewCollection(1, owner, "for the group chat", model.CollectionPrivate, now, now)
+	collection.ShareToken = "q3Xl0m2fR1mYJx2c7Pz9mA"
+	collectionMockRepo.On("Save", mock.Anything, owner, model.NewCollectionCommand("for the group chat", "")).Return(collection, nil)
+
+	body, _ := json.Marshal(model.NewCollectionCommand(" for the group chat ", ""))
+	req := httptest.NewRequest(http.MethodPost, "/collection", bytes.NewBuffer(body))
+
+	r := testRouter(owner)
+	r.POST("/collection", SaveCollection)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.CollectionResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "private", actualResult.Visibility)
+	assert.Equal(t, "q3Xl0m2fR1mYJx2c7Pz9mA", actualResult.ShareToken)
+}
+
+func TestSaveCollectionBadVisibility(t *testing.T) {
+	t.Log("A visibility other than public or private should return Bad Request")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	body, _ := json.Marshal(model.NewCollectionCommand("work-safe", "friends"))
+	req := httptest.NewRequest(http.MethodPost, "/collection", bytes.NewBuffer(body))
+
+	r := testRouter(owner)
+	r.POST("/collection", SaveCollection)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetPrivateCollectionOfAnotherClient(t *testing.T) {
+	t.Log("A private collection should not be found by other clients, and be found by its owner")
+
+	resetMocks()
+
+	now := time.Now()
+	collection := model.NewCollection(1, owner, "work-safe", model.CollectionPrivate, now, now)
+	collection.ShareToken = "q3Xl0m2fR1mYJx2c7Pz9mA"
+	collectionMockRepo.On("Get", mock.Anything, int64(1)).Return(collection, true, nil)
+
+	w := httptest.NewRecorder()
+	r := testRouter("key:other")
+	r.GET("/collection/:collection-id", GetCollection)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/collection/1", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r = testRouter(owner)
+	r.GET("/collection/:collection-id", GetCollection)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/collection/1", nil))
+
+	actualResult := model.CollectionResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "q3Xl0m2fR1mYJx2c7Pz9mA", actualResult.ShareToken)
+}
+
+func TestGetPublicCollectionHidesShareToken(t *testing.T) {
+	t.Log("A public collection should be found by other clients, without its share token")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	collection := model.NewCollection(1, owner, "for the group chat", model.CollectionPublic, now, now)
+	collection.ShareToken = "q3Xl0m2fR1mYJx2c7Pz9mA"
+	collectionMockRepo.On("Get", mock.Anything, int64(1)).Return(collection, true, nil)
+
+	r := testRouter("192.0.2.1")
+	r.GET("/collection/:collection-id", GetCollection)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/collection/1", nil))
+
+	actualResult := model.CollectionResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, actualResult.ShareToken)
+}
+
+func TestGetSharedCollectionUpToMaxRating(t *testing.T) {
+	t.Log("Phrases rated above ?max_rating= should be left out of the phrase ids of a shared collection")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	collection := model.NewCollection(1, owner, "work-safe", model.CollectionPrivate, now, now)
+	collection.Entries = []model.CollectionEntry{
+		{CollectionId: 1, PhraseId: 4, Position: 1, Rating: model.RatingSafe},
+		{CollectionId: 1, PhraseId: 2, Position: 2, Rating: model.RatingExplicit},
+	}
+	collectionMockRepo.On("GetByShareToken", mock.Anything, "q3Xl0m2fR1mYJx2c7Pz9mA").Return(collection, true, nil)
+
+	r := testRouter("192.0.2.1")
+	r.GET("/shared/collection/:share-token", GetSharedCollection)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shared/collection/q3Xl0m2fR1mYJx2c7Pz9mA?max_rating=safe", nil))
+
+	actualResult := model.CollectionResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []int64{4}, actualResult.PhraseIds)
+}
+
+func TestGetSharedCollectionPhrases(t *testing.T) {
+	t.Log("The phrases of a shared private collection should be listed in order like any other list of phrases")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	collection := model.NewCollection(1, owner, "work-safe", model.CollectionPrivate, now, now)
+	phrases := []model.Phrase{
+		model.NewPhrase(4, 2, nil, "miameeee", now, now),
+		model.NewPhrase(1, 1, nil, "el tren de Ricardo Fort pasa una sola vez en la vida", now, now),
+	}
+	collectionMockRepo.On("GetByShareToken", mock.Anything, "q3Xl0m2fR1mYJx2c7Pz9mA").Return(collection, true, nil)
+	collectionMockRepo.On("GetPhrases", mock.Anything, int64(1)).Return(phrases, nil)
+
+	r := testRouter("192.0.2.1")
+	r.GET("/shared/collection/:share-token/phrases", GetSharedCollectionPhrases)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shared/collection/q3Xl0m2fR1mYJx2c7Pz9mA/phrases", nil))
+
+	actualResult := make(map[string][]model.PhraseResult)
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, actualResult["results"], 2)
+	assert.Equal(t, int64(4), actualResult["results"][0].ID)
+	assert.Equal(t, int64(1), actualResult["results"][1].ID)
+}
+
+func TestAddCollectionPhraseOk(t *testing.T) {
+	t.Log("Adding a phrase should return the collection with its new version")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	collection := model.NewCollection(1, owner, "work-safe", model.CollectionPrivate, now, now)
+	collection.Entries = []model.CollectionEntry{{CollectionId: 1, PhraseId: 4, Position: 1, DateAdded: now}}
+	collection.Version = 2
+	entryCmd := model.NewCollectionEntryCommand(4, 0)
+	collectionMockRepo.On("AddPhrase", mock.Anything, int64(1), owner, entryCmd, model.NewPrecondition(1)).Return(collection, true, nil)
+
+	body, _ := json.Marshal(entryCmd)
+	req := httptest.NewRequest(http.MethodPost, "/collection/1/phrases", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"v1"`)
+
+	r := testRouter(owner)
+	r.POST("/collection/:collection-id/phrases", AddCollectionPhrase)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.CollectionResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v2"`, w.Header().Get("ETag"))
+	assert.Equal(t, []int64{4}, actualResult.PhraseIds)
+}
+
+func TestAddCollectionPhraseTwice(t *testing.T) {
+	t.Log("Adding a phrase the collection already has should return Conflict")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	collectionMockRepo.On("AddPhrase", mock.Anything, int64(1), owner, mock.Anything, mock.Anything).
+		Return(model.Collection{}, true, customErrors.NewConflictError("collection 1 already has phrase 4"))
+
+	body, _ := json.Marshal(model.NewCollectionEntryCommand(4, 0))
+	req := httptest.NewRequest(http.MethodPost, "/collection/1/phrases", bytes.NewBuffer(body))
+
+	r := testRouter(owner)
+	r.POST("/collection/:collection-id/phrases", AddCollectionPhrase)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestUpdateCollectionOfAnotherClient(t *testing.T) {
+	t.Log("Renaming the public collection of another client should return Forbidden")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	collectionMockRepo.On("Update", mock.Anything, int64(1), "key:other", model.NewCollectionCommand("mine now", ""), mock.Anything).
+		Return(model.Collection{}, true, customErrors.NewForbiddenError("collection 1 belongs to another client"))
+
+	body, _ := json.Marshal(model.NewCollectionCommand("mine now", ""))
+	req := httptest.NewRequest(http.MethodPatch, "/collection/1", bytes.NewBuffer(body))
+
+	r := testRouter("key:other")
+	r.PATCH("/collection/:collection-id", UpdateCollection)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestDeleteCollectionShouldReturnGone(t *testing.T) {
+	t.Log("Delete collection should return Gone")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	collectionMockRepo.On("Delete", mock.Anything, int64(1), owner, mock.Anything).Return(nil)
+
+	r := testRouter(owner)
+	r.DELETE("/collection/:collection-id", DeleteCollection)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/collection/1", nil))
+
+	assert.Equal(t, http.StatusGone, w.Code)
+}
+
+// testRouter makes requests on behalf of a client, like the API key and client id middlewares do
+func testRouter(clientID string) *gin.Engine {
+	r := utils.TestRouter()
+	r.Use(func(c *gin.Context) {
+		commonContext.WithRequestContext(commonContext.WithClientID(commonContext.RequestContext(c), clientID), c)
+	})
+	return r
+}
+
+func resetMocks() {
+	collectionMockRepo = collectionsMockRepository{}
+	collectionRepository = &collectionMockRepo
+}
+
+type collectionsMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *collectionsMockRepository) Get(ctx context.Context, id int64) (model.Collection, bool, error) {
+	args := repoMock.Called(ctx, id)
+	return collectionFoundArgs(args)
+}
+
+func (repoMock *collectionsMockRepository) GetByShareToken(ctx context.Context, token string) (model.Collection, bool, error) {
+	args := repoMock.Called(ctx, token)
+	return collectionFoundArgs(args)
+}
+
+func (repoMock *collectionsMockRepository) GetAllForOwner(ctx context.Context, owner string) ([]model.Collection, error) {
+	args := repoMock.Called(ctx, owner)
+
+	collections, ok := args.Get(0).([]model.Collection)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return collections, args.Error(1)
+}
+
+func (repoMock *collectionsMockRepository) GetPhrases(ctx context.Context, id int64) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, id)
+
+	phrases, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return phrases, args.Error(1)
+}
+
+func (repoMock *collectionsMockRepository) Save(ctx context.Context, owner string, colCmd model.CollectionCommand) (model.Collection, error) {
+	args := repoMock.Called(ctx, owner, colCmd)
+
+	collection, ok := args.Get(0).(model.Collection)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return collection, args.Error(1)
+}
+
+func (repoMock *collectionsMockRepository) Update(ctx context.Context, id int64, owner string, colCmd model.CollectionCommand, precondition model.Precondition) (model.Collection, bool, error) {
+	args := repoMock.Called(ctx, id, owner, colCmd, precondition)
+	return collectionFoundArgs(args)
+}
+
+func (repoMock *collectionsMockRepository) Delete(ctx context.Context, id int64, owner string, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, id, owner, precondition)
+	return args.Error(0)
+}
+
+func (repoMock *collectionsMockRepository) AddPhrase(ctx context.Context, id int64, owner string, entryCmd model.CollectionEntryCommand, precondition model.Precondition) (model.Collection, bool, error) {
+	args := repoMock.Called(ctx, id, owner, entryCmd, precondition)
+	return collectionFoundArgs(args)
+}
+
+func (repoMock *collectionsMockRepository) RemovePhrase(ctx context.Context, id int64, owner string, phraseId int64, precondition model.Precondition) (model.Collection, bool, error) {
+	args := repoMock.Called(ctx, id, owner, phraseId, precondition)
+	return collectionFoundArgs(args)
+}
+
+func (repoMock *collectionsMockRepository) Reorder(ctx context.Context, id int64, owner string, phraseIds []int64, precondition model.Precondition) (model.Collection, bool, error) {
+	args := repoMock.Called(ctx, id, owner, phraseIds, precondition)
+	return collectionFoundArgs(args)
+}
+
+func collectionFoundArgs(args mock.Arguments) (model.Collection, bool, error) {
+	collection, ok := args.Get(0).(model.Collection)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return collection, found, args.Error(2)
+}
@@ -0,0 +1,410 @@
+package collection
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// shareTokenBytes is how much randomness goes in a share token, enough that it can't be guessed
+const shareTokenBytes = 16
+
+type DBCollectionRepository struct {
+	cluster *database.Cluster
+}
+
+func NewDBCollectionRepository(cluster *database.Cluster) DBCollectionRepository {
+	return DBCollectionRepository{
+		cluster: cluster,
+	}
+}
+
+// withEntries loads the entries of the collections read, in order
+func withEntries(db *gorm.DB) *gorm.DB {
+	return db.Preload("Entries", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position, date_added")
+	})
+}
+
+// rateEntries reads the rating of the phrases in the collections, so they can be left out of the clients that
+// don't take them
+func rateEntries(db *gorm.DB, collections ...*model.Collection) error {
+	var ids []int64
+	for _, collection := range collections {
+		for _, entry := range collection.Entries {
+			ids = append(ids, entry.PhraseId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var rated []struct {
+		ID     int64
+		Rating string
+	}
+	if err := db.Model(&model.Phrase{}).Select("id, rating").Where("id IN (?)", ids).Scan(&rated).Error; err != nil {
+		return err
+	}
+	ratings := make(map[int64]string, len(rated))
+	for _, phrase := range rated {
+		ratings[phrase.ID] = phrase.Rating
+	}
+	for _, collection := range collections {
+		for i, entry := range collection.Entries {
+			collection.Entries[i].Rating = ratings[entry.PhraseId]
+		}
+	}
+	return nil
+}
+
+func (repo DBCollectionRepository) Get(ctx context.Context, id int64) (model.Collection, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Collection with id %d", id))
+	return repo.getWhere(ctx, "id = ?", id)
+}
+
+func (repo DBCollectionRepository) GetByShareToken(ctx context.Context, token string) (model.Collection, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug("Getting Collection by share token")
+	return repo.getWhere(ctx, "share_token = ?", token)
+}
+
+func (repo DBCollectionRepository) getWhere(ctx context.Context, query string, value interface{}) (model.Collection, bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	collection := model.Collection{}
+	notFound := false
+	err := database.RetryRead(ctx, "getting collection", func() error {
+		result := withEntries(db).Where(query, value).Find(&collection)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		return rateEntries(db, &collection)
+	})
+	if err != nil {
+		return model.Collection{}, false, database.TranslateError(err)
+	}
+	return collection, !notFound, nil
+}
+
+func (repo DBCollectionRepository) GetAllForOwner(ctx context.Context, owner string) ([]model.Collection, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Collections of %s", owner))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	collections := make([]model.Collection, 0)
+	err := database.RetryRead(ctx, "getting collections of owner", func() error {
+		if err := withEntries(db).Where("owner = ?", owner).Order("id").Find(&collections).Error; err != nil {
+			return err
+		}
+		rated := make([]*model.Collection, len(collections))
+		for i := range collections {
+			rated[i] = &collections[i]
+		}
+		return rateEntries(db, rated...)
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return collections, nil
+}
+
+func (repo DBCollectionRepository) GetPhrases(ctx context.Context, id int64) ([]model.Phrase, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Phrases of Collection %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	phrases := make([]model.Phrase, 0)
+	err := database.RetryRead(ctx, "getting phrases of collection", func() error {
+		return db.Joins("JOIN collection_phrases ON collection_phrases.phrase_id = phrases.id").
+			Joins(model.JoinVisibleCharacters).
+			Where("collection_phrases.collection_id = ? AND phrases.status = ?", id, model.PhraseStatusApproved).
+			Order("collection_phrases.position, collection_phrases.date_added").Find(&phrases).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return phrases, nil
+}
+
+func (repo DBCollectionRepository) Save(ctx context.Context, owner string, colCmd model.CollectionCommand) (model.Collection, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Creating Collection %s of %s", colCmd.Name, owner))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Writer(ctx)
+
+	token, err := newShareToken()
+	if err != nil {
+		return model.Collection{}, err
+	}
+	visibility := colCmd.Visibility
+	if visibility == "" {
+		visibility = model.CollectionPrivate
+	}
+	now := time.Now()
+	collection := model.NewCollection(0, owner, colCmd.Name, visibility, now, now)
+	collection.ShareToken = token
+	collection.Entries = []model.CollectionEntry{}
+	collection.Version = 1
+
+	if err := db.Create(&collection).Error; err != nil {
+		logger.Error("creating collection", err)
+		return model.Collection{}, database.TranslateError(err)
+	}
+	return collection, nil
+}
+
+func (repo DBCollectionRepository) Update(ctx context.Context, id int64, owner string, colCmd model.CollectionCommand, precondition model.Precondition) (model.Collection, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Updating Collection with id %d", id))
+
+	return repo.modify(ctx, "updating collection", id, owner, precondition, func(tx *gorm.DB, collection *model.Collection) (map[string]interface{}, error) {
+		changes := map[string]interface{}{"name": colCmd.Name}
+		collection.Name = colCmd.Name
+		if colCmd.Visibility != "" {
+			changes["visibility"] = colCmd.Visibility
+			collection.Visibility = colCmd.Visibility
+		}
+		return changes, nil
+	})
+}
+
+func (repo DBCollectionRepository) Delete(ctx context.Context, id int64, owner string, precondition model.Precondition) error {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Deleting Collection with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "deleting collection", func(tx *gorm.DB) error {
+		collection, found, err := findOwned(tx, id, owner)
+		if err != nil || !found {
+			return err
+		}
+		if !precondition.Allows(collection.Version) {
+			return collectionModifiedError(collection)
+		}
+
+		if err := tx.Where("collection_id = ?", id).Delete(&model.CollectionEntry{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("version = ?", collection.Version).Delete(&collection)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return collectionModifiedError(collection)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("deleting collection", err)
+		return database.TranslateError(err)
+	}
+	return nil
+}
+
+func (repo DBCollectionRepository) AddPhrase(ctx context.Context, id int64, owner string, entryCmd model.CollectionEntryCommand, precondition model.Precondition) (model.Collection, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Adding Phrase %d to Collection %d", entryCmd.PhraseId, id))
+
+	return repo.modify(ctx, "adding phrase to collection", id, owner, precondition, func(tx *gorm.DB, collection *model.Collection) (map[string]interface{}, error) {
+		for _, entry := range collection.Entries {
+			if entry.PhraseId == entryCmd.PhraseId {
+				return nil, customErrors.NewConflictError(fmt.Sprintf("collection %d already has phrase %d", id, entryCmd.PhraseId))
+			}
+		}
+		result := tx.Where("id = ? AND status = ?", entryCmd.PhraseId, model.PhraseStatusApproved).Find(&model.Phrase{})
+		if result.RecordNotFound() {
+			return nil, customErrors.NewNotFoundError(fmt.Sprintf("phrase %d not found", entryCmd.PhraseId))
+		}
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		// The phrase takes the position of the entry it goes before, which moves down with the ones after it
+		entries := collection.Entries
+		index := len(entries)
+		position := 1
+		if len(entries) > 0 {
+			position = entries[len(entries)-1].Position + 1
+		}
+		if entryCmd.Position > 0 && entryCmd.Position <= len(entries) {
+			index = entryCmd.Position - 1
+			position = entries[index].Position
+			err := tx.Model(&model.CollectionEntry{}).Where("collection_id = ? AND position >= ?", id, position).
+				Update("position", gorm.Expr("position + 1")).Error
+			if err != nil {
+				return nil, err
+			}
+			for i := index; i < len(entries); i++ {
+				entries[i].Position++
+			}
+		}
+
+		entry := model.CollectionEntry{CollectionId: id, PhraseId: entryCmd.PhraseId, Position: position, DateAdded: time.Now()}
+		if err := tx.Create(&entry).Error; err != nil {
+			return nil, err
+		}
+		entries = append(entries, model.CollectionEntry{})
+		copy(entries[index+1:], entries[index:])
+		entries[index] = entry
+		collection.Entries = entries
+		return map[string]interface{}{}, nil
+	})
+}
+
+func (repo DBCollectionRepository) RemovePhrase(ctx context.Context, id int64, owner string, phraseId int64, precondition model.Precondition) (model.Collection, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Removing Phrase %d from Collection %d", phraseId, id))
+
+	return repo.modify(ctx, "removing phrase from collection", id, owner, precondition, func(tx *gorm.DB, collection *model.Collection) (map[string]interface{}, error) {
+		result := tx.Where("collection_id = ? AND phrase_id = ?", id, phraseId).Delete(&model.CollectionEntry{})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, customErrors.NewNotFoundError(fmt.Sprintf("collection %d doesn't have phrase %d", id, phraseId))
+		}
+
+		entries := make([]model.CollectionEntry, 0, len(collection.Entries))
+		for _, entry := range collection.Entries {
+			if entry.PhraseId != phraseId {
+				entries = append(entries, entry)
+			}
+		}
+		collection.Entries = entries
+		return map[string]interface{}{}, nil
+	})
+}
+
+func (repo DBCollectionRepository) Reorder(ctx context.Context, id int64, owner string, phraseIds []int64, precondition model.Precondition) (model.Collection, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Reordering Collection %d", id))
+
+	return repo.modify(ctx, "reordering collection", id, owner, precondition, func(tx *gorm.DB, collection *model.Collection) (map[string]interface{}, error) {
+		entries := make(map[int64]model.CollectionEntry, len(collection.Entries))
+		for _, entry := range collection.Entries {
+			entries[entry.PhraseId] = entry
+		}
+		if len(phraseIds) != len(entries) {
+			return nil, customErrors.NewValidationError("phrase_ids", "phrase_ids must list every phrase of the collection once")
+		}
+
+		reordered := make([]model.CollectionEntry, len(phraseIds))
+		for i, phraseId := range phraseIds {
+			entry, ok := entries[phraseId]
+			if !ok {
+				return nil, customErrors.NewValidationError("phrase_ids", "phrase_ids must list every phrase of the collection once")
+			}
+			delete(entries, phraseId)
+
+			entry.Position = i + 1
+			err := tx.Model(&model.CollectionEntry{}).Where("collection_id = ? AND phrase_id = ?", id, phraseId).
+				Update("position", entry.Position).Error
+			if err != nil {
+				return nil, err
+			}
+			reordered[i] = entry
+		}
+		collection.Entries = reordered
+		return map[string]interface{}{}, nil
+	})
+}
+
+// modify runs a change to a collection of the owner in a transaction, and stores the columns it changes with
+// a new version. The collection is locked, so concurrent changes to its entries don't mix up their positions
+func (repo DBCollectionRepository) modify(ctx context.Context, operation string, id int64, owner string, precondition model.Precondition,
+	change func(tx *gorm.DB, collection *model.Collection) (map[string]interface{}, error)) (model.Collection, bool, error) {
+	logger := commonContext.Logger(ctx)
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	collection := model.Collection{}
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), operation, func(tx *gorm.DB) error {
+		var err error
+		collection, found, err = findOwned(withEntries(tx.Set("gorm:query_option", "FOR UPDATE")), id, owner)
+		if err != nil || !found {
+			return err
+		}
+		if !precondition.Allows(collection.Version) {
+			return collectionModifiedError(collection)
+		}
+
+		read := collection
+		changes, err := change(tx, &collection)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		changes["last_updated"] = now
+		changes["version"] = read.Version + 1
+		if err := tx.Model(&model.Collection{}).Where("id = ?", id).Updates(changes).Error; err != nil {
+			return err
+		}
+
+		collection.LastUpdated = now
+		collection.Version = read.Version + 1
+		return nil
+	})
+	if err != nil {
+		logger.Error(operation, err)
+		return model.Collection{}, found, database.TranslateError(err)
+	}
+	if !found {
+		return model.Collection{}, false, nil
+	}
+	return collection, true, nil
+}
+
+// findOwned reads a collection a client is changing. Other clients' private collections are not found, and
+// their public ones are forbidden
+func findOwned(tx *gorm.DB, id int64, owner string) (model.Collection, bool, error) {
+	collection := model.Collection{}
+	result := tx.Where("id = ?", id).Find(&collection)
+	if result.RecordNotFound() {
+		return model.Collection{}, false, nil
+	}
+	if result.Error != nil {
+		return model.Collection{}, false, result.Error
+	}
+	if collection.Owner != owner {
+		if collection.Visibility != model.CollectionPublic {
+			return model.Collection{}, false, nil
+		}
+		return model.Collection{}, true, customErrors.NewForbiddenError(fmt.Sprintf("collection %d belongs to another client", id))
+	}
+	return collection, true, nil
+}
+
+// newShareToken makes a random token, safe to put in a URL
+func newShareToken() (string, error) {
+	token := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func collectionModifiedError(collection model.Collection) error {
+	return customErrors.NewPreconditionFailedError(fmt.Sprintf("collection %d was modified, its version is no longer %d", collection.ID, collection.Version))
+}
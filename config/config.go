@@ -0,0 +1,512 @@
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-akka/configuration"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyRoles and ContentRatings are the values the API keys may be given. The packages that read the config
+// define what they mean, from the least to the most privileged role and from the mildest to the strongest rating
+var (
+	APIKeyRoles    = []string{"user", "trusted", "moderator", "admin"}
+	ContentRatings = []string{"safe", "mature", "explicit"}
+)
+
+// Config holds project main configuration
+type Config struct {
+	Environment  string       `json:"environment"`
+	LogConfig    LogConfig    `json:"log"`
+	Server       ServerConfig `json:"server"`
+	DB           DBConfig     `json:"db"`
+	Errors       ErrorsConfig `json:"errors"`
+	Reload       ReloadConfig `json:"reload"`
+	RateLimit    RateLimit    `json:"rate_limit"`
+	CORS         CORSConfig   `json:"cors"`
+	CacheControl CacheControl `json:"cache_control"`
+	Concurrency  Concurrency  `json:"concurrency"`
+	Idempotency  Idempotency  `json:"idempotency"`
+	Duplicates   Duplicates   `json:"duplicates"`
+	Cache        CacheConfig  `json:"cache"`
+	Trending     Trending     `json:"trending"`
+	Filter       Filter       `json:"content_filter"`
+	Reports      Reports      `json:"reports"`
+	Auth         AuthConfig   `json:"auth"`
+	Features     Features     `json:"features"`
+}
+
+// LogConfig represents main configuration for logging
+type LogConfig struct {
+	FilePath string `json:"file_path"`
+	Level    string `json:"level"`
+	MaxAge   int    `json:"max_age"` // It's express in days. How many days we keep the logs
+}
+
+// ServerConfig represents the configuration of the HTTP listeners
+type ServerConfig struct {
+	Port              int           `json:"port"`
+	ReadTimeout       time.Duration `json:"read_timeout"`
+	ReadHeaderTimeout time.Duration `json:"read_header_timeout"`
+	WriteTimeout      time.Duration `json:"write_timeout"`
+	IdleTimeout       time.Duration `json:"idle_timeout"`
+	ShutdownTimeout   time.Duration `json:"shutdown_timeout"` // How long the requests in flight have to be answered on shutdown
+	MaxHeaderBytes    int           `json:"max_header_bytes"`
+	HTTP2             bool          `json:"http2"`
+	UnixSocket        string        `json:"unix_socket"`
+	TLS               TLSConfig     `json:"tls"`
+}
+
+// TLSConfig represents the certificate used by the TCP listener. TLS is disabled when cert or key are empty
+type TLSConfig struct {
+	Cert           string        `json:"cert"`
+	Key            string        `json:"key"`
+	ReloadInterval time.Duration `json:"reload_interval"`
+}
+
+// Enabled tells whether the TCP listener serves HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.Cert != "" && c.Key != ""
+}
+
+// DBConfig represents the database connection
+type DBConfig struct {
+	Host            string        `json:"host"`
+	Port            int           `json:"port"`
+	User            string        `json:"user"`
+	Password        string        `json:"password"`
+	Name            string        `json:"name"`
+	MaxOpenConns    int           `json:"max_open_conns"`
+	MaxIdleConns    int           `json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	DialTimeout     time.Duration `json:"dial_timeout"`
+	ReadTimeout     time.Duration `json:"read_timeout"`
+	WriteTimeout    time.Duration `json:"write_timeout"`
+	RequestTimeout  time.Duration `json:"request_timeout"` // Database work allowed per request. 0 disables it
+
+	Replicas             []string      `json:"replicas"` // host:port of every read replica
+	ReplicaCheckInterval time.Duration `json:"replica_check_interval"`
+	ReadYourWritesWindow time.Duration `json:"read_your_writes_window"` // How long a client reads from the primary after writing
+
+	Retry RetryConfig `json:"retry"`
+}
+
+// RetryConfig represents how transient database errors are retried. Backoff doubles on every attempt
+type RetryConfig struct {
+	ConnectAttempts int           `json:"connect_attempts"` // Attempts to connect on startup
+	Attempts        int           `json:"attempts"`         // Attempts of idempotent reads and deadlocked transactions
+	InitialBackoff  time.Duration `json:"initial_backoff"`
+	MaxBackoff      time.Duration `json:"max_backoff"`
+}
+
+// ErrorsConfig represents how errors are rendered to clients
+type ErrorsConfig struct {
+	Format          string `json:"format"` // legacy or problem
+	ProblemTypeBase string `json:"problem_type_base"`
+}
+
+// ReloadConfig represents how configuration changes are picked up at runtime
+type ReloadConfig struct {
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"interval"` // How often the configuration files are checked for changes
+}
+
+// RateLimit represents the requests allowed per client, as a token bucket
+type RateLimit struct {
+	Enabled           bool    `json:"enabled"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// CORSConfig represents the cross-origin requests allowed by browsers
+type CORSConfig struct {
+	Enabled        bool          `json:"enabled"`
+	AllowedOrigins []string      `json:"allowed_origins"`
+	AllowedMethods []string      `json:"allowed_methods"`
+	AllowedHeaders []string      `json:"allowed_headers"`
+	ExposedHeaders []string      `json:"exposed_headers"`
+	MaxAge         time.Duration `json:"max_age"`
+}
+
+// AllowsOrigin tells whether requests from an origin are allowed. "*" allows any origin
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	return contains(c.AllowedOrigins, "*") || contains(c.AllowedOrigins, origin)
+}
+
+// Concurrency represents how concurrent writes to the same resource are handled
+type Concurrency struct {
+	RequireIfMatch bool `json:"require_if_match"` // Reject updates and deletes that don't send If-Match
+}
+
+// Idempotency represents how long the responses to requests with an Idempotency-Key are kept for replays
+type Idempotency struct {
+	TTL     time.Duration `json:"ttl"`
+	MaxKeys int           `json:"max_keys"` // Requests with a new key are not stored past this count
+}
+
+// Duplicates represents how similar two phrases of a character must be to be suspected duplicates
+type Duplicates struct {
+	SimilarityThreshold float64 `json:"similarity_threshold"` // From 0, anything, to 1, only exact duplicates
+}
+
+// CacheConfig represents the in-process cache of repository reads
+type CacheConfig struct {
+	Enabled    bool          `json:"enabled"`
+	MaxEntries int           `json:"max_entries"` // The least recently used entries are evicted past this size
+	TTL        time.Duration `json:"ttl"`
+}
+
+// Trending represents how views and shares of phrases are counted for the trending phrases
+type Trending struct {
+	FlushInterval time.Duration `json:"flush_interval"`  // How often the counts are written to the database
+	BatchSize     int           `json:"batch_size"`      // Counts are written earlier when this many are pending
+	QueueSize     int           `json:"queue_size"`      // Events waiting to be counted. Events past it are dropped
+	CacheTTL      time.Duration `json:"cache_ttl"`       // How long the trending phrases are reused
+	BotUserAgents []string      `json:"bot_user_agents"` // Parts of the user agents not counted, ignoring case
+	BotAPIKeys    []string      `json:"bot_api_keys"`    // Names of the API keys not counted
+}
+
+// IsBot tells whether a request with a user agent, made with the named API key, is not counted. Requests
+// without a user agent are not counted either
+func (t Trending) IsBot(userAgent string, apiKeyName string) bool {
+	if userAgent == "" || (apiKeyName != "" && contains(t.BotAPIKeys, apiKeyName)) {
+		return true
+	}
+	userAgent = strings.ToLower(userAgent)
+	for _, bot := range t.BotUserAgents {
+		if strings.Contains(userAgent, strings.ToLower(bot)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter represents how the terms of the content filter are kept up to date
+type Filter struct {
+	ReloadInterval time.Duration `json:"reload_interval"` // How often the terms changed by other instances are read
+}
+
+// Reports represents when reported content is hidden until a moderator reviews it
+type Reports struct {
+	HideThreshold int `json:"hide_threshold"` // Open reports from different clients that hide a phrase or character
+}
+
+// AuthConfig holds the API keys clients identify themselves with, by name
+type AuthConfig struct {
+	APIKeys          map[string]APIKey `json:"api_keys"`
+	DefaultMaxRating string            `json:"default_max_rating"` // For anonymous requests and keys without their own
+}
+
+// APIKey is a key sent in the X-API-Key header, and the role it grants
+type APIKey struct {
+	Name      string `json:"name"`
+	Key       string `json:"key"`
+	Role      string `json:"role"`
+	MaxRating string `json:"max_rating"` // The strongest content rating the key is shown
+}
+
+// Lookup finds the API key sent by a client. Keys are compared in constant time
+func (a AuthConfig) Lookup(key string) (APIKey, bool) {
+	for _, apiKey := range a.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) == 1 {
+			return apiKey, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// CacheControl holds the Cache-Control policies of the cacheable routes, by route name
+type CacheControl struct {
+	Default string            `json:"default"`
+	Routes  map[string]string `json:"routes"`
+}
+
+// Policy returns the Cache-Control header of a route, falling back to the default policy
+func (c CacheControl) Policy(route string) string {
+	if policy, ok := c.Routes[route]; ok {
+		return policy
+	}
+	return c.Default
+}
+
+// Features holds the feature flags, by name
+type Features map[string]bool
+
+// Enabled tells whether a feature is on. Unknown features are off
+func (f Features) Enabled(name string) bool {
+	return f[name]
+}
+
+var environments = []string{"dev", "test", "beta", "prod"}
+
+var errorFormats = []string{"legacy", "problem"}
+
+// fromHOCON reads the typed configuration from the merged configuration layers. The typed getters panic
+// on values they can't convert, like a port that is not a number; that is reported as an error
+func fromHOCON(c *configuration.Config) (cfg *Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cfg, err = nil, fmt.Errorf("invalid configuration value: %v", r)
+		}
+	}()
+
+	return &Config{
+		Environment: c.GetString("environment"),
+		LogConfig: LogConfig{
+			FilePath: c.GetString("log.file_path"),
+			Level:    c.GetString("log.level"),
+			MaxAge:   int(c.GetInt32("log.max_age")),
+		},
+		Server: ServerConfig{
+			Port:              int(c.GetInt32("server.port")),
+			ReadTimeout:       c.GetTimeDuration("server.read_timeout"),
+			ReadHeaderTimeout: c.GetTimeDuration("server.read_header_timeout"),
+			WriteTimeout:      c.GetTimeDuration("server.write_timeout"),
+			IdleTimeout:       c.GetTimeDuration("server.idle_timeout"),
+			ShutdownTimeout:   c.GetTimeDuration("server.shutdown_timeout"),
+			MaxHeaderBytes:    int(c.GetInt32("server.max_header_bytes")),
+			HTTP2:             c.GetBoolean("server.http2"),
+			UnixSocket:        c.GetString("server.unix_socket"),
+			TLS: TLSConfig{
+				Cert:           c.GetString("server.tls.cert"),
+				Key:            c.GetString("server.tls.key"),
+				ReloadInterval: c.GetTimeDuration("server.tls.reload_interval"),
+			},
+		},
+		DB: DBConfig{
+			Host:                 c.GetString("db.host"),
+			Port:                 int(c.GetInt32("db.port")),
+			User:                 c.GetString("db.user"),
+			Password:             c.GetString("db.password"),
+			Name:                 c.GetString("db.name"),
+			MaxOpenConns:         int(c.GetInt32("db.max_open_conns")),
+			MaxIdleConns:         int(c.GetInt32("db.max_idle_conns")),
+			ConnMaxLifetime:      c.GetTimeDuration("db.conn_max_lifetime"),
+			DialTimeout:          c.GetTimeDuration("db.dial_timeout"),
+			ReadTimeout:          c.GetTimeDuration("db.read_timeout"),
+			WriteTimeout:         c.GetTimeDuration("db.write_timeout"),
+			RequestTimeout:       c.GetTimeDuration("db.request_timeout"),
+			Replicas:             c.GetStringList("db.replicas"),
+			ReplicaCheckInterval: c.GetTimeDuration("db.replica_check_interval"),
+			ReadYourWritesWindow: c.GetTimeDuration("db.read_your_writes_window"),
+			Retry: RetryConfig{
+				ConnectAttempts: int(c.GetInt32("db.retry.connect_attempts")),
+				Attempts:        int(c.GetInt32("db.retry.attempts")),
+				InitialBackoff:  c.GetTimeDuration("db.retry.initial_backoff"),
+				MaxBackoff:      c.GetTimeDuration("db.retry.max_backoff"),
+			},
+		},
+		Errors: ErrorsConfig{
+			Format:          c.GetString("errors.format"),
+			ProblemTypeBase: c.GetString("errors.problem_type_base"),
+		},
+		Reload: ReloadConfig{
+			Enabled:  c.GetBoolean("reload.enabled"),
+			Interval: c.GetTimeDuration("reload.interval"),
+		},
+		RateLimit: RateLimit{
+			Enabled:           c.GetBoolean("rate_limit.enabled"),
+			RequestsPerSecond: c.GetFloat64("rate_limit.requests_per_second"),
+			Burst:             int(c.GetInt32("rate_limit.burst")),
+		},
+		CORS: CORSConfig{
+			Enabled:        c.GetBoolean("cors.enabled"),
+			AllowedOrigins: c.GetStringList("cors.allowed_origins"),
+			AllowedMethods: c.GetStringList("cors.allowed_methods"),
+			AllowedHeaders: c.GetStringList("cors.allowed_headers"),
+			ExposedHeaders: c.GetStringList("cors.exposed_headers"),
+			MaxAge:         c.GetTimeDuration("cors.max_age"),
+		},
+		CacheControl: CacheControl{
+			Default: c.GetString("cache_control.default"),
+			Routes:  cacheRoutesFromHOCON(c),
+		},
+		Concurrency: Concurrency{
+			RequireIfMatch: c.GetBoolean("concurrency.require_if_match"),
+		},
+		Idempotency: Idempotency{
+			TTL:     c.GetTimeDuration("idempotency.ttl"),
+			MaxKeys: int(c.GetInt32("idempotency.max_keys")),
+		},
+		Duplicates: Duplicates{
+			SimilarityThreshold: c.GetFloat64("duplicates.similarity_threshold"),
+		},
+		Cache: CacheConfig{
+			Enabled:    c.GetBoolean("cache.enabled"),
+			MaxEntries: int(c.GetInt32("cache.max_entries")),
+			TTL:        c.GetTimeDuration("cache.ttl"),
+		},
+		Trending: Trending{
+			FlushInterval: c.GetTimeDuration("trending.flush_interval"),
+			BatchSize:     int(c.GetInt32("trending.batch_size")),
+			QueueSize:     int(c.GetInt32("trending.queue_size")),
+			CacheTTL:      c.GetTimeDuration("trending.cache_ttl"),
+			BotUserAgents: c.GetStringList("trending.bot_user_agents"),
+			BotAPIKeys:    c.GetStringList("trending.bot_api_keys"),
+		},
+		Filter: Filter{
+			ReloadInterval: c.GetTimeDuration("content_filter.reload_interval"),
+		},
+		Reports: Reports{
+			HideThreshold: int(c.GetInt32("reports.hide_threshold")),
+		},
+		Auth: AuthConfig{
+			APIKeys:          apiKeysFromHOCON(c),
+			DefaultMaxRating: c.GetString("auth.default_max_rating"),
+		},
+		Features: featuresFromHOCON(c),
+	}, nil
+}
+
+func featuresFromHOCON(c *configuration.Config) Features {
+	features := make(Features)
+	section := c.GetConfig("features")
+	for _, name := range Keys(section) {
+		features[name] = section.GetBoolean(name)
+	}
+	return features
+}
+
+func cacheRoutesFromHOCON(c *configuration.Config) map[string]string {
+	routes := make(map[string]string)
+	section := c.GetConfig("cache_control.routes")
+	for _, name := range Keys(section) {
+		routes[name] = section.GetString(name)
+	}
+	return routes
+}
+
+func apiKeysFromHOCON(c *configuration.Config) map[string]APIKey {
+	keys := make(map[string]APIKey)
+	section := c.GetConfig("auth.api_keys")
+	if section.IsEmpty() {
+		return keys
+	}
+	for name := range section.Root().GetObject().Items() {
+		keys[name] = APIKey{
+			Name:      name,
+			Key:       section.GetString(name + ".key"),
+			Role:      section.GetString(name+".role", APIKeyRoles[0]),
+			MaxRating: section.GetString(name+".max_rating", c.GetString("auth.default_max_rating")),
+		}
+	}
+	return keys
+}
+
+// Validate checks every value of the configuration, reporting all the problems found at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	if !contains(environments, c.Environment) {
+		problems = append(problems, fmt.Sprintf("environment must be one of %v", environments))
+	}
+	if _, err := logrus.ParseLevel(c.LogConfig.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("log.level %q is not a valid level", c.LogConfig.Level))
+	}
+	if c.LogConfig.MaxAge < 0 {
+		problems = append(problems, "log.max_age must not be negative")
+	}
+	if c.Server.Port < 0 || c.Server.Port > 65535 {
+		problems = append(problems, "server.port must be between 0 and 65535")
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.ReadHeaderTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 ||
+		c.Server.ShutdownTimeout < 0 {
+		problems = append(problems, "server timeouts must not be negative")
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		problems = append(problems, "server.max_header_bytes must be positive")
+	}
+	if (c.Server.TLS.Cert == "") != (c.Server.TLS.Key == "") {
+		problems = append(problems, "server.tls.cert and server.tls.key must be set together")
+	}
+	if c.DB.Host == "" {
+		problems = append(problems, "db.host must not be empty")
+	}
+	if c.DB.Port <= 0 || c.DB.Port > 65535 {
+		problems = append(problems, "db.port must be between 1 and 65535")
+	}
+	if c.DB.Name == "" {
+		problems = append(problems, "db.name must not be empty")
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 {
+		problems = append(problems, "db.max_open_conns and db.max_idle_conns must not be negative")
+	}
+	if c.DB.ConnMaxLifetime < 0 || c.DB.DialTimeout < 0 || c.DB.ReadTimeout < 0 || c.DB.WriteTimeout < 0 || c.DB.RequestTimeout < 0 {
+		problems = append(problems, "db timeouts must not be negative")
+	}
+	if len(c.DB.Replicas) > 0 && c.DB.ReplicaCheckInterval <= 0 {
+		problems = append(problems, "db.replica_check_interval must be positive")
+	}
+	if c.DB.ReadYourWritesWindow < 0 {
+		problems = append(problems, "db.read_your_writes_window must not be negative")
+	}
+	if c.DB.Retry.ConnectAttempts < 1 || c.DB.Retry.Attempts < 1 {
+		problems = append(problems, "db.retry.connect_attempts and db.retry.attempts must be at least 1")
+	}
+	if c.DB.Retry.InitialBackoff < 0 || c.DB.Retry.MaxBackoff < c.DB.Retry.InitialBackoff {
+		problems = append(problems, "db.retry.max_backoff must not be less than db.retry.initial_backoff")
+	}
+	if !contains(errorFormats, c.Errors.Format) {
+		problems = append(problems, fmt.Sprintf("errors.format must be one of %v", errorFormats))
+	}
+	if c.Reload.Enabled && c.Reload.Interval <= 0 {
+		problems = append(problems, "reload.interval must be positive")
+	}
+	if c.RateLimit.Enabled && (c.RateLimit.RequestsPerSecond <= 0 || c.RateLimit.Burst < 1) {
+		problems = append(problems, "rate_limit.requests_per_second must be positive and rate_limit.burst at least 1")
+	}
+	if c.Idempotency.TTL <= 0 || c.Idempotency.MaxKeys < 1 {
+		problems = append(problems, "idempotency.ttl must be positive and idempotency.max_keys at least 1")
+	}
+	if c.Duplicates.SimilarityThreshold <= 0 || c.Duplicates.SimilarityThreshold > 1 {
+		problems = append(problems, "duplicates.similarity_threshold must be greater than 0 and at most 1")
+	}
+	if c.Cache.Enabled && (c.Cache.MaxEntries < 1 || c.Cache.TTL <= 0) {
+		problems = append(problems, "cache.max_entries must be at least 1 and cache.ttl positive")
+	}
+	if c.Trending.FlushInterval <= 0 || c.Trending.BatchSize < 1 || c.Trending.QueueSize < 1 || c.Trending.CacheTTL <= 0 {
+		problems = append(problems, "trending.flush_interval and trending.cache_ttl must be positive, trending.batch_size and trending.queue_size at least 1")
+	}
+	if c.Filter.ReloadInterval <= 0 {
+		problems = append(problems, "content_filter.reload_interval must be positive")
+	}
+	if c.Reports.HideThreshold < 1 {
+		problems = append(problems, "reports.hide_threshold must be at least 1")
+	}
+	seenKeys := make(map[string]bool)
+	for name, apiKey := range c.Auth.APIKeys {
+		if apiKey.Key == "" || seenKeys[apiKey.Key] {
+			problems = append(problems, fmt.Sprintf("auth.api_keys.%s.key must be set and unique", name))
+		}
+		seenKeys[apiKey.Key] = true
+		if !contains(APIKeyRoles, apiKey.Role) {
+			problems = append(problems, fmt.Sprintf("auth.api_keys.%s.role must be one of %v", name, APIKeyRoles))
+		}
+		if !contains(ContentRatings, apiKey.MaxRating) {
+			problems = append(problems, fmt.Sprintf("auth.api_keys.%s.max_rating must be one of %v", name, ContentRatings))
+		}
+	}
+	if !contains(ContentRatings, c.Auth.DefaultMaxRating) {
+		problems = append(problems, fmt.Sprintf("auth.default_max_rating must be one of %v", ContentRatings))
+	}
+	if c.CORS.Enabled && len(c.CORS.AllowedOrigins) == 0 {
+		problems = append(problems, "cors.allowed_origins must not be empty")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
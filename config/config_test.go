@@ -0,0 +1,20 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrendingIsBot(t *testing.T) {
+	t.Log("Requests from bot user agents, bot API keys or without a user agent should not be counted")
+
+	trending := Trending{BotUserAgents: []string{"bot", "curl"}, BotAPIKeys: []string{"monitoring"}}
+
+	assert.False(t, trending.IsBot("Mozilla/5.0 (X11; Linux x86_64)", ""))
+	assert.False(t, trending.IsBot("Mozilla/5.0 (X11; Linux x86_64)", "frontend"))
+	assert.True(t, trending.IsBot("Mozilla/5.0 (compatible; Googlebot/2.1)", ""))
+	assert.True(t, trending.IsBot("curl/7.68.0", ""))
+	assert.True(t, trending.IsBot("Mozilla/5.0 (X11; Linux x86_64)", "monitoring"))
+	assert.True(t, trending.IsBot("", ""))
+}
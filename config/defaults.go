@@ -0,0 +1,133 @@
+package config
+
+// defaults is the lowest configuration layer. Every supported key must be listed here, since the
+// MEMEQUOTES_* environment variables are derived from these keys.
+const defaults = `
+environment = "dev"
+
+log {
+  file_path = ""
+  level = "debug"
+  max_age = 1
+}
+
+server {
+  port = 9000
+  read_timeout = 10s
+  read_header_timeout = 5s
+  write_timeout = 30s
+  idle_timeout = 120s
+  shutdown_timeout = 20s
+  max_header_bytes = 1048576
+  http2 = true
+  unix_socket = ""
+  tls {
+    cert = ""
+    key = ""
+    reload_interval = 10s
+  }
+}
+
+db {
+  host = "localhost"
+  port = 3306
+  user = "root"
+  password = ""
+  name = "memequotes"
+  max_open_conns = 20
+  max_idle_conns = 5
+  conn_max_lifetime = 5m
+  dial_timeout = 5s
+  read_timeout = 30s
+  write_timeout = 30s
+  request_timeout = 10s
+  replicas = []
+  replica_check_interval = 5s
+  read_your_writes_window = 5s
+  retry {
+    connect_attempts = 5
+    attempts = 3
+    initial_backoff = 100ms
+    max_backoff = 5s
+  }
+}
+
+errors {
+  format = "legacy"
+  problem_type_base = "/problems/"
+}
+
+reload {
+  enabled = true
+  interval = 5s
+}
+
+rate_limit {
+  enabled = false
+  requests_per_second = 10
+  burst = 20
+}
+
+cors {
+  enabled = false
+  allowed_origins = ["*"]
+  allowed_methods = ["GET", "POST", "PUT", "PATCH", "DELETE"]
+  allowed_headers = ["Content-Type", "Accept", "X-API-Key", "Idempotency-Key"]
+  exposed_headers = []
+  max_age = 10m
+}
+
+concurrency {
+  require_if_match = false
+}
+
+idempotency {
+  ttl = 24h
+  max_keys = 10000
+}
+
+duplicates {
+  similarity_threshold = 0.85
+}
+
+cache {
+  enabled = false
+  max_entries = 1000
+  ttl = 30s
+}
+
+trending {
+  flush_interval = 10s
+  batch_size = 500
+  queue_size = 10000
+  cache_ttl = 1m
+  bot_user_agents = ["bot", "crawler", "spider", "slurp", "curl", "wget", "python-requests", "headless"]
+  bot_api_keys = []
+}
+
+content_filter {
+  reload_interval = 1m
+}
+
+reports {
+  hide_threshold = 5
+}
+
+auth {
+  default_max_rating = mature
+  api_keys {
+  }
+}
+
+cache_control {
+  default = "no-cache"
+  routes {
+  }
+}
+
+features {
+  dialogues = true
+  collections = true
+  trending = true
+}
+`
@@ -1,45 +1,196 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/go-akka/configuration"
+	"github.com/go-akka/configuration/hocon"
 )
 
+// EnvPrefix is the prefix of the environment variables that override configuration keys
+const EnvPrefix = "MEMEQUOTES_"
+
 // Conf holds project main configuration
 var Conf *configuration.Config
 
 // Credentials holds all credentials
 var Credentials *configuration.Config
 
-// Config holds project main configuration
-type Config struct {
-	LogConfig LogConfig `json:"log"`
+// current holds the *Config in use. It's swapped atomically on reloads, so readers never see a partial update
+var current atomic.Value
+
+// Sources lists where the configuration layers are read from. Later layers override earlier ones:
+// defaults, ConfigFile, CredentialsFile, environment variables and finally Overrides (the CLI flags)
+type Sources struct {
+	ConfigFile      string
+	CredentialsFile string
+	Environ         []string
+	Overrides       map[string]string
 }
 
-// LogConfig represents main configuration for logging
-type LogConfig struct {
-	FilePath string `json:"file_path"`
-	Level    string `json:"level"`
-	MaxAge   int    `json:"max_age"` // It's express in days. How many days we keep the logs
+// Load builds and validates the configuration from its sources, and makes it the current one
+func Load(sources Sources) (*Config, error) {
+	merged, cfg, err := Build(sources)
+	if err != nil {
+		return nil, err
+	}
+	Conf = merged
+	Credentials = merged
+	current.Store(cfg)
+	return cfg, nil
 }
 
-// LoadConfiguration loads main configuration given the fileName
-func LoadConfiguration(fileName string) {
-	path, err := filepath.Abs(fileName)
+// Build merges the configuration layers and validates the result without changing the current configuration.
+// Layers are merged as HOCON text, so later layers override single keys and merge objects of earlier ones.
+func Build(sources Sources) (*configuration.Config, *Config, error) {
+	layers := []string{defaults}
+
+	for _, file := range []string{sources.ConfigFile, sources.CredentialsFile} {
+		layer, err := readFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	known, err := parse("defaults", defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+	layers = append(layers, valuesLayer(envValues(sources.Environ, known)))
+	layers = append(layers, valuesLayer(sources.Overrides))
+
+	merged, err := parse("merged layers", strings.Join(layers, "\n"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := fromHOCON(merged)
 	if err != nil {
-		log.Fatal("Configuration path error ", err)
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return merged, cfg, nil
+}
+
+// Current returns the configuration in use. Before Load is called it holds the defaults
+func Current() *Config {
+	if cfg, ok := current.Load().(*Config); ok {
+		return cfg
 	}
-	Conf = configuration.LoadConfig(path)
+	_, cfg, _ := Build(Sources{})
+	current.Store(cfg)
+	return cfg
 }
 
-// LoadCredentials loads credentials configuration
-func LoadCredentials(fileName string) {
-	if _, err := os.Stat(fileName); os.IsNotExist(err) {
-		log.Println("ERROR: could not find config file")
+// EnvVar returns the environment variable that overrides a configuration key. db.host -> MEMEQUOTES_DB_HOST
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+// readFile reads an optional configuration file, checking it parses on its own so errors point at the file.
+// A missing file is an empty layer
+func readFile(fileName string) (string, error) {
+	if fileName == "" {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		log.Printf("WARN: configuration file %s not found, skipping it", fileName)
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := parse(fileName, string(data)); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// envValues picks the MEMEQUOTES_* variables matching a known configuration key
+func envValues(environ []string, known *configuration.Config) map[string]string {
+	vars := make(map[string]string)
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[0], EnvPrefix) {
+			vars[parts[0]] = parts[1]
+		}
+	}
+
+	values := make(map[string]string)
+	for _, key := range Keys(known) {
+		if value, ok := vars[EnvVar(key)]; ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// valuesLayer creates a layer from plain key/value pairs. Values are always strings, the typed
+// getters convert them when read
+func valuesLayer(values map[string]string) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		value := values[key]
+		// Lists are passed as HOCON, e.g. cors.allowed_origins=["https://memequotes.com"]
+		if !strings.HasPrefix(value, "[") {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(fmt.Sprintf("%s = %s\n", key, value))
+	}
+	return sb.String()
+}
+
+// parse turns the panics of the HOCON parser into errors
+func parse(name string, text string) (conf *configuration.Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			conf, err = nil, fmt.Errorf("parsing configuration %s: %v", name, r)
+		}
+	}()
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return configuration.ParseString(text), nil
+}
+
+// Keys lists every leaf key of a configuration, sorted
+func Keys(c *configuration.Config) []string {
+	if c.IsEmpty() {
+		return nil
+	}
+	var keys []string
+	collectKeys("", c.Root(), &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+func collectKeys(prefix string, value *hocon.HoconValue, keys *[]string) {
+	if !value.IsObject() {
+		*keys = append(*keys, prefix)
 		return
 	}
-	Credentials = configuration.LoadConfig(fileName)
+	for key, child := range value.GetObject().Items() {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		collectKeys(path, child, keys)
+	}
 }
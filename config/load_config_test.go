@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildDefaults(t *testing.T) {
+	t.Log("Without sources the configuration should hold the defaults")
+
+	_, cfg, err := Build(Sources{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "dev", cfg.Environment)
+	assert.Equal(t, 9000, cfg.Server.Port)
+	assert.Equal(t, 10*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 3306, cfg.DB.Port)
+	assert.Equal(t, "memequotes", cfg.DB.Name)
+}
+
+func TestBuildLayersPrecedence(t *testing.T) {
+	t.Log("Each layer should override the previous ones: file, credentials, environment, flags")
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configFile := writeFile(t, dir, "application.conf", `
+environment = beta
+server.port = 8000
+db.host = "config-file-host"
+log.level = info
+`)
+	credentialsFile := writeFile(t, dir, "credentials.conf", `
+db.host = "credentials-host"
+db.user = "memequotes"
+db.password = "s3cr3t"
+`)
+
+	_, cfg, err := Build(Sources{
+		ConfigFile:      configFile,
+		CredentialsFile: credentialsFile,
+		Environ:         []string{"MEMEQUOTES_DB_HOST=env-host", "MEMEQUOTES_SERVER_PORT=8100", "MEMEQUOTES_UNKNOWN=1", "HOME=/root"},
+		Overrides:       map[string]string{"server.port": "8200"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "beta", cfg.Environment)
+	assert.Equal(t, "info", cfg.LogConfig.Level)
+	assert.Equal(t, "env-host", cfg.DB.Host)
+	assert.Equal(t, "memequotes", cfg.DB.User)
+	assert.Equal(t, "s3cr3t", cfg.DB.Password)
+	assert.Equal(t, 8200, cfg.Server.Port)
+}
+
+func TestBuildInvalidConfiguration(t *testing.T) {
+	t.Log("Invalid values should be reported all at once")
+
+	_, _, err := Build(Sources{Overrides: map[string]string{
+		"environment":     "staging",
+		"log.level":       "loud",
+		"server.tls.cert": "cert.pem",
+	}})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "environment")
+	assert.Contains(t, err.Error(), "log.level")
+	assert.Contains(t, err.Error(), "server.tls.cert")
+}
+
+func TestBuildMalformedFile(t *testing.T) {
+	t.Log("A malformed file should be an error, not a panic")
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configFile := writeFile(t, dir, "application.conf", `server { port = `)
+
+	_, _, err = Build(Sources{ConfigFile: configFile})
+
+	assert.Error(t, err)
+}
+
+func TestBuildNonNumericPort(t *testing.T) {
+	t.Log("A value that can't be converted should be an error, not a panic")
+
+	_, _, err := Build(Sources{Environ: []string{"MEMEQUOTES_SERVER_PORT=http"}})
+
+	assert.Error(t, err)
+}
+
+func TestEnvVar(t *testing.T) {
+	t.Log("Environment variables should be derived from the configuration keys")
+
+	assert.Equal(t, "MEMEQUOTES_DB_HOST", EnvVar("db.host"))
+	assert.Equal(t, "MEMEQUOTES_LOG_FILE_PATH", EnvVar("log.file_path"))
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	t.Log("Printing the configuration should never show secrets")
+
+	merged, _, err := Build(Sources{Overrides: map[string]string{"db.password": "s3cr3t"}})
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, Print(&out, merged))
+
+	assert.NotContains(t, out.String(), "s3cr3t")
+	assert.Contains(t, out.String(), "db.password = "+RedactedValue)
+	assert.Contains(t, out.String(), "server.port = 9000")
+}
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-akka/configuration"
+)
+
+// RedactedValue replaces secrets when the configuration is printed
+const RedactedValue = "<redacted>"
+
+// secretKeyParts are the key fragments that mark a value as a secret
+var secretKeyParts = []string{"password", "secret", "token", "api_key"}
+
+// IsSecret tells whether the value of a key must never be printed
+func IsSecret(key string) bool {
+	lower := strings.ToLower(key)
+	for _, part := range secretKeyParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes every key of the effective configuration as HOCON, one per line, with secrets redacted
+func Print(w io.Writer, c *configuration.Config) error {
+	for _, key := range Keys(c) {
+		value := c.GetNode(key).String()
+		if IsSecret(key) && value != "" {
+			value = RedactedValue
+		}
+		if _, err := fmt.Fprintf(w, "%s = %s\n", key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	listenersMu sync.Mutex
+	listeners   []func(previous *Config, current *Config)
+)
+
+// OnReload registers a function called after every successful reload
+func OnReload(listener func(previous *Config, current *Config)) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	listeners = append(listeners, listener)
+}
+
+// Reload rebuilds the configuration from its sources and swaps it in. An invalid configuration is
+// rejected and the running one is kept. Server, database and cache settings, except for db.retry,
+// db.request_timeout and db.read_your_writes_window, are only read on startup, so changing them requires
+// a restart.
+func Reload(sources Sources) error {
+	_, cfg, err := Build(sources)
+	if err != nil {
+		log.Printf("ERROR: configuration reload rejected, keeping the running configuration: %v", err)
+		return err
+	}
+
+	previous := Current()
+	current.Store(cfg)
+	log.Println("INFO: configuration reloaded")
+	if !reflect.DeepEqual(previous.Server, cfg.Server) || !reflect.DeepEqual(previous.DB.startupSettings(), cfg.DB.startupSettings()) ||
+		previous.Cache != cfg.Cache {
+		log.Println("WARN: server, db and cache settings changed, they will be applied on the next restart")
+	}
+
+	listenersMu.Lock()
+	registered := append([]func(*Config, *Config){}, listeners...)
+	listenersMu.Unlock()
+	for _, listener := range registered {
+		listener(previous, cfg)
+	}
+	return nil
+}
+
+// Watch reloads the configuration when SIGHUP is received or when the configuration files change.
+// It returns when stop is closed
+func Watch(sources Sources, stop <-chan struct{}) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(Current().Reload.Interval)
+	defer ticker.Stop()
+
+	files := []string{sources.ConfigFile, sources.CredentialsFile}
+	modTimes := fileModTimes(files)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hangup:
+			log.Println("INFO: SIGHUP received, reloading configuration")
+			_ = Reload(sources)
+			modTimes = fileModTimes(files)
+		case <-ticker.C:
+			latest := fileModTimes(files)
+			if !reflect.DeepEqual(latest, modTimes) {
+				modTimes = latest
+				_ = Reload(sources)
+			}
+		}
+	}
+}
+
+func fileModTimes(files []string) map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// startupSettings drops the settings that are read on every query and can change at runtime
+func (c DBConfig) startupSettings() DBConfig {
+	c.RequestTimeout = 0
+	c.ReadYourWritesWindow = 0
+	c.Retry = RetryConfig{}
+	return c
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadSwapsConfiguration(t *testing.T) {
+	t.Log("A valid reload should replace the current configuration and notify listeners")
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sources := Sources{ConfigFile: writeFile(t, dir, "application.conf", `log.level = debug`)}
+	_, err = Load(sources)
+	assert.NoError(t, err)
+	defer Load(Sources{})
+
+	var notified *Config
+	OnReload(func(previous *Config, current *Config) {
+		notified = current
+	})
+
+	writeFile(t, dir, "application.conf", `log.level = warn`)
+	assert.NoError(t, Reload(sources))
+
+	assert.Equal(t, "warn", Current().LogConfig.Level)
+	assert.Equal(t, Current(), notified)
+}
+
+func TestReloadRejectsInvalidConfiguration(t *testing.T) {
+	t.Log("An invalid reload should keep the running configuration")
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	sources := Sources{ConfigFile: writeFile(t, dir, "application.conf", `rate_limit.burst = 5`)}
+	_, err = Load(sources)
+	assert.NoError(t, err)
+	defer Load(Sources{})
+	running := Current()
+
+	writeFile(t, dir, "application.conf", `rate_limit { enabled = true, burst = 0 }`)
+	assert.Error(t, Reload(sources))
+
+	assert.Equal(t, running, Current())
+}
+
+func TestWatchReloadsChangedFile(t *testing.T) {
+	t.Log("Changing the configuration file should reload it")
+
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configFile := writeFile(t, dir, "application.conf", `reload.interval = 10ms`)
+	sources := Sources{ConfigFile: configFile}
+	_, err = Load(sources)
+	assert.NoError(t, err)
+	defer Load(Sources{})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go Watch(sources, stop)
+
+	writeFile(t, dir, "application.conf", "reload.interval = 10ms\nfeatures.trending = true")
+
+	// The watcher may start after the write, so keep touching the file until it's noticed
+	later := time.Now()
+	assert.Eventually(t, func() bool {
+		later = later.Add(time.Minute)
+		assert.NoError(t, os.Chtimes(configFile, later, later))
+		return Current().Features.Enabled("trending")
+	}, time.Second, 20*time.Millisecond)
+}
@@ -14,6 +14,9 @@ const (
 	loggerKey    = ctxKey("logger_key")
 	requestIDKey = ctxKey("request_id_key")
 	hostnameKey  = ctxKey("hostname_key")
+	clientIDKey  = ctxKey("client_id_key")
+	roleKey      = ctxKey("role_key")
+	maxRatingKey = ctxKey("max_rating_key")
 )
 
 func (c ctxKey) String() string {
@@ -25,10 +28,14 @@ func WithRequestContext(ctx context.Context, ginCtx *gin.Context) {
 	ginCtx.Set(contextKey.String(), ctx)
 }
 
-//RequestContext returns a context.Context from a gin.Context
+//RequestContext returns a context.Context from a gin.Context. It is derived from the http request's
+//context, so it is cancelled when the client disconnects
 func RequestContext(ginCtx *gin.Context) context.Context {
 	ctxValue, ok := ginCtx.Get(contextKey.String())
 	if !ok {
+		if ginCtx.Request != nil {
+			return ginCtx.Request.Context()
+		}
 		return context.Background()
 	}
 
@@ -78,6 +85,40 @@ func Hostname(ctx context.Context) string {
 	return hostname
 }
 
+// WithClientID adds the id of the client making the request to request context
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDKey, id)
+}
+
+// ClientID gets the id of the client making the request. It's empty outside of requests
+func ClientID(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey).(string)
+	return id
+}
+
+// WithRole adds the role of the API key the request was made with to request context
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey, role)
+}
+
+// Role gets the role of the API key the request was made with. It's empty for anonymous requests
+func Role(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey).(string)
+	return role
+}
+
+// WithMaxRating adds the strongest content rating the API key of the request is shown to request context
+func WithMaxRating(ctx context.Context, rating string) context.Context {
+	return context.WithValue(ctx, maxRatingKey, rating)
+}
+
+// MaxRating gets the strongest content rating the API key of the request is shown. It's empty for anonymous
+// requests
+func MaxRating(ctx context.Context) string {
+	rating, _ := ctx.Value(maxRatingKey).(string)
+	return rating
+}
+
 // WithContext sets the application context
 func WithContext(ctx context.Context, c context.Context) context.Context {
 	return context.WithValue(ctx, contextKey, c)
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/jinzhu/gorm"
+)
+
+// Cluster routes reads to the healthy replicas and writes to the primary. A client that wrote
+// recently reads from the primary, so it always sees its own writes
+type Cluster struct {
+	primary  *gorm.DB
+	replicas []*replica
+	next     uint32
+
+	mu     sync.Mutex
+	writes map[string]time.Time // Last write of every client, by client id
+}
+
+type replica struct {
+	addr    string
+	db      *gorm.DB
+	healthy int32
+}
+
+// NewCluster creates a cluster without replicas. Every query goes to the primary until replicas are added
+func NewCluster(primary *gorm.DB) *Cluster {
+	return &Cluster{
+		primary: primary,
+		writes:  make(map[string]time.Time),
+	}
+}
+
+// AddReplica adds a replica. It receives reads once a health check finds it healthy
+func (c *Cluster) AddReplica(addr string, db *gorm.DB) {
+	c.replicas = append(c.replicas, &replica{addr: addr, db: db})
+}
+
+// Reader returns a handle for read-only queries, bound to the context. It is a healthy replica,
+// or the primary when there is none or the client wrote within db.read_your_writes_window
+func (c *Cluster) Reader(ctx context.Context) *gorm.DB {
+	if c.wroteRecently(commonContext.ClientID(ctx)) {
+		return WithContext(ctx, c.primary)
+	}
+	if r := c.pickReplica(); r != nil {
+		return WithContext(ctx, r.db)
+	}
+	return WithContext(ctx, c.primary)
+}
+
+// Writer returns a handle to the primary for writes and transactions, bound to the context. The
+// client's following reads go to the primary too, for db.read_your_writes_window. Without replicas every
+// read goes to the primary already, so the write isn't remembered
+func (c *Cluster) Writer(ctx context.Context) *gorm.DB {
+	if client := commonContext.ClientID(ctx); client != "" && len(c.replicas) > 0 {
+		c.mu.Lock()
+		c.writes[client] = time.Now()
+		c.mu.Unlock()
+	}
+	return WithContext(ctx, c.primary)
+}
+
+// Primary returns the primary, unbound to any context
+func (c *Cluster) Primary() *gorm.DB {
+	return c.primary
+}
+
+func (c *Cluster) wroteRecently(client string) bool {
+	if client == "" {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wrote, ok := c.writes[client]
+	return ok && time.Since(wrote) < config.Current().DB.ReadYourWritesWindow
+}
+
+// pickReplica chooses among the healthy replicas in turns
+func (c *Cluster) pickReplica() *replica {
+	n := len(c.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := atomic.AddUint32(&c.next, 1)
+	for i := 0; i < n; i++ {
+		r := c.replicas[(int(start)+i)%n]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r
+		}
+	}
+	return nil
+}
+
+// CheckReplicas pings every replica, taking the ones that fail out of rotation until they answer again.
+// It also forgets the writes that are past the read-your-writes window
+func (c *Cluster) CheckReplicas(ctx context.Context) {
+	logger := commonContext.Logger(ctx)
+	for _, r := range c.replicas {
+		healthy := ping(ctx, r.db) == nil
+		var state int32
+		if healthy {
+			state = 1
+		}
+		if previous := atomic.SwapInt32(&r.healthy, state); previous != state {
+			if healthy {
+				logger.Info(fmt.Sprintf("Replica %s is healthy, sending reads to it", r.addr))
+			} else {
+				logger.Warn(fmt.Sprintf("Replica %s failed its health check, reads fall back to other replicas or the primary", r.addr))
+			}
+		}
+	}
+
+	window := config.Current().DB.ReadYourWritesWindow
+	c.mu.Lock()
+	for client, wrote := range c.writes {
+		if time.Since(wrote) >= window {
+			delete(c.writes, client)
+		}
+	}
+	c.mu.Unlock()
+}
+
+// WatchReplicas checks the replicas every interval until stop is closed
+func (c *Cluster) WatchReplicas(interval time.Duration, stop <-chan struct{}) {
+	ctx := commonContext.AppContext(context.Background())
+	c.CheckReplicas(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.CheckReplicas(ctx)
+		}
+	}
+}
+
+// Close closes the primary and every replica, returning the first error
+func (c *Cluster) Close() error {
+	err := c.primary.Close()
+	for _, r := range c.replicas {
+		if replicaErr := r.db.Close(); err == nil {
+			err = replicaErr
+		}
+	}
+	return err
+}
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	pool, ok := db.CommonDB().(interface {
+		PingContext(ctx context.Context) error
+	})
+	if !ok {
+		return errors.New("replica has no connection pool")
+	}
+	if timeout := config.Current().DB.DialTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return pool.PingContext(ctx)
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriterRemembersWritesOnlyWithReplicas(t *testing.T) {
+	t.Log("Writes should only be remembered for read-your-writes when there are replicas to read from")
+
+	var used context.Context
+	db, err := gorm.Open("mysql", fakeConn{ctx: &used})
+	assert.NoError(t, err)
+	ctx := commonContext.WithClientID(context.Background(), "ip:10.0.0.1")
+
+	cluster := NewCluster(db)
+	cluster.Writer(ctx)
+	assert.Empty(t, cluster.writes)
+
+	cluster.AddReplica("replica:3306", db)
+	cluster.Writer(ctx)
+	assert.Len(t, cluster.writes, 1)
+	assert.True(t, cluster.wroteRecently("ip:10.0.0.1"))
+}
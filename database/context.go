@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"unsafe"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/jinzhu/gorm"
+)
+
+// ErrNoConnection is returned when a transaction is started on something that is not a connection pool
+var ErrNoConnection = errors.New("transactions can only be started on a database connection")
+
+// sqlConn is implemented by both *sql.DB and *sql.Tx
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// contextConn binds every statement to a context. GORM v1 has no context support, so it is
+// handed this connection instead of the *sql.DB or *sql.Tx
+type contextConn struct {
+	ctx  context.Context
+	conn sqlConn
+}
+
+func (c contextConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.conn.ExecContext(c.ctx, query, args...)
+}
+
+func (c contextConn) Prepare(query string) (*sql.Stmt, error) {
+	return c.conn.PrepareContext(c.ctx, query)
+}
+
+func (c contextConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.conn.QueryContext(c.ctx, query, args...)
+}
+
+func (c contextConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.conn.QueryRowContext(c.ctx, query, args...)
+}
+
+// WithTimeout bounds the database work of a request with db.request_timeout. A zero timeout only
+// keeps the request's own cancellation
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := config.Current().DB.RequestTimeout
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// WithContext returns a GORM handle whose statements are cancelled when the context is done
+func WithContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	conn, ok := unwrap(db.CommonDB())
+	if !ok {
+		return db
+	}
+	return bind(ctx, db, conn)
+}
+
+// WithTransaction runs fn inside a transaction bound to the context, committing it when fn succeeds
+// and rolling it back otherwise. A transaction that deadlocks is run again from the start, as
+// configured in db.retry
+func WithTransaction(ctx context.Context, db *gorm.DB, operation string, fn func(tx *gorm.DB) error) error {
+	conn, _ := unwrap(db.CommonDB())
+	pool, ok := conn.(*sql.DB)
+	if !ok {
+		return ErrNoConnection
+	}
+
+	policy := config.Current().DB.Retry
+	return Retry(ctx, operation, policy.Attempts, policy.InitialBackoff, policy.MaxBackoff, IsDeadlock, func() error {
+		tx, err := pool.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(bind(ctx, db, tx)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+func unwrap(common gorm.SQLCommon) (sqlConn, bool) {
+	switch conn := common.(type) {
+	case contextConn:
+		return conn.conn, true
+	case sqlConn:
+		return conn, true
+	}
+	return nil, false
+}
+
+// bind clones the handle, with its log mode, logger, callbacks and values, running its statements on the
+// connection bound to the context. GORM v1 has no way to change the connection of a handle other than
+// starting a transaction, so the clone's one is set through reflection
+func bind(ctx context.Context, db *gorm.DB, conn sqlConn) *gorm.DB {
+	bound := db.New()
+	common := gorm.SQLCommon(contextConn{ctx: ctx, conn: conn})
+	field := reflect.ValueOf(bound).Elem().FieldByName("db")
+	reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Set(reflect.ValueOf(&common).Elem())
+	bound.Dialect().SetDB(common)
+	return bound
+}
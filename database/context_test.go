@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+var errFakeConn = errors.New("fake connection")
+
+// fakeConn records the context its statements are run with
+type fakeConn struct {
+	ctx *context.Context
+}
+
+func (f fakeConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return f.ExecContext(context.Background(), query, args...)
+}
+
+func (f fakeConn) Prepare(query string) (*sql.Stmt, error) {
+	return f.PrepareContext(context.Background(), query)
+}
+
+func (f fakeConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return f.QueryContext(context.Background(), query, args...)
+}
+
+func (f fakeConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return f.QueryRowContext(context.Background(), query, args...)
+}
+
+func (f fakeConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	*f.ctx = ctx
+	return nil, errFakeConn
+}
+
+func (f fakeConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	*f.ctx = ctx
+	return nil, errFakeConn
+}
+
+func (f fakeConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	*f.ctx = ctx
+	return nil, errFakeConn
+}
+
+func (f fakeConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	*f.ctx = ctx
+	return nil
+}
+
+func TestWithContextBindsStatements(t *testing.T) {
+	t.Log("Statements run through a bound handle should receive the request context")
+
+	var used context.Context
+	db, err := gorm.Open("mysql", fakeConn{ctx: &used})
+	assert.NoError(t, err)
+
+	type requestKey struct{}
+	ctx := context.WithValue(context.Background(), requestKey{}, "request")
+	err = WithContext(ctx, db).Exec("DELETE FROM character").Error
+
+	assert.Equal(t, errFakeConn, err)
+	assert.Equal(t, "request", used.Value(requestKey{}))
+}
+
+func TestWithContextKeepsSettings(t *testing.T) {
+	t.Log("A bound handle should keep the settings of the handle it's bound from")
+
+	var used context.Context
+	db, err := gorm.Open("mysql", fakeConn{ctx: &used})
+	assert.NoError(t, err)
+	db.InstantSet("gorm:table_options", "ENGINE=InnoDB")
+
+	bound := WithContext(context.Background(), db)
+
+	options, ok := bound.Get("gorm:table_options")
+	assert.True(t, ok)
+	assert.Equal(t, "ENGINE=InnoDB", options)
+	assert.IsType(t, contextConn{}, bound.CommonDB())
+	assert.IsType(t, fakeConn{}, db.CommonDB())
+}
+
+func TestWithTransactionNeedsAConnectionPool(t *testing.T) {
+	t.Log("A transaction can't be started on something that is not a connection pool")
+
+	var used context.Context
+	db, err := gorm.Open("mysql", fakeConn{ctx: &used})
+	assert.NoError(t, err)
+
+	err = WithTransaction(context.Background(), db, "test", func(tx *gorm.DB) error { return nil })
+
+	assert.Equal(t, ErrNoConnection, err)
+}
+
+func TestWithTimeout(t *testing.T) {
+	t.Log("Database work should be bounded by db.request_timeout")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{"db.request_timeout": "2s"}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	ctx, cancel := WithTimeout(context.Background())
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(2*time.Second), deadline, time.Second)
+}
+
+func TestWithTimeoutDisabled(t *testing.T) {
+	t.Log("A zero db.request_timeout should only keep the request's own cancellation")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{"db.request_timeout": "0s"}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	ctx, cancel := WithTimeout(context.Background())
+	defer cancel()
+
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
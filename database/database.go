@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"github.com/airabinovich/memequotes_back/config"
 	commonContext "github.com/airabinovich/memequotes_back/context"
@@ -9,32 +10,84 @@ import (
 	_ "github.com/jinzhu/gorm/dialects/mysql"
 )
 
+// DB is the primary database
 var DB *gorm.DB
 
+// DBCluster routes queries between the primary and the read replicas
+var DBCluster *Cluster
+
+var stopReplicaChecks chan struct{}
+
 //Initialize connects to the database al fills the global variable utils.DB
 func Initialize() error {
 	ctx := commonContext.AppContext(context.Background())
 	logger := commonContext.Logger(ctx)
-	username := config.Credentials.GetString("db.user", "root")
-	password := config.Credentials.GetString("db.password", "")
-	host := config.Credentials.GetString("db.host", "localhost")
-	port := config.Credentials.GetInt32("db.post", 3306)
-	var err error
-	DB, err = gorm.Open("mysql",
-		fmt.Sprintf("%s:%s@(%s:%d)/memequotes?charset=utf8mb4&parseTime=True&loc=UTC", username, password, host, port))
+	c := config.Current().DB
+
+	// The database may still be starting, so connecting is retried on any error
+	err := Retry(ctx, "connecting to DB", c.Retry.ConnectAttempts, c.Retry.InitialBackoff, c.Retry.MaxBackoff,
+		func(error) bool { return true },
+		func() error {
+			var err error
+			DB, err = gorm.Open("mysql", dataSourceName(c, fmt.Sprintf("%s:%d", c.Host, c.Port)))
+			return err
+		})
 	if err != nil {
 		logger.Error("opening DB", err)
 		return err
 	}
-	logger.Info(fmt.Sprintf("Connected to DB %s:%d", host, port))
+	configurePool(DB.DB(), c)
+	logger.Info(fmt.Sprintf("Connected to DB %s:%d", c.Host, c.Port))
+
+	DBCluster = NewCluster(DB)
+	for _, addr := range c.Replicas {
+		replica, err := openReplica(c, addr)
+		if err != nil {
+			logger.Error(fmt.Sprintf("opening replica %s", addr), err)
+			return err
+		}
+		DBCluster.AddReplica(addr, replica)
+	}
+	if len(c.Replicas) > 0 {
+		stopReplicaChecks = make(chan struct{})
+		go DBCluster.WatchReplicas(c.ReplicaCheckInterval, stopReplicaChecks)
+	}
 	return nil
 }
 
+// openReplica opens the connection pool of a replica without connecting to it, so a replica that is
+// down doesn't keep the service from starting. The health checks send reads to it once it answers
+func openReplica(c config.DBConfig, addr string) (*gorm.DB, error) {
+	pool, err := sql.Open("mysql", dataSourceName(c, addr))
+	if err != nil {
+		return nil, err
+	}
+	configurePool(pool, c)
+	// Opening on an existing pool only fails when the ping fails, and that is left to the health checks
+	db, _ := gorm.Open("mysql", pool)
+	return db, nil
+}
+
+func configurePool(pool *sql.DB, c config.DBConfig) {
+	pool.SetMaxOpenConns(c.MaxOpenConns)
+	pool.SetMaxIdleConns(c.MaxIdleConns)
+	pool.SetConnMaxLifetime(c.ConnMaxLifetime)
+}
+
+// dataSourceName builds the MySQL DSN for a host:port, including the dial, read and write timeouts
+func dataSourceName(c config.DBConfig, addr string) string {
+	return fmt.Sprintf("%s:%s@(%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC&timeout=%s&readTimeout=%s&writeTimeout=%s",
+		c.User, c.Password, addr, c.Name, c.DialTimeout, c.ReadTimeout, c.WriteTimeout)
+}
+
 // Close the connection to the DB
 func Close() error {
 	ctx := commonContext.AppContext(context.Background())
 	logger := commonContext.Logger(ctx)
-	if err := DB.Close(); err != nil {
+	if stopReplicaChecks != nil {
+		close(stopReplicaChecks)
+	}
+	if err := DBCluster.Close(); err != nil {
 		logger.Error("closing the database connection", err)
 		return err
 	}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL server error numbers we translate into domain errors
+const (
+	mysqlTooManyConnections = 1040
+	mysqlDuplicateEntry     = 1062
+	mysqlDataTooLong        = 1406
+	mysqlRowIsReferenced    = 1451
+	mysqlNoReferencedRow    = 1452
+)
+
+// TranslateError converts a driver error into one of the typed errors in the errors package.
+// Errors that have no domain meaning are returned unchanged.
+func TranslateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case mysqlDuplicateEntry:
+			return customErrors.NewConflictError("resource already exists")
+		case mysqlRowIsReferenced:
+			return customErrors.NewConflictError("resource is still referenced by other resources")
+		case mysqlNoReferencedRow:
+			return customErrors.NewNotFoundError("referenced resource not found")
+		case mysqlDataTooLong:
+			return customErrors.NewValidationError("", "value is too long")
+		case mysqlTooManyConnections:
+			return customErrors.NewUnavailableError("database unavailable", err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr) {
+		return customErrors.NewUnavailableError("database unavailable", err)
+	}
+	return err
+}
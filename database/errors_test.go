@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateErrorDuplicateEntry(t *testing.T) {
+	t.Log("A duplicate entry should be translated to a conflict")
+
+	err := TranslateError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'Fort' for key 'name'"})
+
+	assert.IsType(t, customErrors.ConflictError{}, err)
+}
+
+func TestTranslateErrorMissingReference(t *testing.T) {
+	t.Log("A foreign key failure on insert should be translated to not found")
+
+	err := TranslateError(&mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"})
+
+	assert.IsType(t, customErrors.NotFoundError{}, err)
+}
+
+func TestTranslateErrorBadConnection(t *testing.T) {
+	t.Log("A broken connection should be translated to unavailable")
+
+	err := TranslateError(driver.ErrBadConn)
+
+	assert.IsType(t, customErrors.UnavailableError{}, err)
+	assert.True(t, errors.Is(err, driver.ErrBadConn))
+}
+
+func TestTranslateErrorUnknown(t *testing.T) {
+	t.Log("Errors without domain meaning should be returned unchanged")
+
+	original := errors.New("DB error")
+
+	assert.Equal(t, original, TranslateError(original))
+	assert.Nil(t, TranslateError(nil))
+}
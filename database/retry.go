@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL server error numbers for transactions that lost a lock and can be run again
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// IsTransient tells whether an error is caused by a lost or refused connection, so the same
+// statement may succeed if it is run again
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlTooManyConnections
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
+
+// IsDeadlock tells whether a transaction was rolled back by the server because of a lock conflict
+func IsDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDeadlock || mysqlErr.Number == mysqlLockWaitTimeout
+	}
+	return false
+}
+
+// Retry runs fn until it succeeds, it fails with an error that is not retryable, the attempts are
+// exhausted or the context is done. Every retry is logged. The last error is returned.
+func Retry(ctx context.Context, operation string, attempts int, initialBackoff, maxBackoff time.Duration,
+	retryable func(error) bool, fn func() error) error {
+	logger := commonContext.Logger(ctx)
+	backoff := initialBackoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !retryable(err) || attempt >= attempts {
+			return err
+		}
+
+		logger.Warn(fmt.Sprintf("%s failed on attempt %d of %d, retrying in %s: %v", operation, attempt, attempts, backoff, err))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// RetryRead runs an idempotent read, retrying it on transient errors as configured in db.retry
+func RetryRead(ctx context.Context, operation string, fn func() error) error {
+	policy := config.Current().DB.Retry
+	return Retry(ctx, operation, policy.Attempts, policy.InitialBackoff, policy.MaxBackoff, IsTransient, fn)
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrySucceedsAfterTransientErrors(t *testing.T) {
+	t.Log("A transient error should be retried until the operation succeeds")
+
+	calls := 0
+	err := Retry(context.Background(), "test", 3, time.Millisecond, time.Millisecond, IsTransient, func() error {
+		calls++
+		if calls < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryGivesUpAfterAttempts(t *testing.T) {
+	t.Log("The last error should be returned once the attempts are exhausted")
+
+	calls := 0
+	err := Retry(context.Background(), "test", 2, time.Millisecond, time.Millisecond, IsTransient, func() error {
+		calls++
+		return mysql.ErrInvalidConn
+	})
+
+	assert.Equal(t, mysql.ErrInvalidConn, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetryDoesNotRetryPermanentErrors(t *testing.T) {
+	t.Log("An error that is not transient should be returned right away")
+
+	calls := 0
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+	err := Retry(context.Background(), "test", 5, time.Millisecond, time.Millisecond, IsTransient, func() error {
+		calls++
+		return duplicate
+	})
+
+	assert.Equal(t, duplicate, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryStopsWhenContextIsDone(t *testing.T) {
+	t.Log("Retrying should stop when the request is cancelled")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := Retry(ctx, "test", 5, time.Hour, time.Hour, IsTransient, func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+
+	assert.Equal(t, driver.ErrBadConn, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIsDeadlock(t *testing.T) {
+	t.Log("Deadlocks and lock wait timeouts should be retried as a whole transaction")
+
+	assert.True(t, IsDeadlock(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"}))
+	assert.True(t, IsDeadlock(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}))
+	assert.False(t, IsDeadlock(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}))
+	assert.False(t, IsDeadlock(errors.New("other")))
+}
+
+func TestDataSourceNameIncludesTimeouts(t *testing.T) {
+	t.Log("The DSN should carry the configured dial, read and write timeouts")
+
+	c := config.DBConfig{
+		User: "user", Password: "pass", Name: "memequotes",
+		DialTimeout: 5 * time.Second, ReadTimeout: 30 * time.Second, WriteTimeout: time.Minute,
+	}
+
+	dsn := dataSourceName(c, "db:3306")
+
+	assert.Equal(t, "user:pass@(db:3306)/memequotes?charset=utf8mb4&parseTime=True&loc=UTC&timeout=5s&readTimeout=30s&writeTimeout=1m0s", dsn)
+	_, err := mysql.ParseDSN(dsn)
+	assert.NoError(t, err)
+}
@@ -2,20 +2,201 @@ CREATE SCHEMA memequotes;
 
 CREATE TABLE `characters` (
   `id` bigint(20) NOT NULL AUTO_INCREMENT,
-  `name` varchar(100) NOT NULL,
+  `name` varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `slug` varchar(110) NOT NULL,
+  `hidden` tinyint(1) NOT NULL DEFAULT 0,
   `date_created` datetime NOT NULL,
   `last_updated` datetime NOT NULL,
+  `version` bigint(20) NOT NULL DEFAULT 1,
   PRIMARY KEY (`id`),
-  UNIQUE KEY `name` (`name`)
+  UNIQUE KEY `name` (`name`),
+  UNIQUE KEY `slug` (`slug`)
 ) ENGINE=InnoDB AUTO_INCREMENT=5 DEFAULT CHARSET=utf8mb4;
 
+CREATE TABLE `character_slug_redirects` (
+  `slug` varchar(110) NOT NULL,
+  `character_id` bigint(20) NOT NULL,
+  `date_created` datetime NOT NULL,
+  PRIMARY KEY (`slug`),
+  KEY `fk_slug_redirect_character` (`character_id`),
+  CONSTRAINT `fk_slug_redirect_character` FOREIGN KEY (`character_id`) REFERENCES `characters` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `character_aliases` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `character_id` bigint(20) NOT NULL,
+  `alias` varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `date_created` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `alias` (`alias`),
+  KEY `fk_alias_character` (`character_id`),
+  CONSTRAINT `fk_alias_character` FOREIGN KEY (`character_id`) REFERENCES `characters` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `character_merges` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `target_id` bigint(20) NOT NULL,
+  `source_id` bigint(20) NOT NULL,
+  `source_name` varchar(100) NOT NULL,
+  `source_slug` varchar(110) NOT NULL,
+  `moved_phrases` int(11) NOT NULL,
+  `dropped_phrases` int(11) NOT NULL,
+  `merged_by` varchar(255) NOT NULL,
+  `date_created` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `merge_target` (`target_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `sources` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `kind` varchar(20) NOT NULL,
+  `title` varchar(255) NOT NULL,
+  `episode` varchar(100) NOT NULL DEFAULT '',
+  `air_date` date NULL,
+  `url` varchar(500) NOT NULL DEFAULT '',
+  `date_created` datetime NOT NULL,
+  `last_updated` datetime NOT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 CREATE TABLE `phrases` (
   `id` bigint(20) NOT NULL AUTO_INCREMENT,
   `content` longtext NOT NULL,
   `character_id` bigint(20) NOT NULL,
+  `slug` varchar(70) NOT NULL,
+  `source_id` bigint(20) NULL,
+  `source_timestamp` bigint(20) NULL,
+  `upvotes` bigint(20) NOT NULL DEFAULT 0,
+  `downvotes` bigint(20) NOT NULL DEFAULT 0,
+  `score` double NOT NULL DEFAULT 0,
+  `status` varchar(16) NOT NULL DEFAULT 'approved',
+  `nsfw` tinyint(1) NOT NULL DEFAULT 0,
+  `rating` varchar(16) NOT NULL DEFAULT 'safe',
+  `content_warnings` varchar(255) NOT NULL DEFAULT '',
+  `submitted_by` varchar(255) NOT NULL DEFAULT '',
+  `moderated_by` varchar(255) NULL,
+  `moderation_reason` varchar(500) NULL,
+  `date_moderated` datetime NULL,
   `date_created` datetime NOT NULL,
   `last_updated` datetime NOT NULL,
+  `version` bigint(20) NOT NULL DEFAULT 1,
   PRIMARY KEY (`id`),
+  UNIQUE KEY `character_slug` (`character_id`, `slug`),
   KEY `fk_phrase_character` (`character_id`),
-  CONSTRAINT `fk_phrase_character` FOREIGN KEY (`character_id`) REFERENCES `characters` (`id`)
-) ENGINE=InnoDB AUTO_INCREMENT=9 DEFAULT CHARSET=utf8mb4
+  KEY `fk_phrase_source` (`source_id`, `source_timestamp`),
+  KEY `score` (`score`),
+  KEY `status` (`status`, `date_created`),
+  KEY `submitted_by` (`submitted_by`, `date_created`),
+  KEY `rating` (`rating`),
+  CONSTRAINT `fk_phrase_character` FOREIGN KEY (`character_id`) REFERENCES `characters` (`id`),
+  CONSTRAINT `fk_phrase_source` FOREIGN KEY (`source_id`) REFERENCES `sources` (`id`)
+) ENGINE=InnoDB AUTO_INCREMENT=9 DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `banned_terms` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `term` varchar(100) NOT NULL,
+  `match_mode` varchar(16) NOT NULL,
+  `policy` varchar(16) NOT NULL,
+  `rating` varchar(16) NOT NULL DEFAULT '',
+  `warning` varchar(32) NOT NULL DEFAULT '',
+  `character_id` bigint(20) NULL,
+  `date_created` datetime NOT NULL,
+  `last_updated` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `character_term` (`character_id`, `term`),
+  CONSTRAINT `fk_banned_term_character` FOREIGN KEY (`character_id`) REFERENCES `characters` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `reports` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `target_type` varchar(16) NOT NULL,
+  `target_id` bigint(20) NOT NULL,
+  `character_id` bigint(20) NOT NULL,
+  `reporter` varchar(255) NOT NULL,
+  `category` varchar(16) NOT NULL,
+  `details` varchar(1000) NOT NULL DEFAULT '',
+  `status` varchar(16) NOT NULL,
+  `note` varchar(500) NULL,
+  `closed_by` varchar(255) NULL,
+  `date_created` datetime NOT NULL,
+  `last_updated` datetime NOT NULL,
+  `date_closed` datetime NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `target_reporter` (`target_type`, `target_id`, `reporter`),
+  KEY `status` (`status`),
+  CONSTRAINT `fk_report_character` FOREIGN KEY (`character_id`) REFERENCES `characters` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `phrase_votes` (
+  `phrase_id` bigint(20) NOT NULL,
+  `voter` varchar(255) NOT NULL,
+  `value` tinyint(4) NOT NULL,
+  `date_created` datetime NOT NULL,
+  `last_updated` datetime NOT NULL,
+  PRIMARY KEY (`phrase_id`, `voter`),
+  KEY `last_updated` (`last_updated`),
+  CONSTRAINT `fk_vote_phrase` FOREIGN KEY (`phrase_id`) REFERENCES `phrases` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `phrase_counters` (
+  `phrase_id` bigint(20) NOT NULL,
+  `bucket` datetime NOT NULL,
+  `views` bigint(20) NOT NULL DEFAULT 0,
+  `renders` bigint(20) NOT NULL DEFAULT 0,
+  `shares` bigint(20) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`phrase_id`, `bucket`),
+  KEY `bucket` (`bucket`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `dialogues` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `title` varchar(255) NOT NULL DEFAULT '',
+  `nsfw` tinyint(1) NOT NULL DEFAULT 0,
+  `date_created` datetime NOT NULL,
+  `last_updated` datetime NOT NULL,
+  `version` bigint(20) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `dialogue_lines` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `dialogue_id` bigint(20) NOT NULL,
+  `position` int(11) NOT NULL,
+  `character_id` bigint(20) NOT NULL,
+  `phrase_id` bigint(20) NULL,
+  `content` longtext NOT NULL,
+  `rating` varchar(16) NOT NULL DEFAULT 'safe',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `dialogue_position` (`dialogue_id`, `position`),
+  KEY `fk_line_character` (`character_id`),
+  KEY `fk_line_phrase` (`phrase_id`),
+  CONSTRAINT `fk_line_dialogue` FOREIGN KEY (`dialogue_id`) REFERENCES `dialogues` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_line_character` FOREIGN KEY (`character_id`) REFERENCES `characters` (`id`),
+  CONSTRAINT `fk_line_phrase` FOREIGN KEY (`phrase_id`) REFERENCES `phrases` (`id`) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `collections` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `owner` varchar(255) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `visibility` varchar(10) NOT NULL DEFAULT 'private',
+  `share_token` varchar(32) NOT NULL,
+  `date_created` datetime NOT NULL,
+  `last_updated` datetime NOT NULL,
+  `version` bigint(20) NOT NULL DEFAULT 1,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `share_token` (`share_token`),
+  KEY `owner` (`owner`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `collection_phrases` (
+  `collection_id` bigint(20) NOT NULL,
+  `phrase_id` bigint(20) NOT NULL,
+  `position` int(11) NOT NULL,
+  `date_added` datetime NOT NULL,
+  PRIMARY KEY (`collection_id`, `phrase_id`),
+  KEY `collection_position` (`collection_id`, `position`),
+  KEY `fk_entry_phrase` (`phrase_id`),
+  CONSTRAINT `fk_entry_collection` FOREIGN KEY (`collection_id`) REFERENCES `collections` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `fk_entry_phrase` FOREIGN KEY (`phrase_id`) REFERENCES `phrases` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,267 @@
+package dialogue
+
+import (
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/filter"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dialogueRepository repository.DialogueRepository
+
+func Initialize(dlgRepo repository.DialogueRepository) {
+	dialogueRepository = dlgRepo
+}
+
+// GetDialogue returns a dialogue by its id
+func GetDialogue(c *gin.Context) {
+	rest.ErrorWrapper(getDialogue, c)
+}
+
+func getDialogue(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("dialogue-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting dialogue with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	dialogue, found, err := dialogueRepository.Get(ctx, id)
+	if err != nil {
+		logger.Error("get dialogue by id", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("dialogue %d not found", id))
+	}
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	if !model.RatingAtMost(dialogue.Rating(), maxRating) {
+		return rest.NewResourceNotFound(fmt.Sprintf("dialogue %d not found", id))
+	}
+
+	return rest.VersionedJSON(c, dialogue.Version, dialogue.LastUpdated, model.DialogueResultFromDialogue(dialogue))
+}
+
+// GetAllDialogues returns all dialogues wrapped in a json object
+func GetAllDialogues(c *gin.Context) {
+	rest.ErrorWrapper(getAllDialogues, c)
+}
+
+func getAllDialogues(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	dialogues, err := dialogueRepository.GetAll(ctx)
+	if err != nil {
+		logger.Error("get all dialogues", err)
+		return err
+	}
+	return dialoguesJSON(c, dialogues)
+}
+
+// GetDialoguesForCharacter returns the dialogues a character has a line in, wrapped in a json object
+func GetDialoguesForCharacter(c *gin.Context) {
+	rest.ErrorWrapper(getDialoguesForCharacter, c)
+}
+
+func getDialoguesForCharacter(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric characterId", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	dialogues, found, err := dialogueRepository.GetAllForCharacter(ctx, characterId)
+	if err != nil {
+		logger.Error("get dialogues of character", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", characterId))
+	}
+	return dialoguesJSON(c, dialogues)
+}
+
+// SaveDialogue saves a new dialogue
+func SaveDialogue(c *gin.Context) {
+	rest.ErrorWrapper(saveDialogue, c)
+}
+
+func saveDialogue(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	dlgCmd, err := bindDialogueCommand(c)
+	if err != nil {
+		logger.Error("creating dialogue bad body format", err)
+		return err
+	}
+	if err := filterLines(c, &dlgCmd); err != nil {
+		return err
+	}
+
+	dialogue, err := dialogueRepository.Save(ctx, dlgCmd)
+	if err != nil {
+		logger.Error("error creating dialogue", err)
+		return err
+	}
+
+	c.JSON(http.StatusCreated, model.DialogueResultFromDialogue(dialogue))
+	return nil
+}
+
+// UpdateDialogue replaces the title and lines of a dialogue
+func UpdateDialogue(c *gin.Context) {
+	rest.ErrorWrapper(updateDialogue, c)
+}
+
+func updateDialogue(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("dialogue-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting dialogue with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	dlgCmd, err := bindDialogueCommand(c)
+	if err != nil {
+		logger.Error("updating dialogue bad body format", err)
+		return err
+	}
+	if err := filterLines(c, &dlgCmd); err != nil {
+		return err
+	}
+
+	precondition, err := rest.IfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	dialogue, found, err := dialogueRepository.Update(ctx, id, dlgCmd, precondition)
+	if err != nil {
+		logger.Error("update dialogue by id", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("dialogue %d not found", id))
+	}
+
+	return rest.VersionedJSON(c, dialogue.Version, dialogue.LastUpdated, model.DialogueResultFromDialogue(dialogue))
+}
+
+// DeleteDialogue removes a dialogue and its lines
+func DeleteDialogue(c *gin.Context) {
+	rest.ErrorWrapper(deleteDialogue, c)
+}
+
+func deleteDialogue(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("dialogue-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting dialogue with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	precondition, err := rest.IfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	if err := dialogueRepository.Delete(ctx, id, precondition); err != nil {
+		logger.Error("error deleting dialogue", err)
+		return err
+	}
+
+	c.Status(http.StatusGone)
+	return nil
+}
+
+// bindDialogueCommand reads a dialogue from the body. Every line needs either its content or a phrase, and
+// a character unless it's a phrase
+func bindDialogueCommand(c *gin.Context) (model.DialogueCommand, error) {
+	var dlgCmd model.DialogueCommand
+	if err := c.ShouldBindJSON(&dlgCmd); err != nil {
+		return model.DialogueCommand{}, rest.NewValidationError(err)
+	}
+
+	dlgCmd.Title = strings.TrimSpace(dlgCmd.Title)
+	for i, line := range dlgCmd.Lines {
+		line.Content = strings.TrimSpace(line.Content)
+		switch {
+		case line.PhraseId != nil && line.Content != "":
+			return model.DialogueCommand{}, rest.NewBadRequest(fmt.Sprintf("line %d has both content and a phrase", i+1))
+		case line.PhraseId == nil && line.Content == "":
+			return model.DialogueCommand{}, rest.NewBadRequest(fmt.Sprintf("line %d needs content or a phrase", i+1))
+		case line.PhraseId == nil && line.CharacterId == 0:
+			return model.DialogueCommand{}, rest.NewBadRequest(fmt.Sprintf("line %d needs a character", i+1))
+		}
+		dlgCmd.Lines[i] = line
+	}
+	return dlgCmd, nil
+}
+
+// filterLines runs the lines with their own content through the content filter, like new phrases. A line with a
+// rejected term rejects the dialogue, the words of masked terms are masked and a NSFW term flags the dialogue.
+// Lines taken from phrases were filtered when the phrases were saved
+func filterLines(c *gin.Context, dlgCmd *model.DialogueCommand) error {
+	logger := commonContext.Logger(commonContext.RequestContext(c))
+
+	dlgCmd.NSFW = false
+	for i, line := range dlgCmd.Lines {
+		if line.PhraseId != nil {
+			continue
+		}
+		verdict := filter.Check(line.CharacterId, line.Content)
+		if len(verdict.Terms) > 0 {
+			logger.Info(fmt.Sprintf("Dialogue line %d for character %d has banned terms %q", i+1, line.CharacterId, verdict.Terms))
+		}
+		if verdict.Rejected {
+			return customErrors.NewValidationError("content", fmt.Sprintf("line %d has banned terms", i+1))
+		}
+		dlgCmd.Lines[i].Content = verdict.Content
+		dlgCmd.Lines[i].Rating = verdict.Rating
+		dlgCmd.NSFW = dlgCmd.NSFW || verdict.NSFW
+	}
+	return nil
+}
+
+// dialoguesJSON renders a list of dialogues wrapped in a json object, leaving out the ones rated above
+// ?max_rating=
+func dialoguesJSON(c *gin.Context, dialogues []model.Dialogue) error {
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	dialogues = model.DialoguesRatedUpTo(dialogues, maxRating)
+
+	dialogueResults := make([]model.DialogueResult, len(dialogues))
+	var lastModified time.Time
+	for i, dialogue := range dialogues {
+		dialogueResults[i] = model.DialogueResultFromDialogue(dialogue)
+		lastModified = rest.LatestUpdate(lastModified, dialogue.LastUpdated)
+	}
+
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
+		"results": dialogueResults,
+	})
+}
@@ -0,0 +1,374 @@
+package dialogue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/filter"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var dialogueMockRepo dialoguesMockRepository
+
+func TestSaveDialogueCreated(t *testing.T) {
+	t.Log("Saving a dialogue should return it with its lines in order and Created")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	phraseId := int64(4)
+	dlgCmd := model.NewDialogueCommand("La cena",
+		model.DialogueLineCommand{CharacterId: 1, Content: "¿Quién es?", Rating: model.RatingSafe},
+		model.DialogueLineCommand{PhraseId: &phraseId},
+	)
+	lines := []model.DialogueLine{
+		model.NewDialogueLine(1, 1, nil, "¿Quién es?"),
+		model.NewDialogueLine(2, 2, &phraseId, "Miameeee"),
+	}
+	dialogueMockRepo.On("Save", mock.Anything, dlgCmd).Return(model.NewDialogue(5, "La cena", lines, now, now), nil)
+
+	body, _ := json.Marshal(model.NewDialogueCommand(" La cena ",
+		model.DialogueLineCommand{CharacterId: 1, Content: " ¿Quién es? "},
+		model.DialogueLineCommand{PhraseId: &phraseId},
+	))
+	req := httptest.NewRequest(http.MethodPost, "/dialogue", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/dialogue", SaveDialogue)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.DialogueResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Len(t, actualResult.Lines, 2)
+	assert.Equal(t, int64(2), actualResult.Lines[1].CharacterId)
+	assert.Equal(t, "Miameeee", actualResult.Lines[1].Content)
+}
+
+func TestSaveDialogueInvalidLinesShouldFail(t *testing.T) {
+	t.Log("A single line, or lines without content or character, should return Bad Request")
+
+	phraseId := int64(4)
+	for _, dlgCmd := range []model.DialogueCommand{
+		model.NewDialogueCommand("", model.DialogueLineCommand{CharacterId: 1, Content: "¿Quién es?"}),
+		model.NewDialogueCommand("",
+			model.DialogueLineCommand{CharacterId: 1, Content: "¿Quién es?"},
+			model.DialogueLineCommand{CharacterId: 2, Content: "  "},
+		),
+		model.NewDialogueCommand("",
+			model.DialogueLineCommand{CharacterId: 1, Content: "¿Quién es?"},
+			model.DialogueLineCommand{Content: "Yo"},
+		),
+		model.NewDialogueCommand("",
+			model.DialogueLineCommand{CharacterId: 1, Content: "¿Quién es?"},
+			model.DialogueLineCommand{CharacterId: 2, PhraseId: &phraseId, Content: "Yo"},
+		),
+	} {
+		w := httptest.NewRecorder()
+
+		resetMocks()
+
+		body, _ := json.Marshal(dlgCmd)
+		req := httptest.NewRequest(http.MethodPost, "/dialogue", bytes.NewBuffer(body))
+
+		r := utils.TestRouter()
+		r.POST("/dialogue", SaveDialogue)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		dialogueMockRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	}
+}
+
+func TestSaveDialogueSingleCharacterShouldFail(t *testing.T) {
+	t.Log("A dialogue the repository rejects should return its error")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	dialogueMockRepo.On("Save", mock.Anything, mock.Anything).
+		Return(model.Dialogue{}, customErrors.NewValidationError("lines", "a dialogue needs lines of at least two characters"))
+
+	body, _ := json.Marshal(model.NewDialogueCommand("",
+		model.DialogueLineCommand{CharacterId: 1, Content: "¿Quién es?"},
+		model.DialogueLineCommand{CharacterId: 1, Content: "Yo"},
+	))
+	req := httptest.NewRequest(http.MethodPost, "/dialogue", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/dialogue", SaveDialogue)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateDialogue(t *testing.T) {
+	t.Log("Update dialogue should pass the If-Match version to the repository and return the new version")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	dialogue := model.NewDialogue(5, "La cena", nil, now, now)
+	dialogue.Version = 4
+	dialogueMockRepo.On("Update", mock.Anything, int64(5), mock.Anything, model.NewPrecondition(3)).Return(dialogue, true, nil)
+
+	body, _ := json.Marshal(model.NewDialogueCommand("La cena",
+		model.DialogueLineCommand{CharacterId: 1, Content: "¿Quién es?"},
+		model.DialogueLineCommand{CharacterId: 2, Content: "Yo"},
+	))
+	req := httptest.NewRequest(http.MethodPatch, "/dialogue/5", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"v3"`)
+
+	r := utils.TestRouter()
+	r.PATCH("/dialogue/:dialogue-id", UpdateDialogue)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v4"`, w.Header().Get("ETag"))
+}
+
+func TestUpdateDialogueFiltered(t *testing.T) {
+	t.Log("Lines with their own content should go through the content filter, like new phrases")
+
+	resetMocks()
+
+	filter.Initialize(bannedTermsRepository{
+		model.NewBannedTerm(1, "chanta", model.MatchExact, model.PolicyReject, nil),
+		model.NewBannedTerm(2, "garca", model.MatchStem, model.PolicyNSFW, nil),
+		model.NewBannedTerm(3, "bobo", model.MatchLeet, model.PolicyMask, nil),
+	})
+	defer filter.Initialize(nil)
+	assert.NoError(t, filter.Reload(context.Background()))
+
+	now := time.Now()
+	phraseId := int64(4)
+	dialogueMockRepo.On("Update", mock.Anything, int64(5), mock.MatchedBy(func(dlgCmd model.DialogueCommand) bool {
+		return dlgCmd.NSFW && dlgCmd.Lines[0].Content == "Qué ****, qué garcas"
+	}), mock.Anything).Return(model.NewDialogue(5, "La cena", nil, now, now), true, nil)
+
+	r := utils.TestRouter()
+	r.PATCH("/dialogue/:dialogue-id", UpdateDialogue)
+
+	w := httptest.NewRecorder()
+	body, _ := json.Marshal(model.NewDialogueCommand("La cena",
+		model.DialogueLineCommand{CharacterId: 1, Content: "Sos un chanta"},
+		model.DialogueLineCommand{PhraseId: &phraseId},
+	))
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/dialogue/5", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	body, _ = json.Marshal(model.NewDialogueCommand("La cena",
+		model.DialogueLineCommand{CharacterId: 1, Content: "Qué b0bo, qué garcas"},
+		model.DialogueLineCommand{PhraseId: &phraseId},
+	))
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/dialogue/5", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	dialogueMockRepo.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func TestGetDialogueNotFound(t *testing.T) {
+	t.Log("A dialogue that doesn't exist should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	dialogueMockRepo.On("Get", mock.Anything, int64(5)).Return(model.Dialogue{}, false, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/dialogue/5", nil)
+
+	r := utils.TestRouter()
+	r.GET("/dialogue/:dialogue-id", GetDialogue)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetDialoguesForCharacter(t *testing.T) {
+	t.Log("The dialogues a character has lines in should be returned")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	dialogues := []model.Dialogue{model.NewDialogue(5, "La cena", nil, now, now)}
+	dialogueMockRepo.On("GetAllForCharacter", mock.Anything, int64(2)).Return(dialogues, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/2/dialogues", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/dialogues", GetDialoguesForCharacter)
+	r.ServeHTTP(w, req)
+
+	actualResult := map[string][]model.DialogueResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(5), actualResult["results"][0].ID)
+}
+
+func TestGetDialoguesUpToMaxRating(t *testing.T) {
+	t.Log("Dialogues with a line rated above ?max_rating= should be left out, and not found by id")
+
+	resetMocks()
+
+	now := time.Now()
+	phraseId := int64(4)
+	explicitLine := model.NewDialogueLine(2, 2, &phraseId, "no me toquen")
+	explicitLine.Rating = model.RatingExplicit
+	dialogues := []model.Dialogue{
+		model.NewDialogue(5, "La cena", []model.DialogueLine{model.NewDialogueLine(1, 1, nil, "¿Quién es?")}, now, now),
+		model.NewDialogue(6, "El after", []model.DialogueLine{model.NewDialogueLine(1, 1, nil, "¿Quién es?"), explicitLine}, now, now),
+	}
+	dialogueMockRepo.On("GetAllForCharacter", mock.Anything, int64(2)).Return(dialogues, true, nil)
+	dialogueMockRepo.On("Get", mock.Anything, int64(6)).Return(dialogues[1], true, nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/dialogues", GetDialoguesForCharacter)
+	r.GET("/dialogue/:dialogue-id", GetDialogue)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/character/2/dialogues?max_rating=safe", nil))
+	actualResult := map[string][]model.DialogueResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, actualResult["results"], 1)
+	assert.Equal(t, int64(5), actualResult["results"][0].ID)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dialogue/6?max_rating=mature", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteDialogue(t *testing.T) {
+	t.Log("Delete dialogue should return Gone")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	dialogueMockRepo.On("Delete", mock.Anything, int64(5), model.Precondition{}).Return(nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/dialogue/5", nil)
+
+	r := utils.TestRouter()
+	r.DELETE("/dialogue/:dialogue-id", DeleteDialogue)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+}
+
+func resetMocks() {
+	dialogueMockRepo = dialoguesMockRepository{}
+	dialogueRepository = &dialogueMockRepo
+}
+
+type dialoguesMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *dialoguesMockRepository) Get(ctx context.Context, id int64) (model.Dialogue, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	dialogue, ok := args.Get(0).(model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogue, found, args.Error(2)
+}
+
+func (repoMock *dialoguesMockRepository) GetAll(ctx context.Context) ([]model.Dialogue, error) {
+	args := repoMock.Called(ctx)
+
+	dialogues, ok := args.Get(0).([]model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogues, args.Error(1)
+}
+
+func (repoMock *dialoguesMockRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Dialogue, bool, error) {
+	args := repoMock.Called(ctx, characterId)
+
+	dialogues, ok := args.Get(0).([]model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogues, found, args.Error(2)
+}
+
+func (repoMock *dialoguesMockRepository) Save(ctx context.Context, dlgCmd model.DialogueCommand) (model.Dialogue, error) {
+	args := repoMock.Called(ctx, dlgCmd)
+
+	dialogue, ok := args.Get(0).(model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogue, args.Error(1)
+}
+
+func (repoMock *dialoguesMockRepository) Update(ctx context.Context, id int64, dlgCmd model.DialogueCommand, precondition model.Precondition) (model.Dialogue, bool, error) {
+	args := repoMock.Called(ctx, id, dlgCmd, precondition)
+
+	dialogue, ok := args.Get(0).(model.Dialogue)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return dialogue, found, args.Error(2)
+}
+
+func (repoMock *dialoguesMockRepository) Delete(ctx context.Context, id int64, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, id, precondition)
+	return args.Error(0)
+}
+
+type bannedTermsRepository []model.BannedTerm
+
+func (repo bannedTermsRepository) GetAll(ctx context.Context) ([]model.BannedTerm, error) {
+	return repo, nil
+}
+
+func (repo bannedTermsRepository) Save(ctx context.Context, termCmd model.BannedTermCommand) (model.BannedTerm, error) {
+	return model.BannedTerm{}, errors.New("not implemented")
+}
+
+func (repo bannedTermsRepository) Delete(ctx context.Context, id int64) error {
+	return errors.New("not implemented")
+}
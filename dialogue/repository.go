@@ -0,0 +1,289 @@
+package dialogue
+
+import (
+	"context"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type DBDialogueRepository struct {
+	cluster *database.Cluster
+}
+
+func NewDBDialogueRepository(cluster *database.Cluster) DBDialogueRepository {
+	return DBDialogueRepository{
+		cluster: cluster,
+	}
+}
+
+// withLines loads the lines of the dialogues read, in the order they are said
+func withLines(db *gorm.DB) *gorm.DB {
+	return db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+}
+
+func (repo DBDialogueRepository) Get(ctx context.Context, id int64) (model.Dialogue, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Dialogue with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	dialogue := model.Dialogue{}
+	notFound := false
+	err := database.RetryRead(ctx, "getting dialogue", func() error {
+		result := withLines(db).Where("id = ?", id).Find(&dialogue)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return model.Dialogue{}, false, database.TranslateError(err)
+	}
+	return dialogue, !notFound, nil
+}
+
+func (repo DBDialogueRepository) GetAll(ctx context.Context) ([]model.Dialogue, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug("Getting all Dialogues")
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	dialogues := make([]model.Dialogue, 0)
+	err := database.RetryRead(ctx, "getting all dialogues", func() error {
+		return withLines(db).Order("id").Find(&dialogues).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return dialogues, nil
+}
+
+func (repo DBDialogueRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Dialogue, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Dialogues of character %d", characterId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	dialogues := make([]model.Dialogue, 0)
+	found := false
+	err := database.RetryRead(ctx, "getting dialogues of character", func() error {
+		result := db.Where("id = ?", characterId).Find(&model.Character{})
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		return withLines(db).Where("id IN (SELECT dialogue_id FROM dialogue_lines WHERE character_id = ?)", characterId).
+			Order("id").Find(&dialogues).Error
+	})
+	if err != nil {
+		return nil, false, database.TranslateError(err)
+	}
+	return dialogues, found, nil
+}
+
+func (repo DBDialogueRepository) Save(ctx context.Context, dlgCmd model.DialogueCommand) (model.Dialogue, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Creating Dialogue with %d lines", len(dlgCmd.Lines)))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	dialogue := model.NewDialogue(0, dlgCmd.Title, nil, now, now)
+	dialogue.NSFW = dlgCmd.NSFW
+	dialogue.Version = 1
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "creating dialogue", func(tx *gorm.DB) error {
+		lines, err := resolveLines(tx, dlgCmd.Lines)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&dialogue).Error; err != nil {
+			return err
+		}
+		dialogue.Lines, err = createLines(tx, dialogue.ID, lines)
+		return err
+	})
+	if err != nil {
+		logger.Error("creating dialogue", err)
+		return model.Dialogue{}, database.TranslateError(err)
+	}
+	return dialogue, nil
+}
+
+func (repo DBDialogueRepository) Update(ctx context.Context, id int64, dlgCmd model.DialogueCommand, precondition model.Precondition) (model.Dialogue, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Updating Dialogue with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	dialogue := model.Dialogue{}
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "updating dialogue", func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Find(&dialogue)
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if !precondition.Allows(dialogue.Version) {
+			return dialogueModifiedError(dialogue)
+		}
+		lines, err := resolveLines(tx, dlgCmd.Lines)
+		if err != nil {
+			return err
+		}
+
+		// The version check makes the update fail if another write got in since the dialogue was read
+		read := dialogue
+		now := time.Now()
+		result = tx.Model(&dialogue).Where("version = ?", read.Version).Updates(map[string]interface{}{
+			"title":        dlgCmd.Title,
+			"nsfw":         dlgCmd.NSFW,
+			"last_updated": now,
+			"version":      read.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return dialogueModifiedError(read)
+		}
+		if err := tx.Where("dialogue_id = ?", id).Delete(&model.DialogueLine{}).Error; err != nil {
+			return err
+		}
+		dialogue.Lines, err = createLines(tx, id, lines)
+		if err != nil {
+			return err
+		}
+
+		dialogue.Title = dlgCmd.Title
+		dialogue.NSFW = dlgCmd.NSFW
+		dialogue.LastUpdated = now
+		dialogue.Version = read.Version + 1
+		return nil
+	})
+	if err != nil {
+		logger.Error("updating dialogue", err)
+		return model.Dialogue{}, found, database.TranslateError(err)
+	}
+	if !found {
+		return model.Dialogue{}, false, nil
+	}
+	return dialogue, true, nil
+}
+
+func (repo DBDialogueRepository) Delete(ctx context.Context, id int64, precondition model.Precondition) error {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Deleting Dialogue with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "deleting dialogue", func(tx *gorm.DB) error {
+		dialogue := model.Dialogue{}
+		result := tx.Where("id = ?", id).Find(&dialogue)
+		if result.RecordNotFound() {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if !precondition.Allows(dialogue.Version) {
+			return dialogueModifiedError(dialogue)
+		}
+
+		if err := tx.Where("dialogue_id = ?", id).Delete(&model.DialogueLine{}).Error; err != nil {
+			return err
+		}
+		result = tx.Where("version = ?", dialogue.Version).Delete(&dialogue)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return dialogueModifiedError(dialogue)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("deleting dialogue", err)
+		return database.TranslateError(err)
+	}
+	return nil
+}
+
+// resolveLines turns the lines of a command into dialogue lines. A line taken from a phrase gets the phrase's
+// content, and its character when it's left out. Every character and phrase must exist, and a dialogue needs
+// at least two characters
+func resolveLines(tx *gorm.DB, lineCmds []model.DialogueLineCommand) ([]model.DialogueLine, error) {
+	lines := make([]model.DialogueLine, len(lineCmds))
+	characters := make(map[int64]bool)
+	for i, lineCmd := range lineCmds {
+		line := model.NewDialogueLine(i+1, lineCmd.CharacterId, lineCmd.PhraseId, lineCmd.Content)
+		if lineCmd.PhraseId != nil {
+			phrase := model.Phrase{}
+			result := tx.Where("id = ? AND status = ?", *lineCmd.PhraseId, model.PhraseStatusApproved).Find(&phrase)
+			if result.RecordNotFound() {
+				return nil, customErrors.NewNotFoundError(fmt.Sprintf("phrase %d not found", *lineCmd.PhraseId))
+			}
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			if line.CharacterId == 0 {
+				line.CharacterId = phrase.CharacterId
+			}
+			if line.CharacterId != phrase.CharacterId {
+				return nil, customErrors.NewValidationError("lines", fmt.Sprintf("phrase %d doesn't belong to character %d", phrase.ID, line.CharacterId))
+			}
+			line.Content = phrase.Content
+			line.Rating = phrase.Rating
+		} else {
+			line.Rating = model.StrongerRating(lineCmd.Rating, model.RatingSafe)
+		}
+		characters[line.CharacterId] = true
+		lines[i] = line
+	}
+	if len(characters) < 2 {
+		return nil, customErrors.NewValidationError("lines", "a dialogue needs lines of at least two characters")
+	}
+
+	ids := make([]int64, 0, len(characters))
+	for id := range characters {
+		ids = append(ids, id)
+	}
+	count := 0
+	if err := tx.Model(&model.Character{}).Where("id IN (?)", ids).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count != len(ids) {
+		return nil, customErrors.NewNotFoundError("a character of the dialogue was not found")
+	}
+	return lines, nil
+}
+
+func createLines(tx *gorm.DB, dialogueId int64, lines []model.DialogueLine) ([]model.DialogueLine, error) {
+	for i := range lines {
+		lines[i].DialogueId = dialogueId
+		if err := tx.Create(&lines[i]).Error; err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+func dialogueModifiedError(dialogue model.Dialogue) error {
+	return customErrors.NewPreconditionFailedError(fmt.Sprintf("dialogue %d was modified, its version is no longer %d", dialogue.ID, dialogue.Version))
+}
@@ -13,3 +13,130 @@ func NewUnauthorizedError(message string) UnauthorizedError {
 func (err UnauthorizedError) Error() string {
 	return err.Message
 }
+
+// NotFoundError is returned when a resource, or a resource it references, doesn't exist
+type NotFoundError struct {
+	Message string
+}
+
+// NewNotFoundError is a constructor for NotFoundError
+func NewNotFoundError(message string) NotFoundError {
+	return NotFoundError{
+		Message: message,
+	}
+}
+
+func (err NotFoundError) Error() string {
+	return err.Message
+}
+
+// ConflictError is returned when an operation clashes with the current state of a resource,
+// like creating a character with a name that is already taken
+type ConflictError struct {
+	Message string
+}
+
+// NewConflictError is a constructor for ConflictError
+func NewConflictError(message string) ConflictError {
+	return ConflictError{
+		Message: message,
+	}
+}
+
+func (err ConflictError) Error() string {
+	return err.Message
+}
+
+// PreconditionFailedError is returned when a write expects a version of a resource that is no longer current
+type PreconditionFailedError struct {
+	Message string
+}
+
+// NewPreconditionFailedError is a constructor for PreconditionFailedError
+func NewPreconditionFailedError(message string) PreconditionFailedError {
+	return PreconditionFailedError{
+		Message: message,
+	}
+}
+
+func (err PreconditionFailedError) Error() string {
+	return err.Message
+}
+
+// ForbiddenError is returned when the caller is known but not allowed to perform an operation
+type ForbiddenError struct {
+	Message string
+}
+
+// NewForbiddenError is a constructor for ForbiddenError
+func NewForbiddenError(message string) ForbiddenError {
+	return ForbiddenError{
+		Message: message,
+	}
+}
+
+func (err ForbiddenError) Error() string {
+	return err.Message
+}
+
+// ValidationError is returned when a value is not acceptable. Field is empty when it's not tied to a single field
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+// NewValidationError is a constructor for ValidationError
+func NewValidationError(field string, message string) ValidationError {
+	return ValidationError{
+		Field:   field,
+		Message: message,
+	}
+}
+
+func (err ValidationError) Error() string {
+	return err.Message
+}
+
+// UnavailableError is returned when a dependency, like the database, can't be reached
+type UnavailableError struct {
+	Message string
+	Cause   error
+}
+
+// NewUnavailableError is a constructor for UnavailableError
+func NewUnavailableError(message string, cause error) UnavailableError {
+	return UnavailableError{
+		Message: message,
+		Cause:   cause,
+	}
+}
+
+func (err UnavailableError) Error() string {
+	if err.Cause == nil {
+		return err.Message
+	}
+	return err.Message + ": " + err.Cause.Error()
+}
+
+// Unwrap returns the error that made the dependency unavailable
+func (err UnavailableError) Unwrap() error {
+	return err.Cause
+}
+
+// DuplicateError is returned when a new resource would duplicate an existing one
+type DuplicateError struct {
+	Message    string
+	ExistingID int64
+}
+
+// NewDuplicateError is a constructor for DuplicateError
+func NewDuplicateError(message string, existingID int64) DuplicateError {
+	return DuplicateError{
+		Message:    message,
+		ExistingID: existingID,
+	}
+}
+
+func (err DuplicateError) Error() string {
+	return err.Message
+}
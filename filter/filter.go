@@ -0,0 +1,236 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/slug"
+)
+
+// minStemLength keeps the stemmer from cutting short words down to a couple of letters, which would match
+// unrelated words
+const minStemLength = 3
+
+// suffixes are the endings the stemmer removes, the longest first. They cover the plurals, genders and common
+// endings of Spanish and English words
+var suffixes = []string{
+	"amente", "mente", "aciones", "acion", "iendo", "ando", "ados", "adas", "ado", "ada",
+	"ing", "ers", "er", "es", "os", "as", "ed", "s", "o", "a", "e",
+}
+
+// leet maps the digits and symbols written for letters in leetspeak to the letters
+var leet = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g', '@': 'a', '$': 's',
+}
+
+// Filter finds the banned terms in phrases. A Filter is immutable, the terms are compiled into a new one
+// whenever they change
+type Filter struct {
+	global      []compiledTerm
+	byCharacter map[int64][]compiledTerm
+}
+
+type compiledTerm struct {
+	term  model.BannedTerm
+	words []string // The words of the term, normalized for its match
+}
+
+// word is a word of a phrase, with where it is in the phrase and its normalized forms
+type word struct {
+	start int
+	end   int
+	keys  map[string]string // By match
+}
+
+// New compiles the terms into a Filter. The terms of a character replace the same terms of every character
+// for its phrases
+func New(terms []model.BannedTerm) *Filter {
+	filter := &Filter{byCharacter: make(map[int64][]compiledTerm)}
+	overrides := make(map[int64]map[string]bool)
+	for _, term := range terms {
+		compiled := compile(term)
+		if len(compiled.words) == 0 {
+			continue
+		}
+		if term.CharacterId == nil {
+			filter.global = append(filter.global, compiled)
+			continue
+		}
+		characterId := *term.CharacterId
+		filter.byCharacter[characterId] = append(filter.byCharacter[characterId], compiled)
+		if overrides[characterId] == nil {
+			overrides[characterId] = make(map[string]bool)
+		}
+		overrides[characterId][Normalize(term.Term)] = true
+	}
+	for characterId, overridden := range overrides {
+		for _, compiled := range filter.global {
+			if !overridden[Normalize(compiled.term.Term)] {
+				filter.byCharacter[characterId] = append(filter.byCharacter[characterId], compiled)
+			}
+		}
+	}
+	return filter
+}
+
+// Check decides on the content of a phrase of a character. A rejection wins over every other policy, and a
+// phrase is flagged as NSFW even when some of its terms are masked. The rating suggested is the strongest one
+// of the terms found, and at least mature when one of them is NSFW
+func (f *Filter) Check(characterId int64, content string) model.FilterVerdict {
+	verdict := model.FilterVerdict{Content: content, Rating: model.RatingSafe}
+	if f == nil {
+		return verdict
+	}
+	terms, ok := f.byCharacter[characterId]
+	if !ok {
+		terms = f.global
+	}
+	if len(terms) == 0 {
+		return verdict
+	}
+
+	words := split(content)
+	masked := []rune(content)
+	runeIndex := runeIndexes(content)
+	for _, compiled := range terms {
+		spans := compiled.find(words)
+		if len(spans) == 0 || compiled.term.Policy == model.PolicyAllow {
+			continue
+		}
+		verdict.Terms = append(verdict.Terms, compiled.term.Term)
+		verdict.Rating = model.StrongerRating(verdict.Rating, compiled.term.Rating)
+		if compiled.term.Warning != "" {
+			verdict.Warnings = append(verdict.Warnings, compiled.term.Warning)
+		}
+		switch compiled.term.Policy {
+		case model.PolicyReject:
+			verdict.Rejected = true
+		case model.PolicyNSFW:
+			verdict.NSFW = true
+			verdict.Rating = model.StrongerRating(verdict.Rating, model.RatingMature)
+		case model.PolicyMask:
+			for _, span := range spans {
+				for i := runeIndex[span[0]]; i < runeIndex[span[1]]; i++ {
+					if !unicode.IsSpace(masked[i]) {
+						masked[i] = '*'
+					}
+				}
+			}
+		}
+	}
+	verdict.Content = string(masked)
+	return verdict
+}
+
+// find returns where the term is in the words, as byte offsets of the content
+func (t compiledTerm) find(words []word) [][2]int {
+	var spans [][2]int
+	for i := 0; i+len(t.words) <= len(words); i++ {
+		matches := true
+		for j, termWord := range t.words {
+			if words[i+j].keys[t.term.Match] != termWord {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			spans = append(spans, [2]int{words[i].start, words[i+len(t.words)-1].end})
+		}
+	}
+	return spans
+}
+
+func compile(term model.BannedTerm) compiledTerm {
+	compiled := compiledTerm{term: term}
+	for _, w := range split(term.Term) {
+		compiled.words = append(compiled.words, w.keys[term.Match])
+	}
+	return compiled
+}
+
+// split finds the words of a text. Leetspeak symbols are part of the words, so h@te is one word
+func split(text string) []word {
+	var words []word
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' || r == '$'
+		if inWord && start < 0 {
+			start = i
+		}
+		if !inWord && start >= 0 {
+			words = append(words, newWord(text, start, i))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, newWord(text, start, len(text)))
+	}
+	return words
+}
+
+func newWord(text string, start int, end int) word {
+	exact := Normalize(text[start:end])
+	return word{
+		start: start,
+		end:   end,
+		keys: map[string]string{
+			model.MatchExact: exact,
+			model.MatchStem:  Stem(exact),
+			model.MatchLeet:  Stem(Unleet(text[start:end])),
+		},
+	}
+}
+
+// runeIndexes maps the byte offsets of a text to the index of their rune, with one more for the end of the text
+func runeIndexes(text string) map[int]int {
+	indexes := make(map[int]int, len(text)+1)
+	count := 0
+	for i := range text {
+		indexes[i] = count
+		count++
+	}
+	indexes[len(text)] = count
+	return indexes
+}
+
+// Normalize lowers the case of a term and removes its accents, keeping only its letters and digits
+func Normalize(text string) string {
+	var normalized strings.Builder
+	for _, r := range slug.Transliterate(strings.ToLower(text)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			normalized.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(normalized.String()), " ")
+}
+
+// Stem removes the ending of a normalized word, so its plural and other forms have the same stem
+func Stem(word string) string {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= minStemLength {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}
+
+// Unleet normalizes a word written in leetspeak: the symbols are read as the letters they stand for, and
+// stretched letters count once
+func Unleet(text string) string {
+	var unleeted strings.Builder
+	var previous rune
+	for _, r := range slug.Transliterate(strings.ToLower(text)) {
+		if letter, ok := leet[r]; ok {
+			r = letter
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+		if r != previous {
+			unleeted.WriteRune(r)
+		}
+		previous = r
+	}
+	return unleeted.String()
+}
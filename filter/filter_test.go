@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMatches(t *testing.T) {
+	t.Log("Terms should be matched as whole words, exactly, by their stem or undoing leetspeak")
+
+	filter := New([]model.BannedTerm{
+		model.NewBannedTerm(1, "gato", model.MatchExact, model.PolicyReject, nil),
+		model.NewBannedTerm(2, "perro", model.MatchStem, model.PolicyReject, nil),
+		model.NewBannedTerm(3, "rata", model.MatchLeet, model.PolicyReject, nil),
+	})
+
+	for content, rejected := range map[string]bool{
+		"Sos un GATO!":         true,
+		"Sos un gáto":          true,
+		"Sos un gatito":        false,
+		"Unos gatos":           false,
+		"Tus perros":           true,
+		"Perra vida":           true,
+		"Perrito":              false,
+		"Sos una r4t4":         true,
+		"Sos una raaaaata":     true,
+		"Sos una r@tas":        true,
+		"Piratas del Caribe":   false,
+		"Miameeee, que mambo!": false,
+	} {
+		assert.Equal(t, rejected, filter.Check(1, content).Rejected, content)
+	}
+}
+
+func TestCheckTermOfManyWords(t *testing.T) {
+	t.Log("A term of many words should only match them together and in order")
+
+	filter := New([]model.BannedTerm{model.NewBannedTerm(1, "mala onda", model.MatchExact, model.PolicyNSFW, nil)})
+
+	assert.True(t, filter.Check(1, "Qué mala, onda!").NSFW)
+	assert.False(t, filter.Check(1, "Onda mala").NSFW)
+	assert.False(t, filter.Check(1, "Mala").NSFW)
+}
+
+func TestCheckPolicies(t *testing.T) {
+	t.Log("A rejection should win, and a phrase should be flagged as NSFW with its masked terms masked")
+
+	filter := New([]model.BannedTerm{
+		model.NewBannedTerm(1, "bobo", model.MatchExact, model.PolicyMask, nil),
+		model.NewBannedTerm(2, "garca", model.MatchExact, model.PolicyNSFW, nil),
+		model.NewBannedTerm(3, "chanta", model.MatchExact, model.PolicyReject, nil),
+	})
+
+	verdict := filter.Check(1, "Qué bobo, qué garca. BOBÓ!")
+	assert.False(t, verdict.Rejected)
+	assert.True(t, verdict.NSFW)
+	assert.Equal(t, "Qué ****, qué garca. ****!", verdict.Content)
+	assert.ElementsMatch(t, []string{"bobo", "garca"}, verdict.Terms)
+
+	verdict = filter.Check(1, "Bobo y chanta")
+	assert.True(t, verdict.Rejected)
+}
+
+func TestCheckSuggestsRating(t *testing.T) {
+	t.Log("The strongest rating of the terms found should be suggested, at least mature for NSFW terms, with their warnings")
+
+	mask := model.NewBannedTerm(1, "bobo", model.MatchExact, model.PolicyMask, nil)
+	mask.Warning = "language"
+	explicit := model.NewBannedTerm(2, "garca", model.MatchExact, model.PolicyMask, nil)
+	explicit.Rating = model.RatingExplicit
+	filter := New([]model.BannedTerm{mask, explicit, model.NewBannedTerm(3, "chanta", model.MatchExact, model.PolicyNSFW, nil)})
+
+	verdict := filter.Check(1, "Miameee")
+	assert.Equal(t, model.RatingSafe, verdict.Rating)
+
+	verdict = filter.Check(1, "Bobo y chanta")
+	assert.Equal(t, model.RatingMature, verdict.Rating)
+	assert.Equal(t, []string{"language"}, verdict.Warnings)
+
+	verdict = filter.Check(1, "Bobo y garca")
+	assert.Equal(t, model.RatingExplicit, verdict.Rating)
+}
+
+func TestCheckCharacterOverrides(t *testing.T) {
+	t.Log("The terms of a character should replace the same terms of every character for its phrases")
+
+	characterId := int64(2)
+	filter := New([]model.BannedTerm{
+		model.NewBannedTerm(1, "gato", model.MatchExact, model.PolicyReject, nil),
+		model.NewBannedTerm(2, "bobo", model.MatchExact, model.PolicyReject, nil),
+		model.NewBannedTerm(3, "Gato", model.MatchExact, model.PolicyAllow, &characterId),
+		model.NewBannedTerm(4, "bobo", model.MatchExact, model.PolicyMask, &characterId),
+		model.NewBannedTerm(5, "mambo", model.MatchExact, model.PolicyReject, &characterId),
+	})
+
+	assert.True(t, filter.Check(1, "Gato").Rejected)
+	assert.False(t, filter.Check(2, "Gato").Rejected)
+	assert.Equal(t, "****", filter.Check(2, "bobo").Content)
+	assert.True(t, filter.Check(2, "Qué mambo").Rejected)
+	assert.False(t, filter.Check(1, "Qué mambo").Rejected)
+}
+
+func TestCheckWithoutFilter(t *testing.T) {
+	t.Log("Nothing should be filtered before the terms are loaded")
+
+	var filter *Filter
+	verdict := filter.Check(1, "Sos un gato")
+
+	assert.False(t, verdict.Rejected)
+	assert.Equal(t, "Sos un gato", verdict.Content)
+}
@@ -0,0 +1,149 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var bannedTermRepository repository.BannedTermRepository
+
+// current is the *Filter phrases are checked with. It's replaced as a whole on every reload
+var current atomic.Value
+
+func Initialize(termRepo repository.BannedTermRepository) {
+	bannedTermRepository = termRepo
+	current.Store((*Filter)(nil))
+}
+
+// Reload compiles the banned terms into the filter phrases are checked with. The filter in use is kept when
+// the terms can't be read
+func Reload(ctx context.Context) error {
+	terms, err := bannedTermRepository.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	current.Store(New(terms))
+	commonContext.Logger(ctx).Debug(fmt.Sprintf("Content filter reloaded with %d terms", len(terms)))
+	return nil
+}
+
+// Watch reloads the filter every interval until stop is closed, so the terms changed through another
+// instance are applied by this one too
+func Watch(interval time.Duration, stop <-chan struct{}) {
+	ctx := commonContext.AppContext(context.Background())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := Reload(ctx); err != nil {
+				commonContext.Logger(ctx).Error("reloading the content filter", err)
+			}
+		}
+	}
+}
+
+// Check decides on the content of a phrase of a character with the filter in use. Nothing is filtered before
+// the first reload
+func Check(characterId int64, content string) model.FilterVerdict {
+	filter, _ := current.Load().(*Filter)
+	return filter.Check(characterId, content)
+}
+
+// GetBannedTerms returns every term of the content filter wrapped in a json object
+func GetBannedTerms(c *gin.Context) {
+	rest.ErrorWrapper(getBannedTerms, c)
+}
+
+func getBannedTerms(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	terms, err := bannedTermRepository.GetAll(ctx)
+	if err != nil {
+		logger.Error("get all banned terms", err)
+		return err
+	}
+
+	termResults := make([]model.BannedTermResult, len(terms))
+	for i, term := range terms {
+		termResults[i] = model.BannedTermResultFromBannedTerm(term)
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"results": termResults,
+	})
+	return nil
+}
+
+// SaveBannedTerm adds a term to the content filter, and applies it right away on this instance
+func SaveBannedTerm(c *gin.Context) {
+	rest.ErrorWrapper(saveBannedTerm, c)
+}
+
+func saveBannedTerm(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	var termCmd model.BannedTermCommand
+	if err := c.ShouldBindJSON(&termCmd); err != nil {
+		logger.Error("creating banned term bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	if termCmd.Policy == model.PolicyAllow && termCmd.CharacterId == nil {
+		return rest.NewBadRequest("only a term of a character may be allowed")
+	}
+
+	term, err := bannedTermRepository.Save(ctx, termCmd)
+	if err != nil {
+		logger.Error("error creating banned term", err)
+		return err
+	}
+	reload(ctx)
+
+	c.JSON(http.StatusCreated, model.BannedTermResultFromBannedTerm(term))
+	return nil
+}
+
+// DeleteBannedTerm removes a term from the content filter, and stops applying it right away on this instance
+func DeleteBannedTerm(c *gin.Context) {
+	rest.ErrorWrapper(deleteBannedTerm, c)
+}
+
+func deleteBannedTerm(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("term-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting banned term with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	if err := bannedTermRepository.Delete(ctx, id); err != nil {
+		logger.Error("error deleting banned term", err)
+		return err
+	}
+	reload(ctx)
+
+	c.Status(http.StatusGone)
+	return nil
+}
+
+// reload applies a change of the terms. The change is already stored, so a failure is only logged and the
+// next periodic reload applies it
+func reload(ctx context.Context) {
+	if err := Reload(ctx); err != nil {
+		commonContext.Logger(ctx).Error("reloading the content filter", err)
+	}
+}
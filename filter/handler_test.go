@@ -0,0 +1,144 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var termMockRepo bannedTermsMockRepository
+
+func TestSaveBannedTermAppliesIt(t *testing.T) {
+	t.Log("A new banned term should be applied right away")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	term := model.NewBannedTerm(1, "gato", model.MatchExact, model.PolicyReject, nil)
+	termMockRepo.On("Save", mock.Anything, model.BannedTermCommand{Term: "Gato", Policy: model.PolicyReject}).Return(term, nil)
+	termMockRepo.On("GetAll", mock.Anything).Return([]model.BannedTerm{term}, nil)
+
+	r := utils.TestRouter()
+	r.POST("/admin/banned-term", SaveBannedTerm)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/banned-term", bytes.NewBufferString(`{"term": "Gato", "policy": "reject"}`)))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"match":"exact"`)
+	assert.True(t, Check(1, "Sos un gato").Rejected)
+}
+
+func TestSaveBannedTermBadBody(t *testing.T) {
+	t.Log("A term with an unknown policy or match, or allowed for every character, should return Bad Request")
+
+	r := utils.TestRouter()
+	r.POST("/admin/banned-term", SaveBannedTerm)
+
+	for _, body := range []string{
+		`{"term": "gato", "policy": "ban"}`,
+		`{"term": "gato", "policy": "reject", "match": "fuzzy"}`,
+		`{"term": "gato", "policy": "allow"}`,
+		`{"policy": "reject"}`,
+	} {
+		resetMocks()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/banned-term", bytes.NewBufferString(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	termMockRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestSaveBannedTermConflict(t *testing.T) {
+	t.Log("A term already banned should return Conflict")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	termMockRepo.On("Save", mock.Anything, mock.Anything).Return(model.BannedTerm{}, customErrors.NewConflictError(`term "gato" is already banned`))
+
+	r := utils.TestRouter()
+	r.POST("/admin/banned-term", SaveBannedTerm)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/banned-term", bytes.NewBufferString(`{"term": "gato", "policy": "mask"}`)))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDeleteBannedTermStopsApplyingIt(t *testing.T) {
+	t.Log("A deleted banned term should stop being applied right away, and return Gone")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	termMockRepo.On("GetAll", mock.Anything).Return([]model.BannedTerm{model.NewBannedTerm(1, "gato", model.MatchExact, model.PolicyReject, nil)}, nil).Once()
+	assert.NoError(t, Reload(context.Background()))
+	assert.True(t, Check(1, "gato").Rejected)
+
+	termMockRepo.On("Delete", mock.Anything, int64(1)).Return(nil)
+	termMockRepo.On("GetAll", mock.Anything).Return([]model.BannedTerm{}, nil)
+
+	r := utils.TestRouter()
+	r.DELETE("/admin/banned-term/:term-id", DeleteBannedTerm)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/admin/banned-term/1", nil))
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.False(t, Check(1, "gato").Rejected)
+}
+
+func TestReloadKeepsFilterOnError(t *testing.T) {
+	t.Log("The filter in use should be kept when the terms can't be read")
+
+	resetMocks()
+
+	termMockRepo.On("GetAll", mock.Anything).Return([]model.BannedTerm{model.NewBannedTerm(1, "gato", model.MatchExact, model.PolicyReject, nil)}, nil).Once()
+	termMockRepo.On("GetAll", mock.Anything).Return([]model.BannedTerm{}, errors.New("DB error"))
+
+	assert.NoError(t, Reload(context.Background()))
+	assert.Error(t, Reload(context.Background()))
+	assert.True(t, Check(1, "gato").Rejected)
+}
+
+func resetMocks() {
+	termMockRepo = bannedTermsMockRepository{}
+	Initialize(&termMockRepo)
+}
+
+type bannedTermsMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *bannedTermsMockRepository) GetAll(ctx context.Context) ([]model.BannedTerm, error) {
+	args := repoMock.Called(ctx)
+
+	terms, ok := args.Get(0).([]model.BannedTerm)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return terms, args.Error(1)
+}
+
+func (repoMock *bannedTermsMockRepository) Save(ctx context.Context, termCmd model.BannedTermCommand) (model.BannedTerm, error) {
+	args := repoMock.Called(ctx, termCmd)
+
+	term, ok := args.Get(0).(model.BannedTerm)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return term, args.Error(1)
+}
+
+func (repoMock *bannedTermsMockRepository) Delete(ctx context.Context, id int64) error {
+	args := repoMock.Called(ctx, id)
+	return args.Error(0)
+}
@@ -0,0 +1,103 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type DBBannedTermRepository struct {
+	cluster *database.Cluster
+}
+
+func NewDBBannedTermRepository(cluster *database.Cluster) DBBannedTermRepository {
+	return DBBannedTermRepository{
+		cluster: cluster,
+	}
+}
+
+func (repo DBBannedTermRepository) GetAll(ctx context.Context) ([]model.BannedTerm, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug("Getting all banned terms")
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	terms := make([]model.BannedTerm, 0)
+	err := database.RetryRead(ctx, "getting all banned terms", func() error {
+		return db.Order("character_id IS NOT NULL, character_id, term, id").Find(&terms).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return terms, nil
+}
+
+// Save stores the term normalized, so the same term written differently is found already banned
+func (repo DBBannedTermRepository) Save(ctx context.Context, termCmd model.BannedTermCommand) (model.BannedTerm, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Creating banned term %q", termCmd.Term))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	term := model.NewBannedTerm(0, Normalize(termCmd.Term), termCmd.Match, termCmd.Policy, termCmd.CharacterId)
+	if term.Match == "" {
+		term.Match = model.MatchExact
+	}
+	term.Rating = termCmd.Rating
+	term.Warning = termCmd.Warning
+	term.DateCreated = now
+	term.LastUpdated = now
+	if term.Term == "" {
+		return model.BannedTerm{}, customErrors.NewValidationError("term", "term must have letters or digits")
+	}
+
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "creating banned term", func(tx *gorm.DB) error {
+		existing := tx.Where("term = ?", term.Term)
+		if term.CharacterId == nil {
+			existing = existing.Where("character_id IS NULL")
+		} else {
+			result := tx.Where("id = ?", *term.CharacterId).Find(&model.Character{})
+			if result.RecordNotFound() {
+				return customErrors.NewNotFoundError(fmt.Sprintf("character %d not found", *term.CharacterId))
+			}
+			if result.Error != nil {
+				return result.Error
+			}
+			existing = existing.Where("character_id = ?", *term.CharacterId)
+		}
+
+		result := existing.Find(&model.BannedTerm{})
+		if result.Error == nil {
+			return customErrors.NewConflictError(fmt.Sprintf("term %q is already banned", term.Term))
+		}
+		if !result.RecordNotFound() {
+			return result.Error
+		}
+		return tx.Create(&term).Error
+	})
+	if err != nil {
+		logger.Error("creating banned term", err)
+		return model.BannedTerm{}, database.TranslateError(err)
+	}
+	return term, nil
+}
+
+func (repo DBBannedTermRepository) Delete(ctx context.Context, id int64) error {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Deleting banned term %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if err := repo.cluster.Writer(ctx).Where("id = ?", id).Delete(&model.BannedTerm{}).Error; err != nil {
+		logger.Error("deleting banned term", err)
+		return database.TranslateError(err)
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ go 1.14
 require (
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-akka/configuration v0.0.0-20200606091224-a002c0330665
+	github.com/go-playground/validator/v10 v10.2.0
+	github.com/go-sql-driver/mysql v1.4.1
 	github.com/jinzhu/gorm v1.9.12
 	github.com/satori/go.uuid v1.2.0
 	github.com/sirupsen/logrus v1.6.0
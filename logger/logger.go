@@ -30,8 +30,8 @@ var log = logrus.Logger{
 
 //NewLogger creates a creditsLogger instance
 func NewLogger(tags map[string]interface{}) *SupportLogger {
-	c := config.Conf
-	level, err := logrus.ParseLevel(c.GetString("log.level", logrus.DebugLevel.String()))
+	c := config.Current().LogConfig
+	level, err := logrus.ParseLevel(c.Level)
 	if err != nil {
 		log.Println("Malformed log level configuration. Use configuration by default logging level INFO", err)
 		level = logrus.DebugLevel
@@ -40,8 +40,8 @@ func NewLogger(tags map[string]interface{}) *SupportLogger {
 	log.SetLevel(level)
 	sl := &SupportLogger{log.WithFields(tags)}
 	sl.Logger.SetOutput(&lumberjack.Logger{
-		Filename: c.GetString("log.file_path"),
-		MaxAge:   int(c.GetInt32("log.max_age", 1)),
+		Filename: c.FilePath,
+		MaxAge:   c.MaxAge,
 	})
 	return sl
 }
@@ -1,24 +1,53 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"github.com/airabinovich/memequotes_back/cache"
 	"github.com/airabinovich/memequotes_back/character"
+	"github.com/airabinovich/memequotes_back/collection"
 	"github.com/airabinovich/memequotes_back/config"
 	"github.com/airabinovich/memequotes_back/database"
+	"github.com/airabinovich/memequotes_back/dialogue"
+	"github.com/airabinovich/memequotes_back/filter"
 	"github.com/airabinovich/memequotes_back/phrase"
+	"github.com/airabinovich/memequotes_back/report"
+	"github.com/airabinovich/memequotes_back/repository"
 	"github.com/airabinovich/memequotes_back/router"
+	"github.com/airabinovich/memequotes_back/server"
+	"github.com/airabinovich/memequotes_back/source"
+	"github.com/airabinovich/memequotes_back/trending"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
 	"log"
 	"os"
+	"strings"
 )
 
 type commandFlags struct {
+	configFile      string
 	credentialsFile string
+	overrides       overrideFlag
 }
 
-func parseFlags() (commandFlags, error) {
+// overrideFlag collects repeated --set key=value flags
+type overrideFlag map[string]string
+
+func (o overrideFlag) String() string {
+	return fmt.Sprint(map[string]string(o))
+}
+
+func (o overrideFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return errors.New("expected key=value")
+	}
+	o[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	return nil
+}
+
+func parseFlags(args []string) (commandFlags, error) {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
 		log.Println("ERROR: could not get home directory")
@@ -26,20 +55,93 @@ func parseFlags() (commandFlags, error) {
 	}
 	defaultCredentialsFile := fmt.Sprintf("%s/credentials.conf", homedir)
 
-	var credentialsFile string
-	flag.StringVar(&credentialsFile, "credentials", defaultCredentialsFile, "The environment in which the application is running")
-	flag.Parse()
-	return commandFlags{
-		credentialsFile: credentialsFile,
-	}, nil
+	flags := commandFlags{overrides: overrideFlag{}}
+	flagSet := flag.NewFlagSet("memequotes", flag.ExitOnError)
+	flagSet.StringVar(&flags.configFile, "config", "application.conf", "The main HOCON configuration file")
+	flagSet.StringVar(&flags.credentialsFile, "credentials", defaultCredentialsFile, "The environment in which the application is running")
+	flagSet.Var(flags.overrides, "set", "Override a configuration key, as key=value. May be repeated")
+	if err := flagSet.Parse(args); err != nil {
+		return commandFlags{}, err
+	}
+	return flags, nil
+}
+
+func loadConfig(args []string) (config.Sources, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return config.Sources{}, err
+	}
+	sources := config.Sources{
+		ConfigFile:      flags.configFile,
+		CredentialsFile: flags.credentialsFile,
+		Environ:         os.Environ(),
+		Overrides:       flags.overrides,
+	}
+	_, err = config.Load(sources)
+	return sources, err
+}
+
+// printConfig dumps the effective configuration with secrets redacted
+func printConfig(args []string) {
+	if _, err := loadConfig(args); err != nil {
+		log.Fatal(err)
+	}
+	if err := config.Print(os.Stdout, config.Conf); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// backfillSlugs gives a slug to the characters and phrases stored before slugs existed
+func backfillSlugs(args []string) {
+	if _, err := loadConfig(args); err != nil {
+		log.Fatal(err)
+	}
+	if err := database.Initialize(); err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := database.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	ctx := context.Background()
+	characters, err := character.NewDBCharacterRepository(database.DBCluster).BackfillSlugs(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Gave a slug to %d characters", characters)
+
+	phrases, err := phrase.NewDBPhraseRepository(database.DBCluster).BackfillSlugs(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Gave a slug to %d phrases", phrases)
 }
 
 func main() {
 
-	flags, _ := parseFlags()
-	config.LoadCredentials(flags.credentialsFile)
+	args := os.Args[1:]
+	if len(args) >= 2 && args[0] == "config" && args[1] == "print" {
+		printConfig(args[2:])
+		return
+	}
+	if len(args) >= 2 && args[0] == "slugs" && args[1] == "backfill" {
+		backfillSlugs(args[2:])
+		return
+	}
 
-	err := database.Initialize()
+	sources, err := loadConfig(args)
+	if err != nil {
+		panic(err)
+	}
+	if config.Current().Reload.Enabled {
+		stopWatching := make(chan struct{})
+		defer close(stopWatching)
+		go config.Watch(sources, stopWatching)
+	}
+
+	err = database.Initialize()
 	if err != nil {
 		panic(err)
 	}
@@ -49,14 +151,43 @@ func main() {
 		}
 	}()
 
-	characterRepository := character.NewDBCharacterRepository(database.DB)
-	phraseRepository := phrase.NewDBPhraseRepository(database.DB)
+	var characterRepository repository.CharacterRepository = character.NewDBCharacterRepository(database.DBCluster)
+	var phraseRepository repository.PhraseRepository = phrase.NewDBPhraseRepository(database.DBCluster)
+	var sourceRepository repository.SourceRepository = source.NewDBSourceRepository(database.DBCluster)
+	var dialogueRepository repository.DialogueRepository = dialogue.NewDBDialogueRepository(database.DBCluster)
+	var collectionRepository repository.CollectionRepository = collection.NewDBCollectionRepository(database.DBCluster)
+	if cacheConfig := config.Current().Cache; cacheConfig.Enabled {
+		repositoryCache := cache.New(cacheConfig.MaxEntries, cacheConfig.TTL)
+		characterRepository = cache.NewCharacterRepository(characterRepository, repositoryCache)
+		phraseRepository = cache.NewPhraseRepository(phraseRepository, repositoryCache)
+		cache.Initialize(repositoryCache)
+	}
+
+	character.Initialize(characterRepository, phraseRepository, dialogueRepository)
+	phrase.Initialize(phraseRepository, sourceRepository)
+	source.Initialize(sourceRepository)
+	dialogue.Initialize(dialogueRepository)
+	collection.Initialize(collectionRepository)
+
+	// Phrases are not saved unfiltered: the service doesn't start without the banned terms
+	filter.Initialize(filter.NewDBBannedTermRepository(database.DBCluster))
+	if err := filter.Reload(context.Background()); err != nil {
+		panic(err)
+	}
+	stopFilterReloads := make(chan struct{})
+	defer close(stopFilterReloads)
+	go filter.Watch(config.Current().Filter.ReloadInterval, stopFilterReloads)
 
-	character.Initialize(characterRepository, phraseRepository)
-	phrase.Initialize(phraseRepository)
+	// Counts are written one last time when the service stops, once the server is shut down on SIGINT or SIGTERM
+	var trendingRepository repository.TrendingRepository = trending.NewDBTrendingRepository(database.DBCluster)
+	counter := trending.NewCounter(trendingRepository, config.Current().Trending)
+	counter.Start()
+	defer counter.Stop()
+	trending.Initialize(trendingRepository, phraseRepository, counter)
+	report.Initialize(report.NewDBReportRepository(database.DBCluster), phraseRepository, characterRepository)
 
 	engine := router.Route()
-	if err := engine.Run(":9000"); err != nil {
+	if err := server.Run(router.Handler(engine)); err != nil {
 		println("Backend service could not be started")
 		panic(err)
 	}
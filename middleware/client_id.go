@@ -0,0 +1,14 @@
+package middleware
+
+import (
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientID adds the id of the client making the request to the request context. Clients are told
+// apart by their IP address
+func ClientID(c *gin.Context) {
+	requestCtx := commonContext.WithClientID(commonContext.RequestContext(c), c.ClientIP())
+	commonContext.WithRequestContext(requestCtx, c)
+	c.Next()
+}
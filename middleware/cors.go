@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS adds the cross-origin headers for allowed origins and answers preflight requests.
+// Settings are read on every request, so they follow configuration reloads
+func CORS(c *gin.Context) {
+	cors := config.Current().CORS
+	origin := c.GetHeader("Origin")
+	if !cors.Enabled || origin == "" {
+		c.Next()
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Add("Vary", "Origin")
+	if !cors.AllowsOrigin(origin) {
+		c.Next()
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", origin)
+	if len(cors.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+	}
+
+	if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+		header.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+		header.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+		c.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	c.Next()
+}
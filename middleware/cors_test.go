@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	t.Log("A preflight request from an allowed origin should be answered with the allowed methods")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{
+		"cors.enabled":         "true",
+		"cors.allowed_origins": `["https://memequotes.com"]`,
+	}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	router := utils.TestRouter()
+	router.Use(CORS)
+	router.GET("/characters", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := utils.PerformRequest(router, http.MethodOptions, "/characters", map[string]string{
+		"Origin":                        "https://memequotes.com",
+		"Access-Control-Request-Method": http.MethodGet,
+	})
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://memequotes.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodGet)
+}
+
+func TestCORSUnknownOrigin(t *testing.T) {
+	t.Log("Requests from origins that are not allowed should not get CORS headers")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{
+		"cors.enabled":         "true",
+		"cors.allowed_origins": `["https://memequotes.com"]`,
+	}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	router := utils.TestRouter()
+	router.Use(CORS)
+	router.GET("/characters", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := utils.PerformRequest(router, http.MethodGet, "/characters", map[string]string{"Origin": "https://evil.com"})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
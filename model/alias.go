@@ -0,0 +1,58 @@
+package model
+
+import (
+	"github.com/airabinovich/memequotes_back/utils"
+	"time"
+)
+
+// AliasResult is the type to be shown in the API for an Alias
+type AliasResult struct {
+	ID          int64              `json:"id"`
+	CharacterId int64              `json:"character_id"`
+	Alias       string             `json:"alias"`
+	DateCreated *utils.ISO8601Time `json:"date_created"`
+}
+
+// AliasResultFromAlias creates an AliasResult from an Alias
+func AliasResultFromAlias(alias Alias) AliasResult {
+	dateCreated := utils.ISO8601Time(alias.DateCreated)
+	return AliasResult{
+		ID:          alias.ID,
+		CharacterId: alias.CharacterId,
+		Alias:       alias.Alias,
+		DateCreated: &dateCreated,
+	}
+}
+
+// Alias is another name a character goes by. Names and aliases are unique together
+type Alias struct {
+	ID          int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	CharacterId int64
+	Alias       string    `gorm:"unique"`
+	DateCreated time.Time `gorm:"column:date_created;type:datetime;not null"`
+}
+
+// TableName keeps the aliases next to the characters they belong to
+func (Alias) TableName() string {
+	return "character_aliases"
+}
+
+// NewAlias is a constructor for Alias
+func NewAlias(id int64, characterId int64, alias string, dateCreated time.Time) Alias {
+	return Alias{
+		ID:          id,
+		CharacterId: characterId,
+		Alias:       alias,
+		DateCreated: dateCreated,
+	}
+}
+
+// AliasCommand contains the info to add an alias to a character
+type AliasCommand struct {
+	Alias string `json:"alias" binding:"required,max=100"`
+}
+
+// NewAliasCommand is a constructor for AliasCommand
+func NewAliasCommand(alias string) AliasCommand {
+	return AliasCommand{Alias: alias}
+}
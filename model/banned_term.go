@@ -0,0 +1,99 @@
+package model
+
+import (
+	"github.com/airabinovich/memequotes_back/utils"
+	"time"
+)
+
+// How a banned term is matched against the words of a phrase. Exact matches the word ignoring case and
+// accents, stem also matches its plurals and other endings, and leet also undoes leetspeak and stretched letters
+const (
+	MatchExact = "exact"
+	MatchStem  = "stem"
+	MatchLeet  = "leet"
+)
+
+// What happens to a phrase with a banned term. Allow is only given to a character, to lift a term banned for
+// every character
+const (
+	PolicyReject = "reject"
+	PolicyNSFW   = "nsfw"
+	PolicyMask   = "mask"
+	PolicyAllow  = "allow"
+)
+
+// BannedTermResult is the type to be shown in the API for a BannedTerm
+type BannedTermResult struct {
+	ID          int64              `json:"id"`
+	Term        string             `json:"term"`
+	Match       string             `json:"match"`
+	Policy      string             `json:"policy"`
+	Rating      string             `json:"rating"`
+	Warning     string             `json:"warning"`
+	CharacterId *int64             `json:"character_id"`
+	DateCreated *utils.ISO8601Time `json:"date_created"`
+	LastUpdated *utils.ISO8601Time `json:"last_updated"`
+}
+
+// BannedTermResultFromBannedTerm creates a BannedTermResult from a BannedTerm
+func BannedTermResultFromBannedTerm(term BannedTerm) BannedTermResult {
+	dateCreated := utils.ISO8601Time(term.DateCreated)
+	lastUpdated := utils.ISO8601Time(term.LastUpdated)
+	return BannedTermResult{
+		ID:          term.ID,
+		Term:        term.Term,
+		Match:       term.Match,
+		Policy:      term.Policy,
+		Rating:      term.Rating,
+		Warning:     term.Warning,
+		CharacterId: term.CharacterId,
+		DateCreated: &dateCreated,
+		LastUpdated: &lastUpdated,
+	}
+}
+
+// BannedTerm is a word, or words, of the content filter. A term of a character overrides the same term of
+// every character for its phrases. Besides its policy, a term suggests a rating and a content warning for the
+// phrases it's found in
+type BannedTerm struct {
+	ID          int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	Term        string
+	Match       string `gorm:"column:match_mode"` // MATCH is reserved by MySQL
+	Policy      string
+	Rating      string    // Empty when the term suggests none, the nsfw policy suggests mature
+	Warning     string    // Empty when the term suggests none
+	CharacterId *int64    // Nil when the term is banned for every character
+	DateCreated time.Time `gorm:"column:date_created;type:datetime;not null"`
+	LastUpdated time.Time `gorm:"column:last_updated;type:datetime;not null"`
+}
+
+// NewBannedTerm is a constructor for BannedTerm
+func NewBannedTerm(id int64, term string, match string, policy string, characterId *int64) BannedTerm {
+	return BannedTerm{
+		ID:          id,
+		Term:        term,
+		Match:       match,
+		Policy:      policy,
+		CharacterId: characterId,
+	}
+}
+
+// BannedTermCommand contains the info to add a term to the content filter. It's matched exactly by default
+type BannedTermCommand struct {
+	Term        string `json:"term" binding:"required,max=100"`
+	Match       string `json:"match" binding:"omitempty,oneof=exact stem leet"`
+	Policy      string `json:"policy" binding:"required,oneof=reject nsfw mask allow"`
+	Rating      string `json:"rating" binding:"omitempty,oneof=mature explicit"`
+	Warning     string `json:"warning" binding:"omitempty,oneof=language sexual violence drugs discrimination self_harm"`
+	CharacterId *int64 `json:"character_id" binding:"omitempty,min=1"`
+}
+
+// FilterVerdict is what the content filter decides on a phrase
+type FilterVerdict struct {
+	Rejected bool
+	NSFW     bool
+	Rating   string   // The strongest rating suggested by the terms found, safe when none suggests one
+	Warnings []string // The content warnings suggested by the terms found
+	Content  string   // The phrase with the terms to mask masked
+	Terms    []string // The banned terms found
+}
@@ -9,8 +9,11 @@ import (
 type CharacterResult struct {
 	ID          int64              `json:"id"`
 	Name        string             `json:"name"`
+	Slug        string             `json:"slug"`
+	Hidden      bool               `json:"hidden"`
 	DateCreated *utils.ISO8601Time `json:"date_created"`
 	LastUpdated *utils.ISO8601Time `json:"last_updated"`
+	Version     int64              `json:"version"`
 }
 
 // NewCharacterResult is a constructor for CharacterResult
@@ -30,17 +33,32 @@ func CharacterResultFromCharacter(ch Character) CharacterResult {
 	return CharacterResult{
 		ID:          ch.ID,
 		Name:        ch.Name,
+		Slug:        ch.Slug,
+		Hidden:      ch.Hidden,
 		DateCreated: &dateCreated,
 		LastUpdated: &lastUpdated,
+		Version:     ch.Version,
 	}
 }
 
+// JoinVisibleCharacters joins phrases to their characters, leaving out the phrases of hidden characters
+const JoinVisibleCharacters = "JOIN characters ON characters.id = phrases.character_id AND characters.hidden = false"
+
 // Character represents a character that may own phrases
 type Character struct {
 	ID          int64     `gorm:"primary_key;AUTO_INCREMENT"`
 	Name        string    `gorm:"unique"`
+	Slug        string    `gorm:"unique"` // Identifies the character in URLs, like its id
+	Hidden      bool      // Hidden after its reports, only moderators see it
 	DateCreated time.Time `gorm:"column:date_created;type:datetime;not null"`
 	LastUpdated time.Time `gorm:"column:last_updated;type:datetime;not null"`
+	Version     int64     `gorm:"column:version;not null"` // Incremented on every update
+}
+
+// VisibleTo tells whether a client may see a character. Characters hidden after their reports are only seen
+// by moderators
+func (ch Character) VisibleTo(moderator bool) bool {
+	return !ch.Hidden || moderator
 }
 
 // NewCharacter is a constructor for Character
@@ -62,3 +80,15 @@ type CharacterCommand struct {
 func NewCharacterCommand(name string) CharacterCommand {
 	return CharacterCommand{Name: name}
 }
+
+// SlugRedirect keeps a slug a character went by before a rename pointing at it
+type SlugRedirect struct {
+	Slug        string `gorm:"primary_key"`
+	CharacterId int64
+	DateCreated time.Time `gorm:"column:date_created;type:datetime;not null"`
+}
+
+// TableName keeps the redirects next to the characters they point at
+func (SlugRedirect) TableName() string {
+	return "character_slug_redirects"
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"github.com/airabinovich/memequotes_back/utils"
+	"time"
+)
+
+// Visibilities of a collection. Anyone can read a public collection, and only its owner a private one. Both
+// can be read by anyone with their share token
+const (
+	CollectionPublic  = "public"
+	CollectionPrivate = "private"
+)
+
+// CollectionResult is the type to be shown in the API for a Collection
+type CollectionResult struct {
+	ID          int64              `json:"id"`
+	Name        string             `json:"name"`
+	Visibility  string             `json:"visibility"`
+	ShareToken  string             `json:"share_token,omitempty"` // Only shown to the owner
+	PhraseIds   []int64            `json:"phrase_ids"`
+	DateCreated *utils.ISO8601Time `json:"date_created"`
+	LastUpdated *utils.ISO8601Time `json:"last_updated"`
+	Version     int64              `json:"version"`
+}
+
+// CollectionResultFromCollection creates a CollectionResult from a Collection, without its share token
+func CollectionResultFromCollection(collection Collection) CollectionResult {
+	dateCreated := utils.ISO8601Time(collection.DateCreated)
+	lastUpdated := utils.ISO8601Time(collection.LastUpdated)
+	phraseIds := make([]int64, len(collection.Entries))
+	for i, entry := range collection.Entries {
+		phraseIds[i] = entry.PhraseId
+	}
+	return CollectionResult{
+		ID:          collection.ID,
+		Name:        collection.Name,
+		Visibility:  collection.Visibility,
+		PhraseIds:   phraseIds,
+		DateCreated: &dateCreated,
+		LastUpdated: &lastUpdated,
+		Version:     collection.Version,
+	}
+}
+
+// Collection is a named list of phrases a client put together, in the order it chose
+type Collection struct {
+	ID          int64  `gorm:"primary_key;AUTO_INCREMENT"`
+	Owner       string // The client id of the API key that created it
+	Name        string
+	Visibility  string
+	ShareToken  string            // Secret that gives read access to the collection
+	Entries     []CollectionEntry `gorm:"foreignkey:CollectionId"`
+	DateCreated time.Time         `gorm:"column:date_created;type:datetime;not null"`
+	LastUpdated time.Time         `gorm:"column:last_updated;type:datetime;not null"`
+	Version     int64             `gorm:"column:version;not null"` // Incremented on every update
+}
+
+// NewCollection is a constructor for Collection
+func NewCollection(id int64, owner string, name string, visibility string, dateCreated time.Time, lastUpdated time.Time) Collection {
+	return Collection{
+		ID:          id,
+		Owner:       owner,
+		Name:        name,
+		Visibility:  visibility,
+		DateCreated: dateCreated,
+		LastUpdated: lastUpdated,
+	}
+}
+
+// RatedUpTo returns the collection with the phrases a client that takes up to the maximum rating may be shown
+func (collection Collection) RatedUpTo(maximum string) Collection {
+	entries := make([]CollectionEntry, 0, len(collection.Entries))
+	for _, entry := range collection.Entries {
+		if RatingAtMost(entry.Rating, maximum) {
+			entries = append(entries, entry)
+		}
+	}
+	collection.Entries = entries
+	return collection
+}
+
+// CollectionEntry is a phrase in a collection. Entries are listed by position, which may have gaps when
+// phrases are removed
+type CollectionEntry struct {
+	CollectionId int64 `gorm:"primary_key;auto_increment:false"`
+	PhraseId     int64 `gorm:"primary_key;auto_increment:false"`
+	Position     int
+	DateAdded    time.Time `gorm:"column:date_added;type:datetime;not null"`
+	Rating       string    `gorm:"-"` // The one of the phrase, read with the collection
+}
+
+// TableName keeps the entries next to the collections they are in
+func (CollectionEntry) TableName() string {
+	return "collection_phrases"
+}
+
+// CollectionCommand contains the info to create a Collection or rename it. A collection is private unless
+// it says otherwise, and an update without a visibility keeps the one it has
+type CollectionCommand struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	Visibility string `json:"visibility" binding:"omitempty,oneof=public private"`
+}
+
+// NewCollectionCommand is a constructor for CollectionCommand
+func NewCollectionCommand(name string, visibility string) CollectionCommand {
+	return CollectionCommand{
+		Name:       name,
+		Visibility: visibility,
+	}
+}
+
+// CollectionEntryCommand contains the info to add a phrase to a Collection. The phrase goes at the position,
+// starting at 1, or last when it's left out
+type CollectionEntryCommand struct {
+	PhraseId int64 `json:"phrase_id" binding:"required"`
+	Position int   `json:"position" binding:"min=0"`
+}
+
+// NewCollectionEntryCommand is a constructor for CollectionEntryCommand
+func NewCollectionEntryCommand(phraseId int64, position int) CollectionEntryCommand {
+	return CollectionEntryCommand{
+		PhraseId: phraseId,
+		Position: position,
+	}
+}
+
+// CollectionOrderCommand lists every phrase of a Collection in their new order
+type CollectionOrderCommand struct {
+	PhraseIds []int64 `json:"phrase_ids" binding:"required"`
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"github.com/airabinovich/memequotes_back/utils"
+	"time"
+)
+
+// DialogueLineResult is the type to be shown in the API for a DialogueLine
+type DialogueLineResult struct {
+	Position    int    `json:"position"`
+	CharacterId int64  `json:"character_id"`
+	PhraseId    *int64 `json:"phrase_id"`
+	Content     string `json:"content"`
+}
+
+// DialogueResult is the type to be shown in the API for a Dialogue
+type DialogueResult struct {
+	ID          int64                `json:"id"`
+	Title       string               `json:"title"`
+	Lines       []DialogueLineResult `json:"lines"`
+	NSFW        bool                 `json:"nsfw"`
+	Rating      string               `json:"rating"`
+	DateCreated *utils.ISO8601Time   `json:"date_created"`
+	LastUpdated *utils.ISO8601Time   `json:"last_updated"`
+	Version     int64                `json:"version"`
+}
+
+// DialogueResultFromDialogue creates a DialogueResult from a Dialogue
+func DialogueResultFromDialogue(dialogue Dialogue) DialogueResult {
+	dateCreated := utils.ISO8601Time(dialogue.DateCreated)
+	lastUpdated := utils.ISO8601Time(dialogue.LastUpdated)
+	lines := make([]DialogueLineResult, len(dialogue.Lines))
+	for i, line := range dialogue.Lines {
+		lines[i] = DialogueLineResult{
+			Position:    line.Position,
+			CharacterId: line.CharacterId,
+			PhraseId:    line.PhraseId,
+			Content:     line.Content,
+		}
+	}
+	return DialogueResult{
+		ID:          dialogue.ID,
+		Title:       dialogue.Title,
+		Lines:       lines,
+		NSFW:        dialogue.NSFW,
+		Rating:      dialogue.Rating(),
+		DateCreated: &dateCreated,
+		LastUpdated: &lastUpdated,
+		Version:     dialogue.Version,
+	}
+}
+
+// Dialogue is an exchange of lines between characters, in the order they are said
+type Dialogue struct {
+	ID          int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	Title       string
+	Lines       []DialogueLine `gorm:"foreignkey:DialogueId"`
+	NSFW        bool           `gorm:"column:nsfw"` // Flagged by the content filter on one of its lines
+	DateCreated time.Time      `gorm:"column:date_created;type:datetime;not null"`
+	LastUpdated time.Time      `gorm:"column:last_updated;type:datetime;not null"`
+	Version     int64          `gorm:"column:version;not null"` // Incremented on every update
+}
+
+// Rating is the strongest rating of the lines of the dialogue
+func (dialogue Dialogue) Rating() string {
+	rating := RatingSafe
+	for _, line := range dialogue.Lines {
+		rating = StrongerRating(rating, line.Rating)
+	}
+	return rating
+}
+
+// DialoguesRatedUpTo keeps the dialogues a client that takes up to the maximum rating may be shown
+func DialoguesRatedUpTo(dialogues []Dialogue, maximum string) []Dialogue {
+	rated := make([]Dialogue, 0, len(dialogues))
+	for _, dialogue := range dialogues {
+		if RatingAtMost(dialogue.Rating(), maximum) {
+			rated = append(rated, dialogue)
+		}
+	}
+	return rated
+}
+
+// NewDialogue is a constructor for Dialogue
+func NewDialogue(id int64, title string, lines []DialogueLine, dateCreated time.Time, lastUpdated time.Time) Dialogue {
+	return Dialogue{
+		ID:          id,
+		Title:       title,
+		Lines:       lines,
+		DateCreated: dateCreated,
+		LastUpdated: lastUpdated,
+	}
+}
+
+// DialogueLine is what a character says in a dialogue. A line taken from a phrase keeps its content even if
+// the phrase is removed
+type DialogueLine struct {
+	ID          int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	DialogueId  int64
+	Position    int // Lines are said in the order of their position, starting at 1
+	CharacterId int64
+	PhraseId    *int64
+	Content     string
+	Rating      string // The one of the phrase the line is taken from, or the one the content filter suggests
+}
+
+// NewDialogueLine is a constructor for DialogueLine
+func NewDialogueLine(position int, characterId int64, phraseId *int64, content string) DialogueLine {
+	return DialogueLine{
+		Position:    position,
+		CharacterId: characterId,
+		PhraseId:    phraseId,
+		Content:     content,
+	}
+}
+
+// DialogueLineCommand contains the info of a line of a dialogue: a character and what it says, either as
+// text or as one of its phrases. The character may be left out when the line is a phrase
+type DialogueLineCommand struct {
+	CharacterId int64  `json:"character_id"`
+	PhraseId    *int64 `json:"phrase_id"`
+	Content     string `json:"content"`
+	Rating      string `json:"-"` // Suggested by the content filter
+}
+
+// DialogueCommand contains the info to create a Dialogue. Lines are said in the order they are listed
+type DialogueCommand struct {
+	Title string                `json:"title" binding:"max=255"`
+	Lines []DialogueLineCommand `json:"lines" binding:"required,min=2,max=50,dive"`
+	NSFW  bool                  `json:"-"` // Set by the content filter
+}
+
+// NewDialogueCommand is a constructor for DialogueCommand
+func NewDialogueCommand(title string, lines ...DialogueLineCommand) DialogueCommand {
+	return DialogueCommand{
+		Title: title,
+		Lines: lines,
+	}
+}
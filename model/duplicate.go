@@ -0,0 +1,28 @@
+package model
+
+// DuplicateCluster is a group of phrases of a character that are suspected to be the same quote
+type DuplicateCluster struct {
+	CharacterId int64
+	Similarity  float64 // The highest similarity between two phrases of the cluster
+	Phrases     []Phrase
+}
+
+// DuplicateClusterResult is the type to be shown in the API for a DuplicateCluster
+type DuplicateClusterResult struct {
+	CharacterId int64          `json:"character_id"`
+	Similarity  float64        `json:"similarity"`
+	Phrases     []PhraseResult `json:"phrases"`
+}
+
+// DuplicateClusterResultFromCluster creates a DuplicateClusterResult from a DuplicateCluster
+func DuplicateClusterResultFromCluster(cluster DuplicateCluster) DuplicateClusterResult {
+	phrases := make([]PhraseResult, len(cluster.Phrases))
+	for i, phrase := range cluster.Phrases {
+		phrases[i] = PhraseResultFromPhrase(phrase)
+	}
+	return DuplicateClusterResult{
+		CharacterId: cluster.CharacterId,
+		Similarity:  cluster.Similarity,
+		Phrases:     phrases,
+	}
+}
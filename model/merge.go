@@ -0,0 +1,87 @@
+package model
+
+import (
+	"time"
+)
+
+// MergeCommand contains the info to merge a character into another
+type MergeCommand struct {
+	SourceId int64 `json:"source_id" binding:"required"`
+	DryRun   bool  `json:"dry_run"`
+}
+
+// NewMergeCommand is a constructor for MergeCommand
+func NewMergeCommand(sourceId int64, dryRun bool) MergeCommand {
+	return MergeCommand{
+		SourceId: sourceId,
+		DryRun:   dryRun,
+	}
+}
+
+// PhraseCollision is a phrase of the merged character that the character it's merged into already has
+type PhraseCollision struct {
+	SourcePhrase Phrase
+	TargetPhrase Phrase
+}
+
+// MergePlan tells what merging the source character into the target does. The moved phrases carry the slug
+// they take in the target, and the colliding ones are dropped
+type MergePlan struct {
+	Target        Character
+	Source        Character
+	TargetPhrases int
+	Moved         []Phrase
+	Collisions    []PhraseCollision
+}
+
+// PhraseCollisionResult is the type to be shown in the API for a PhraseCollision
+type PhraseCollisionResult struct {
+	SourcePhraseId int64  `json:"source_phrase_id"`
+	TargetPhraseId int64  `json:"target_phrase_id"`
+	Content        string `json:"content"`
+}
+
+// MergeResult is the type to be shown in the API for a MergePlan
+type MergeResult struct {
+	DryRun        bool                    `json:"dry_run"`
+	Character     CharacterResult         `json:"character"`
+	SourceId      int64                   `json:"source_id"`
+	TargetPhrases int                     `json:"target_phrases"`
+	SourcePhrases int                     `json:"source_phrases"`
+	MovedPhrases  int                     `json:"moved_phrases"`
+	Collisions    []PhraseCollisionResult `json:"collisions"`
+}
+
+// MergeResultFromPlan creates a MergeResult from a MergePlan
+func MergeResultFromPlan(plan MergePlan, dryRun bool) MergeResult {
+	collisions := make([]PhraseCollisionResult, len(plan.Collisions))
+	for i, collision := range plan.Collisions {
+		collisions[i] = PhraseCollisionResult{
+			SourcePhraseId: collision.SourcePhrase.ID,
+			TargetPhraseId: collision.TargetPhrase.ID,
+			Content:        collision.SourcePhrase.Content,
+		}
+	}
+	return MergeResult{
+		DryRun:        dryRun,
+		Character:     CharacterResultFromCharacter(plan.Target),
+		SourceId:      plan.Source.ID,
+		TargetPhrases: plan.TargetPhrases,
+		SourcePhrases: len(plan.Moved) + len(plan.Collisions),
+		MovedPhrases:  len(plan.Moved),
+		Collisions:    collisions,
+	}
+}
+
+// CharacterMerge is the audit record of a character merged into another
+type CharacterMerge struct {
+	ID             int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	TargetId       int64
+	SourceId       int64
+	SourceName     string
+	SourceSlug     string
+	MovedPhrases   int
+	DroppedPhrases int
+	MergedBy       string    // The client that asked for the merge
+	DateCreated    time.Time `gorm:"column:date_created;type:datetime;not null"`
+}
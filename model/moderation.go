@@ -0,0 +1,42 @@
+package model
+
+import (
+	"github.com/airabinovich/memequotes_back/utils"
+)
+
+// Statuses of a phrase. Phrases submitted by clients below trusted wait in the moderation queue as pending
+// until a moderator approves or rejects them
+const (
+	PhraseStatusPending  = "pending"
+	PhraseStatusApproved = "approved"
+	PhraseStatusRejected = "rejected"
+)
+
+// SubmissionResult is the type to be shown in the API for a Phrase to its submitter and to moderators, with
+// the outcome of its moderation
+type SubmissionResult struct {
+	PhraseResult
+	ModerationReason *string            `json:"moderation_reason"`
+	DateModerated    *utils.ISO8601Time `json:"date_moderated"`
+}
+
+// SubmissionResultFromPhrase creates a SubmissionResult from a Phrase
+func SubmissionResultFromPhrase(phrase Phrase) SubmissionResult {
+	var dateModerated *utils.ISO8601Time
+	if phrase.DateModerated != nil {
+		moderated := utils.ISO8601Time(*phrase.DateModerated)
+		dateModerated = &moderated
+	}
+	return SubmissionResult{
+		PhraseResult:     PhraseResultFromPhrase(phrase),
+		ModerationReason: phrase.ModerationReason,
+		DateModerated:    dateModerated,
+	}
+}
+
+// ModerationCommand approves or rejects phrases at once, with the reason given to their submitters. A
+// rejection needs a reason
+type ModerationCommand struct {
+	PhraseIds []int64 `json:"phrase_ids" binding:"required,min=1,max=100,dive,min=1"`
+	Reason    string  `json:"reason" binding:"max=500"`
+}
@@ -2,16 +2,29 @@ package model
 
 import (
 	"github.com/airabinovich/memequotes_back/utils"
+	"strings"
 	"time"
 )
 
 // PhraseResult is the type to be shown in the API for a Phrase
 type PhraseResult struct {
-	ID          int64              `json:"id"`
-	CharacterId int64              `json:"character_id"`
-	Content     string             `json:"content"`
-	DateCreated *utils.ISO8601Time `json:"date_created"`
-	LastUpdated *utils.ISO8601Time `json:"last_updated"`
+	ID              int64              `json:"id"`
+	CharacterId     int64              `json:"character_id"`
+	Content         string             `json:"content"`
+	Slug            string             `json:"slug"`
+	SourceId        *int64             `json:"source_id"`
+	SourceTimestamp *int64             `json:"source_timestamp"`
+	Source          *SourceResult      `json:"source,omitempty"` // Only when the source is embedded
+	Upvotes         int64              `json:"upvotes"`
+	Downvotes       int64              `json:"downvotes"`
+	Score           float64            `json:"score"`
+	Status          string             `json:"status"`
+	NSFW            bool               `json:"nsfw"`
+	Rating          string             `json:"rating"`
+	ContentWarnings []string           `json:"content_warnings"`
+	DateCreated     *utils.ISO8601Time `json:"date_created"`
+	LastUpdated     *utils.ISO8601Time `json:"last_updated"`
+	Version         int64              `json:"version"`
 }
 
 // NewPhraseResult is a constructor for PhraseResult
@@ -30,22 +43,79 @@ func PhraseResultFromPhrase(phrase Phrase) PhraseResult {
 	dateCreated := utils.ISO8601Time(phrase.DateCreated)
 	lastUpdated := utils.ISO8601Time(phrase.LastUpdated)
 	return PhraseResult{
-		ID:          phrase.ID,
-		CharacterId: phrase.CharacterId,
-		Content:     phrase.Content,
-		DateCreated: &dateCreated,
-		LastUpdated: &lastUpdated,
+		ID:              phrase.ID,
+		CharacterId:     phrase.CharacterId,
+		Content:         phrase.Content,
+		Slug:            phrase.Slug,
+		SourceId:        phrase.SourceId,
+		SourceTimestamp: phrase.SourceTimestamp,
+		Upvotes:         phrase.Upvotes,
+		Downvotes:       phrase.Downvotes,
+		Score:           phrase.Score,
+		Status:          phrase.Status,
+		NSFW:            phrase.NSFW,
+		Rating:          StrongerRating(phrase.Rating, RatingSafe),
+		ContentWarnings: phrase.Warnings(),
+		DateCreated:     &dateCreated,
+		LastUpdated:     &lastUpdated,
+		Version:         phrase.Version,
 	}
 }
 
 // Phrase represent a phrase from one character
 type Phrase struct {
-	ID          int64 `gorm:"primary_key;AUTO_INCREMENT"`
-	CharacterId int64
-	Character   *Character `gorm:"foreignkey:CharacterId"`
-	Content     string
-	DateCreated time.Time `gorm:"column:date_created;type:datetime;not null"`
-	LastUpdated time.Time `gorm:"column:last_updated;type:datetime;not null"`
+	ID               int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	CharacterId      int64
+	Character        *Character `gorm:"foreignkey:CharacterId"`
+	Content          string
+	Slug             string // Identifies the phrase in URLs among the phrases of its character, like its id
+	SourceId         *int64
+	SourceTimestamp  *int64 // Seconds into the source where the phrase is said
+	Upvotes          int64  // Kept with every vote, like the score, so phrases are sorted without counting votes
+	Downvotes        int64
+	Score            float64 // Lower bound of the Wilson score interval of the votes
+	Status           string  // Only approved phrases are listed, see PhraseStatusApproved
+	NSFW             bool    `gorm:"column:nsfw"` // Rated above safe
+	Rating           string
+	ContentWarnings  string // Separated by commas
+	SubmittedBy      string // Client that submitted the phrase, see context.ClientID
+	ModeratedBy      *string
+	ModerationReason *string
+	DateModerated    *time.Time `gorm:"column:date_moderated;type:datetime"`
+	DateCreated      time.Time  `gorm:"column:date_created;type:datetime;not null"`
+	LastUpdated      time.Time  `gorm:"column:last_updated;type:datetime;not null"`
+	Version          int64      `gorm:"column:version;not null"` // Incremented on every update
+}
+
+// VisibleTo tells whether a client may see a phrase. Approved phrases are public, the rest are only seen by
+// the client that submitted them and by moderators
+func (phrase Phrase) VisibleTo(clientID string, moderator bool) bool {
+	return phrase.Status == PhraseStatusApproved || moderator || (phrase.SubmittedBy != "" && phrase.SubmittedBy == clientID)
+}
+
+// Warnings splits the content warnings of a phrase
+func (phrase Phrase) Warnings() []string {
+	if phrase.ContentWarnings == "" {
+		return []string{}
+	}
+	return strings.Split(phrase.ContentWarnings, ",")
+}
+
+// JoinWarnings puts together content warnings to store them, each once and in the order of ContentWarnings
+func JoinWarnings(warnings ...[]string) string {
+	flagged := make(map[string]bool)
+	for _, list := range warnings {
+		for _, warning := range list {
+			flagged[warning] = true
+		}
+	}
+	joined := make([]string, 0, len(flagged))
+	for _, warning := range ContentWarnings {
+		if flagged[warning] {
+			joined = append(joined, warning)
+		}
+	}
+	return strings.Join(joined, ",")
 }
 
 // NewPhrase is a constructor for Phrase
@@ -62,8 +132,15 @@ func NewPhrase(ID int64, characterId int64, character *Character, content string
 
 // PhraseCommand contains the info to create a phrase
 type PhraseCommand struct {
-	CharacterId int64  `json:"character_id"`
-	Content     string `json:"content" binding:"required"`
+	CharacterId     int64    `json:"character_id"`
+	Content         string   `json:"content" binding:"required"`
+	SourceId        *int64   `json:"source_id"`
+	SourceTimestamp *int64   `json:"source_timestamp" binding:"omitempty,min=0"`
+	Rating          string   `json:"rating" binding:"omitempty,oneof=safe mature explicit"` // Raised by the content filter
+	ContentWarnings []string `json:"content_warnings" binding:"max=6,dive,oneof=language sexual violence drugs discrimination self_harm"`
+	Status          string   `json:"-"` // Set by the API from the role of the submitter
+	SubmittedBy     string   `json:"-"`
+	NSFW            bool     `json:"-"` // Set from the rating
 }
 
 // NewPhraseCommand is a constructor for PhraseCommand
@@ -0,0 +1,30 @@
+package model
+
+// Precondition restricts a write to some versions of a resource, as sent by the client in If-Match.
+// The zero value allows any version
+type Precondition struct {
+	Versions []int64
+}
+
+// NewPrecondition is a constructor for Precondition
+func NewPrecondition(versions ...int64) Precondition {
+	return Precondition{Versions: versions}
+}
+
+// Unconditional tells whether the write is allowed on any version
+func (p Precondition) Unconditional() bool {
+	return len(p.Versions) == 0
+}
+
+// Allows tells whether the write is allowed on a version
+func (p Precondition) Allows(version int64) bool {
+	if p.Unconditional() {
+		return true
+	}
+	for _, v := range p.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package model
+
+// Content ratings of a phrase, from the mildest to the strongest. Phrases are safe unless rated otherwise
+const (
+	RatingSafe     = "safe"
+	RatingMature   = "mature"
+	RatingExplicit = "explicit"
+)
+
+// Ratings lists the content ratings, from the mildest to the strongest
+var Ratings = []string{RatingSafe, RatingMature, RatingExplicit}
+
+// ContentWarnings lists what a phrase may be flagged for, besides its rating
+var ContentWarnings = []string{"language", "sexual", "violence", "drugs", "discrimination", "self_harm"}
+
+// ValidRating tells whether a phrase may have a rating
+func ValidRating(rating string) bool {
+	return rating != "" && ratingLevel(rating) <= len(Ratings)
+}
+
+// RatingAtMost tells whether a phrase with a rating may be shown to a client that takes up to the maximum
+func RatingAtMost(rating string, maximum string) bool {
+	return ratingLevel(rating) <= maximumLevel(maximum)
+}
+
+// RatingsUpTo lists the ratings a client that takes up to the maximum may be shown
+func RatingsUpTo(maximum string) []string {
+	return Ratings[:maximumLevel(maximum)]
+}
+
+// PhrasesRatedUpTo keeps the phrases a client that takes up to the maximum rating may be shown
+func PhrasesRatedUpTo(phrases []Phrase, maximum string) []Phrase {
+	rated := make([]Phrase, 0, len(phrases))
+	for _, phrase := range phrases {
+		if RatingAtMost(phrase.Rating, maximum) {
+			rated = append(rated, phrase)
+		}
+	}
+	return rated
+}
+
+// StrongerRating returns the strongest of two ratings. An empty rating is safe
+func StrongerRating(rating string, other string) string {
+	if ratingLevel(other) > ratingLevel(rating) {
+		return other
+	}
+	if rating == "" {
+		return RatingSafe
+	}
+	return rating
+}
+
+// WeakerRating returns the mildest of two ratings
+func WeakerRating(rating string, other string) string {
+	if ratingLevel(other) < ratingLevel(rating) {
+		return other
+	}
+	return rating
+}
+
+// RatingCommand rates a phrase and flags it with content warnings, replacing the ones it had
+type RatingCommand struct {
+	Rating          string   `json:"rating" binding:"required,oneof=safe mature explicit"`
+	ContentWarnings []string `json:"content_warnings" binding:"max=6,dive,oneof=language sexual violence drugs discrimination self_harm"`
+}
+
+// ratingLevel is the position of a rating from the mildest one, counting from 1. An empty rating is safe, and
+// one that is not known is stronger than any, so it's never shown by mistake
+func ratingLevel(rating string) int {
+	if rating == "" {
+		return 1
+	}
+	for i, r := range Ratings {
+		if r == rating {
+			return i + 1
+		}
+	}
+	return len(Ratings) + 1
+}
+
+// maximumLevel is the level of the strongest rating a client takes. A maximum that is not known only takes safe
+func maximumLevel(maximum string) int {
+	if level := ratingLevel(maximum); level <= len(Ratings) {
+		return level
+	}
+	return 1
+}
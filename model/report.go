@@ -0,0 +1,128 @@
+package model
+
+import (
+	"github.com/airabinovich/memequotes_back/utils"
+	"time"
+)
+
+// What a report is about
+const (
+	ReportTargetPhrase    = "phrase"
+	ReportTargetCharacter = "character"
+)
+
+// Why content is reported
+const (
+	ReportCategoryOffensive     = "offensive"
+	ReportCategoryMisattributed = "misattributed"
+	ReportCategorySpam          = "spam"
+	ReportCategoryOther         = "other"
+)
+
+// Statuses of a report. A report is open until a moderator resolves it, taking the content down, or dismisses it
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// HiddenByReports is the moderator recorded on the phrases hidden after their reports, so dismissing the
+// reports publishes them again
+const HiddenByReports = "reports"
+
+// ClosedByMerge is recorded on the reports a merge closes, when their reporter had also reported the content kept
+const ClosedByMerge = "merge"
+
+// ReportResult is the type to be shown in the API for a Report
+type ReportResult struct {
+	ID          int64              `json:"id"`
+	Category    string             `json:"category"`
+	Details     string             `json:"details"`
+	Reporter    string             `json:"reporter"`
+	Status      string             `json:"status"`
+	Note        *string            `json:"note"`
+	ClosedBy    *string            `json:"closed_by"`
+	DateCreated *utils.ISO8601Time `json:"date_created"`
+	LastUpdated *utils.ISO8601Time `json:"last_updated"`
+	DateClosed  *utils.ISO8601Time `json:"date_closed"`
+}
+
+// ReportResultFromReport creates a ReportResult from a Report
+func ReportResultFromReport(report Report) ReportResult {
+	dateCreated := utils.ISO8601Time(report.DateCreated)
+	lastUpdated := utils.ISO8601Time(report.LastUpdated)
+	var dateClosed *utils.ISO8601Time
+	if report.DateClosed != nil {
+		closed := utils.ISO8601Time(*report.DateClosed)
+		dateClosed = &closed
+	}
+	return ReportResult{
+		ID:          report.ID,
+		Category:    report.Category,
+		Details:     report.Details,
+		Reporter:    report.Reporter,
+		Status:      report.Status,
+		Note:        report.Note,
+		ClosedBy:    report.ClosedBy,
+		DateCreated: &dateCreated,
+		LastUpdated: &lastUpdated,
+		DateClosed:  dateClosed,
+	}
+}
+
+// ReportedTarget is the type to be shown in the API for the reports on a phrase or character, grouped so a
+// moderator reviews the content once
+type ReportedTarget struct {
+	TargetType  string         `json:"target_type"`
+	TargetId    int64          `json:"target_id"`
+	CharacterId int64          `json:"character_id"`
+	Count       int            `json:"count"`
+	Categories  map[string]int `json:"categories"`
+	Reports     []ReportResult `json:"reports"`
+}
+
+// Report is a client's complaint about a phrase or a character. A client reports the same content once, a
+// new report replaces its category and details while it's open
+type Report struct {
+	ID          int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	TargetType  string
+	TargetId    int64
+	CharacterId int64 // The character reported, or the one of the phrase reported
+	Reporter    string
+	Category    string
+	Details     string
+	Status      string
+	Note        *string // Given by the moderator who closed the report
+	ClosedBy    *string
+	DateCreated time.Time  `gorm:"column:date_created;type:datetime;not null"`
+	LastUpdated time.Time  `gorm:"column:last_updated;type:datetime;not null"`
+	DateClosed  *time.Time `gorm:"column:date_closed;type:datetime"`
+}
+
+// NewReport is a constructor for Report
+func NewReport(id int64, targetType string, targetId int64, characterId int64, reporter string, reportCmd ReportCommand) Report {
+	return Report{
+		ID:          id,
+		TargetType:  targetType,
+		TargetId:    targetId,
+		CharacterId: characterId,
+		Reporter:    reporter,
+		Category:    reportCmd.Category,
+		Details:     reportCmd.Details,
+		Status:      ReportStatusOpen,
+	}
+}
+
+// ReportCommand contains the info to report a phrase or a character
+type ReportCommand struct {
+	Category string `json:"category" binding:"required,oneof=offensive misattributed spam other"`
+	Details  string `json:"details" binding:"max=1000"`
+}
+
+// ReviewCommand closes the open reports on a phrase or a character. Resolving the reports of a phrase rejects
+// it, and needs a note for its submitter
+type ReviewCommand struct {
+	TargetType string `json:"target_type" binding:"required,oneof=phrase character"`
+	TargetId   int64  `json:"target_id" binding:"required,min=1"`
+	Note       string `json:"note" binding:"max=500"`
+}
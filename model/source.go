@@ -0,0 +1,103 @@
+package model
+
+import (
+	"github.com/airabinovich/memequotes_back/utils"
+	"time"
+)
+
+// AirDateLayout is the format of the date a source was aired or published
+const AirDateLayout = "2006-01-02"
+
+// Kinds of the media phrases come from
+const (
+	SourceShow      = "show"
+	SourceMovie     = "movie"
+	SourceInterview = "interview"
+	SourceTweet     = "tweet"
+)
+
+// SourceResult is the type to be shown in the API for a Source
+type SourceResult struct {
+	ID          int64              `json:"id"`
+	Kind        string             `json:"kind"`
+	Title       string             `json:"title"`
+	Episode     string             `json:"episode,omitempty"`
+	AirDate     string             `json:"air_date,omitempty"`
+	URL         string             `json:"url,omitempty"`
+	DateCreated *utils.ISO8601Time `json:"date_created"`
+	LastUpdated *utils.ISO8601Time `json:"last_updated"`
+}
+
+// SourceResultFromSource creates a SourceResult from a Source
+func SourceResultFromSource(source Source) SourceResult {
+	dateCreated := utils.ISO8601Time(source.DateCreated)
+	lastUpdated := utils.ISO8601Time(source.LastUpdated)
+	result := SourceResult{
+		ID:          source.ID,
+		Kind:        source.Kind,
+		Title:       source.Title,
+		Episode:     source.Episode,
+		URL:         source.URL,
+		DateCreated: &dateCreated,
+		LastUpdated: &lastUpdated,
+	}
+	if source.AirDate != nil {
+		result.AirDate = source.AirDate.Format(AirDateLayout)
+	}
+	return result
+}
+
+// Source is the media a phrase was said in, like a show, a movie, an interview or a tweet
+type Source struct {
+	ID          int64 `gorm:"primary_key;AUTO_INCREMENT"`
+	Kind        string
+	Title       string
+	Episode     string     // Empty when the source is not part of a series
+	AirDate     *time.Time `gorm:"column:air_date;type:date"`
+	URL         string     `gorm:"column:url"`
+	DateCreated time.Time  `gorm:"column:date_created;type:datetime;not null"`
+	LastUpdated time.Time  `gorm:"column:last_updated;type:datetime;not null"`
+}
+
+// NewSource is a constructor for Source
+func NewSource(id int64, kind string, title string, dateCreated time.Time, lastUpdated time.Time) Source {
+	return Source{
+		ID:          id,
+		Kind:        kind,
+		Title:       title,
+		DateCreated: dateCreated,
+		LastUpdated: lastUpdated,
+	}
+}
+
+// SourceCommand contains the info to create a Source
+type SourceCommand struct {
+	Kind    string `json:"kind" binding:"required,oneof=show movie interview tweet"`
+	Title   string `json:"title" binding:"required,max=255"`
+	Episode string `json:"episode" binding:"max=100"`
+	AirDate string `json:"air_date" binding:"omitempty,datetime=2006-01-02"`
+	URL     string `json:"url" binding:"omitempty,url,max=500"`
+}
+
+// NewSourceCommand is a constructor for SourceCommand
+func NewSourceCommand(kind string, title string) SourceCommand {
+	return SourceCommand{
+		Kind:  kind,
+		Title: title,
+	}
+}
+
+// AppearanceCommand contains the info to link a phrase to the source it was said in. The timestamp is
+// how many seconds into the source the phrase is said
+type AppearanceCommand struct {
+	SourceId        int64  `json:"source_id" binding:"required"`
+	SourceTimestamp *int64 `json:"source_timestamp" binding:"omitempty,min=0"`
+}
+
+// NewAppearanceCommand is a constructor for AppearanceCommand
+func NewAppearanceCommand(sourceId int64, sourceTimestamp *int64) AppearanceCommand {
+	return AppearanceCommand{
+		SourceId:        sourceId,
+		SourceTimestamp: sourceTimestamp,
+	}
+}
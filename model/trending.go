@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+)
+
+// Events counted for the trending phrases: a phrase was read, or made into a meme and rendered or shared
+const (
+	EventView   = "view"
+	EventRender = "render"
+	EventShare  = "share"
+)
+
+// TrendingWindows are the sliding windows of the trending phrases, by name
+var TrendingWindows = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// PhraseCounts are the events of a phrase in the time bucket starting at Bucket
+type PhraseCounts struct {
+	PhraseId int64
+	Bucket   time.Time
+	Views    int64
+	Renders  int64
+	Shares   int64
+}
+
+// TableName keeps the counts next to the phrases they are for
+func (PhraseCounts) TableName() string {
+	return "phrase_counters"
+}
+
+// TrendingPhrase is a phrase with the events it had in a window, and the score they give it
+type TrendingPhrase struct {
+	Phrase  Phrase
+	Views   int64
+	Renders int64
+	Shares  int64
+	Score   float64
+}
+
+// TrendingPhraseResult is the type to be shown in the API for a TrendingPhrase
+type TrendingPhraseResult struct {
+	PhraseResult
+	Views         int64   `json:"views"`
+	Renders       int64   `json:"renders"`
+	Shares        int64   `json:"shares"`
+	TrendingScore float64 `json:"trending_score"`
+}
+
+// TrendingPhraseResultFromTrendingPhrase creates a TrendingPhraseResult from a TrendingPhrase
+func TrendingPhraseResultFromTrendingPhrase(trending TrendingPhrase) TrendingPhraseResult {
+	return TrendingPhraseResult{
+		PhraseResult:  PhraseResultFromPhrase(trending.Phrase),
+		Views:         trending.Views,
+		Renders:       trending.Renders,
+		Shares:        trending.Shares,
+		TrendingScore: trending.Score,
+	}
+}
+
+// ShareCommand tells whether a phrase was shared, the default, or rendered as a meme
+type ShareCommand struct {
+	Kind string `json:"kind" binding:"omitempty,oneof=share render"`
+}
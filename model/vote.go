@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+)
+
+// Values of a vote
+const (
+	Upvote   = 1
+	Downvote = -1
+)
+
+// Vote is what a client thinks of a phrase. A client has one vote per phrase
+type Vote struct {
+	PhraseId    int64  `gorm:"primary_key;auto_increment:false"`
+	Voter       string `gorm:"primary_key"` // The client id of the API key that voted
+	Value       int
+	DateCreated time.Time `gorm:"column:date_created;type:datetime;not null"`
+	LastUpdated time.Time `gorm:"column:last_updated;type:datetime;not null"`
+}
+
+// TableName keeps the votes next to the phrases they are for
+func (Vote) TableName() string {
+	return "phrase_votes"
+}
+
+// VoteCommand contains the info to vote a phrase up or down
+type VoteCommand struct {
+	Value int `json:"value" binding:"required,oneof=1 -1"`
+}
+
+// NewVoteCommand is a constructor for VoteCommand
+func NewVoteCommand(value int) VoteCommand {
+	return VoteCommand{Value: value}
+}
+
+// TopWindows are the time windows of the top phrases, by name. A zero window takes every vote
+var TopWindows = map[string]time.Duration{
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+	"all":  0,
+}
+
+// RankedPhrase is a phrase with the votes it got in a time window, and the score they give it
+type RankedPhrase struct {
+	Phrase    Phrase
+	Upvotes   int64
+	Downvotes int64
+	Score     float64
+}
+
+// RankedPhraseResult is the type to be shown in the API for a RankedPhrase
+type RankedPhraseResult struct {
+	PhraseResult
+	WindowUpvotes   int64   `json:"window_upvotes"`
+	WindowDownvotes int64   `json:"window_downvotes"`
+	WindowScore     float64 `json:"window_score"`
+}
+
+// RankedPhraseResultFromRankedPhrase creates a RankedPhraseResult from a RankedPhrase
+func RankedPhraseResultFromRankedPhrase(ranked RankedPhrase) RankedPhraseResult {
+	return RankedPhraseResult{
+		PhraseResult:    PhraseResultFromPhrase(ranked.Phrase),
+		WindowUpvotes:   ranked.Upvotes,
+		WindowDownvotes: ranked.Downvotes,
+		WindowScore:     ranked.Score,
+	}
+}
@@ -0,0 +1,198 @@
+package phrase
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/slug"
+)
+
+// Normalize reduces a phrase to what makes it a different quote: lower case letters and digits without
+// diacritics, separated by single spaces. Punctuation and symbols are dropped
+func Normalize(content string) string {
+	var normalized strings.Builder
+	space := false
+	for _, r := range strings.ToLower(content) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && normalized.Len() > 0 {
+				normalized.WriteByte(' ')
+			}
+			space = false
+			normalized.WriteString(slug.Transliterate(string(r)))
+		case unicode.IsSpace(r):
+			space = true
+		}
+	}
+	return normalized.String()
+}
+
+// Similarity scores two normalized phrases from 0, nothing in common, to 1, the same phrase. It's one minus
+// the edit distance between them relative to the longest one
+func Similarity(a string, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// similarEnough discards the pairs whose lengths alone keep them under the threshold, before computing
+// the edit distance
+func similarEnough(a string, b string, threshold float64) (float64, bool) {
+	la, lb := len([]rune(a)), len([]rune(b))
+	shortest, longest := la, lb
+	if la > lb {
+		shortest, longest = lb, la
+	}
+	if longest > 0 && float64(shortest)/float64(longest) < threshold {
+		return 0, false
+	}
+	similarity := Similarity(a, b)
+	return similarity, similarity >= threshold
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	lowest := values[0]
+	for _, v := range values[1:] {
+		if v < lowest {
+			lowest = v
+		}
+	}
+	return lowest
+}
+
+// findDuplicate returns the phrase with the same normalized content, if any
+func findDuplicate(content string, phrases []model.Phrase) (model.Phrase, bool) {
+	normalized := Normalize(content)
+	for _, phrase := range phrases {
+		if Normalize(phrase.Content) == normalized {
+			return phrase, true
+		}
+	}
+	return model.Phrase{}, false
+}
+
+// findSimilar returns the ids of the phrases at least as similar to the content as the threshold
+func findSimilar(content string, phrases []model.Phrase, threshold float64) []int64 {
+	normalized := Normalize(content)
+	var similar []int64
+	for _, phrase := range phrases {
+		if _, ok := similarEnough(normalized, Normalize(phrase.Content), threshold); ok {
+			similar = append(similar, phrase.ID)
+		}
+	}
+	return similar
+}
+
+// DuplicateClusters groups the phrases of each character that are at least as similar as the threshold.
+// Similarity is transitive within a cluster, so a cluster may hold phrases less similar than the threshold
+// when others link them. Phrases without duplicates are left out
+func DuplicateClusters(phrases []model.Phrase, threshold float64) []model.DuplicateCluster {
+	byCharacter := make(map[int64][]model.Phrase)
+	for _, phrase := range phrases {
+		byCharacter[phrase.CharacterId] = append(byCharacter[phrase.CharacterId], phrase)
+	}
+
+	clusters := make([]model.DuplicateCluster, 0)
+	for characterId, characterPhrases := range byCharacter {
+		clusters = append(clusters, characterClusters(characterId, characterPhrases, threshold)...)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].CharacterId != clusters[j].CharacterId {
+			return clusters[i].CharacterId < clusters[j].CharacterId
+		}
+		return clusters[i].Phrases[0].ID < clusters[j].Phrases[0].ID
+	})
+	return clusters
+}
+
+func characterClusters(characterId int64, phrases []model.Phrase, threshold float64) []model.DuplicateCluster {
+	sort.Slice(phrases, func(i, j int) bool { return phrases[i].ID < phrases[j].ID })
+	normalized := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		normalized[i] = Normalize(phrase.Content)
+	}
+
+	// Union-find over the phrase indexes, keeping the highest similarity of every cluster in its root
+	parent := make([]int, len(phrases))
+	similarity := make([]float64, len(phrases))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range phrases {
+		for j := i + 1; j < len(phrases); j++ {
+			score, ok := similarEnough(normalized[i], normalized[j], threshold)
+			if !ok {
+				continue
+			}
+			ri, rj := root(i), root(j)
+			if ri != rj {
+				parent[rj] = ri
+				if similarity[rj] > similarity[ri] {
+					similarity[ri] = similarity[rj]
+				}
+			}
+			if score > similarity[ri] {
+				similarity[ri] = score
+			}
+		}
+	}
+
+	members := make(map[int][]model.Phrase)
+	var roots []int
+	for i, phrase := range phrases {
+		r := root(i)
+		if _, ok := members[r]; !ok {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], phrase)
+	}
+
+	var clusters []model.DuplicateCluster
+	for _, r := range roots {
+		if len(members[r]) < 2 {
+			continue
+		}
+		clusters = append(clusters, model.DuplicateCluster{
+			CharacterId: characterId,
+			Similarity:  similarity[r],
+			Phrases:     members[r],
+		})
+	}
+	return clusters
+}
@@ -0,0 +1,63 @@
+package phrase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Log("Case, whitespace, punctuation and diacritics should not make phrases different")
+
+	assert.Equal(t, "tengo un sueno", Normalize("  ¡Tengo   un SUEÑO!  "))
+	assert.Equal(t, Normalize("Qué lindo, ¿no?"), Normalize("que lindo no"))
+	assert.Equal(t, "", Normalize("?!..."))
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Log("Similarity should go from 1 for the same phrase down to 0 for nothing in common")
+
+	assert.Equal(t, 1.0, Similarity("miame", "miame"))
+	assert.Equal(t, 0.0, Similarity("abc", "xyz"))
+	assert.InDelta(t, 0.8, Similarity("miame", "miamo"), 0.001)
+}
+
+func TestFindDuplicate(t *testing.T) {
+	t.Log("A phrase that only differs in punctuation should be found as a duplicate")
+
+	now := time.Now()
+	phrases := []model.Phrase{
+		model.NewPhrase(1, 1, nil, "Tengo un sueño", now, now),
+		model.NewPhrase(2, 1, nil, "Miameeee", now, now),
+	}
+
+	duplicate, found := findDuplicate("miameeee!!", phrases)
+	assert.True(t, found)
+	assert.Equal(t, int64(2), duplicate.ID)
+
+	_, found = findDuplicate("miameeeee", phrases)
+	assert.False(t, found)
+	assert.Equal(t, []int64{2}, findSimilar("miameeeee", phrases, 0.85))
+}
+
+func TestDuplicateClustersAreTransitive(t *testing.T) {
+	t.Log("Phrases linked through a similar phrase should end up in the same cluster")
+
+	now := time.Now()
+	clusters := DuplicateClusters([]model.Phrase{
+		model.NewPhrase(3, 1, nil, "abcdefghij", now, now),
+		model.NewPhrase(1, 1, nil, "abcdefghix", now, now),
+		model.NewPhrase(2, 1, nil, "abcdefghyx", now, now),
+		model.NewPhrase(4, 1, nil, "something else", now, now),
+	}, 0.9)
+
+	assert.Len(t, clusters, 1)
+	assert.Equal(t, 0.9, clusters[0].Similarity)
+	ids := make([]int64, 0)
+	for _, phrase := range clusters[0].Phrases {
+		ids = append(ids, phrase.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+}
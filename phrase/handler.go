@@ -1,28 +1,42 @@
 package phrase
 
 import (
+	"errors"
 	"fmt"
+	"github.com/airabinovich/memequotes_back/auth"
+	"github.com/airabinovich/memequotes_back/config"
 	commonContext "github.com/airabinovich/memequotes_back/context"
 	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/filter"
 	"github.com/airabinovich/memequotes_back/model"
 	"github.com/airabinovich/memequotes_back/repository"
 	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/airabinovich/memequotes_back/trending"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"time"
+)
+
+// How many top phrases are returned when the request doesn't say, and at most
+const (
+	defaultTopLimit = 20
+	maxTopLimit     = 100
 )
 
 var phraseRepository repository.PhraseRepository
+var sourceRepository repository.SourceRepository
 
-func Initialize(phRepo repository.PhraseRepository) {
+func Initialize(phRepo repository.PhraseRepository, srcRepo repository.SourceRepository) {
 	phraseRepository = phRepo
+	sourceRepository = srcRepo
 }
 
 func GetPhrase(c *gin.Context) {
 	rest.ErrorWrapper(getPhrase, c)
 }
 
-func getPhrase(c *gin.Context) *rest.APIError {
+func getPhrase(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
@@ -38,30 +52,43 @@ func getPhrase(c *gin.Context) *rest.APIError {
 		return rest.NewBadRequest(err.Error())
 	}
 
-	phrase, found, err := phraseRepository.Get(c, characterId, phraseId)
+	phrase, found, err := phraseRepository.Get(ctx, characterId, phraseId)
 	if err != nil {
-		switch err.(type) {
-		case customErrors.UnauthorizedError:
-			return rest.NewUnauthorized(err.Error())
-		default:
-			return rest.NewInternalServerError(err.Error())
-		}
+		logger.Error("get phrase by id", err)
+		return err
 	}
 
-	if !found {
+	if !found || !phrase.VisibleTo(commonContext.ClientID(ctx), auth.AtLeast(commonContext.Role(ctx), auth.RoleModerator)) {
 		return rest.NewResourceNotFound("phrase not found")
 	}
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	if !model.RatingAtMost(phrase.Rating, maxRating) {
+		return rest.NewResourceNotFound("phrase not found")
+	}
+	if phrase.Status == model.PhraseStatusApproved {
+		trending.RecordView(c, phrase.ID)
+	} else {
+		// Only its submitter and moderators see the phrase, shared caches must not keep it
+		c.Header("Cache-Control", "private, no-cache")
+	}
 
-	c.JSON(http.StatusOK, model.PhraseResultFromPhrase(phrase))
-	return nil
+	phraseResults := []model.PhraseResult{model.PhraseResultFromPhrase(phrase)}
+	if err := embedSources(c, phraseResults); err != nil {
+		return err
+	}
+	return rest.VersionedJSON(c, phrase.Version, phrase.LastUpdated, phraseResults[0])
 }
 
-// GetAllPhrasesForCharacter returns all phrases for a character wrapped in a json object
+// GetAllPhrasesForCharacter returns all phrases for a character wrapped in a json object, best voted first
+// with ?sort=score
 func GetAllPhrasesForCharacter(c *gin.Context) {
 	rest.ErrorWrapper(getAllPhrasesForCharacter, c)
 }
 
-func getAllPhrasesForCharacter(c *gin.Context) *rest.APIError {
+func getAllPhrasesForCharacter(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
@@ -72,31 +99,55 @@ func getAllPhrasesForCharacter(c *gin.Context) *rest.APIError {
 	}
 
 	logger.Debug(fmt.Sprintf("Getting phrases for character id %d", characterId))
-	phrases, found, err := phraseRepository.GetAllForCharacter(c, characterId)
+	phrases, found, err := phraseRepository.GetAllForCharacter(ctx, characterId)
 	if err != nil {
 		logger.Error("get character by id", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
 	}
 	if !found {
 		return rest.NewResourceNotFound(fmt.Sprintf("phrases for character %d not found", characterId))
 	}
+	switch c.Query("sort") {
+	case "":
+	case "score":
+		sortByScore(phrases)
+	default:
+		return rest.NewBadRequest("sort must be score")
+	}
+	return PhrasesJSON(c, phrases)
+}
+
+// PhrasesJSON renders a list of phrases wrapped in a json object, with their sources when the request asks
+// for them. Phrases rated above ?max_rating= are left out. Every list of phrases is rendered like this
+func PhrasesJSON(c *gin.Context, phrases []model.Phrase) error {
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	phrases = model.PhrasesRatedUpTo(phrases, maxRating)
+
 	phraseResults := make([]model.PhraseResult, len(phrases))
+	var lastModified time.Time
 	for i, phrase := range phrases {
 		phraseResults[i] = model.PhraseResultFromPhrase(phrase)
+		lastModified = rest.LatestUpdate(lastModified, phrase.LastUpdated)
+	}
+	if err := embedSources(c, phraseResults); err != nil {
+		return err
 	}
 
-	c.JSON(http.StatusOK, map[string]interface{}{
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
 		"results": phraseResults,
 	})
-	return nil
 }
 
-// SaveNewPhrase saves a new phrase for the specified character
+// SaveNewPhrase saves a new phrase for the specified character, once the content filter allows it. Phrases of
+// clients below trusted wait for a moderator to approve them
 func SaveNewPhrase(c *gin.Context) {
 	rest.ErrorWrapper(saveNewPhrase, c)
 }
 
-func saveNewPhrase(c *gin.Context) *rest.APIError {
+func saveNewPhrase(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
@@ -109,17 +160,310 @@ func saveNewPhrase(c *gin.Context) *rest.APIError {
 	var phCmd model.PhraseCommand
 	if err := c.ShouldBindJSON(&phCmd); err != nil {
 		logger.Error("creating phrase bad body format", err)
-		return rest.NewBadRequest(err.Error())
+		return rest.NewValidationError(err)
 	}
 	phCmd.CharacterId = characterId
+	phCmd.SubmittedBy = commonContext.ClientID(ctx)
+	phCmd.Status = model.PhraseStatusPending
+	if auth.AtLeast(commonContext.Role(ctx), auth.RoleTrusted) {
+		phCmd.Status = model.PhraseStatusApproved
+	}
+	if phCmd.SourceTimestamp != nil && phCmd.SourceId == nil {
+		return rest.NewBadRequest("source_timestamp needs a source_id")
+	}
+
+	verdict := filter.Check(characterId, phCmd.Content)
+	if len(verdict.Terms) > 0 {
+		logger.Info(fmt.Sprintf("New phrase for character %d has banned terms %q", characterId, verdict.Terms))
+	}
+	if verdict.Rejected {
+		return customErrors.NewValidationError("content", "content has banned terms")
+	}
+	phCmd.Content = verdict.Content
+	phCmd.NSFW = verdict.NSFW
+	phCmd.Rating = model.StrongerRating(phCmd.Rating, verdict.Rating)
+	phCmd.ContentWarnings = append(phCmd.ContentWarnings, verdict.Warnings...)
 
-	phrase, err := phraseRepository.Save(c, phCmd)
+	phrase, err := phraseRepository.Save(ctx, phCmd)
+	var duplicate customErrors.DuplicateError
+	if errors.As(err, &duplicate) {
+		c.Header("Location", fmt.Sprintf("/character/%d/phrase/%d", characterId, duplicate.ExistingID))
+		return err
+	}
 	if err != nil {
 		logger.Error("error creating phrase", err)
-		return rest.NewInternalServerError(err.Error())
+		return err
+	}
+
+	return rest.VersionedJSON(c, phrase.Version, phrase.LastUpdated, model.PhraseResultFromPhrase(phrase))
+}
+
+// SetPhraseSource links a phrase to the source it was said in
+func SetPhraseSource(c *gin.Context) {
+	rest.ErrorWrapper(setPhraseSource, c)
+}
+
+func setPhraseSource(c *gin.Context) error {
+	logger := commonContext.Logger(commonContext.RequestContext(c))
+
+	var appearance model.AppearanceCommand
+	if err := c.ShouldBindJSON(&appearance); err != nil {
+		logger.Error("setting phrase source bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	return updatePhraseSource(c, &appearance)
+}
+
+// DeletePhraseSource unlinks a phrase from its source
+func DeletePhraseSource(c *gin.Context) {
+	rest.ErrorWrapper(deletePhraseSource, c)
+}
+
+func deletePhraseSource(c *gin.Context) error {
+	return updatePhraseSource(c, nil)
+}
+
+func updatePhraseSource(c *gin.Context, appearance *model.AppearanceCommand) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric characterId", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("phrase-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting phrase with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	precondition, err := rest.IfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	phrase, found, err := phraseRepository.SetSource(ctx, characterId, id, appearance, precondition)
+	if err != nil {
+		logger.Error("set phrase source", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound("phrase not found")
+	}
+
+	return rest.VersionedJSON(c, phrase.Version, phrase.LastUpdated, model.PhraseResultFromPhrase(phrase))
+}
+
+// SetPhraseRating rates a phrase and replaces its content warnings
+func SetPhraseRating(c *gin.Context) {
+	rest.ErrorWrapper(setPhraseRating, c)
+}
+
+func setPhraseRating(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric characterId", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("phrase-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting phrase with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	var ratingCmd model.RatingCommand
+	if err := c.ShouldBindJSON(&ratingCmd); err != nil {
+		logger.Error("rating phrase bad body format", err)
+		return rest.NewValidationError(err)
+	}
+
+	precondition, err := rest.IfMatch(c)
+	if err != nil {
+		return err
+	}
+
+	phrase, found, err := phraseRepository.SetRating(ctx, characterId, id, ratingCmd, precondition)
+	if err != nil {
+		logger.Error("set phrase rating", err)
+		return err
 	}
+	if !found {
+		return rest.NewResourceNotFound("phrase not found")
+	}
+
+	return rest.VersionedJSON(c, phrase.Version, phrase.LastUpdated, model.PhraseResultFromPhrase(phrase))
+}
+
+// VotePhrase records the vote of the client for a phrase, replacing the one it had
+func VotePhrase(c *gin.Context) {
+	rest.ErrorWrapper(votePhrase, c)
+}
+
+func votePhrase(c *gin.Context) error {
+	logger := commonContext.Logger(commonContext.RequestContext(c))
+
+	var voteCmd model.VoteCommand
+	if err := c.ShouldBindJSON(&voteCmd); err != nil {
+		logger.Error("voting phrase bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	return updateVote(c, voteCmd.Value)
+}
+
+// UnvotePhrase removes the vote of the client for a phrase
+func UnvotePhrase(c *gin.Context) {
+	rest.ErrorWrapper(unvotePhrase, c)
+}
+
+func unvotePhrase(c *gin.Context) error {
+	return updateVote(c, 0)
+}
+
+func updateVote(c *gin.Context, value int) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric characterId", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("phrase-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting phrase with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	phrase, found, err := phraseRepository.Vote(ctx, characterId, id, commonContext.ClientID(ctx), value)
+	if err != nil {
+		logger.Error("vote phrase", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound("phrase not found")
+	}
+
+	return rest.VersionedJSON(c, phrase.Version, phrase.LastUpdated, model.PhraseResultFromPhrase(phrase))
+}
+
+// GetTopPhrases returns the best voted phrases in a time window given by ?window=day|week|all, all time by
+// default, wrapped in a json object. Phrases rated above ?max_rating= are left out
+func GetTopPhrases(c *gin.Context) {
+	rest.ErrorWrapper(getTopPhrases, c)
+}
+
+func getTopPhrases(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	window, ok := model.TopWindows[c.DefaultQuery("window", "all")]
+	if !ok {
+		return rest.NewBadRequest("window must be one of day, week or all")
+	}
+	limit := defaultTopLimit
+	if c.Query("limit") != "" {
+		var err error
+		if limit, err = strconv.Atoi(c.Query("limit")); err != nil || limit < 1 || limit > maxTopLimit {
+			return rest.NewBadRequest(fmt.Sprintf("limit must be a number between 1 and %d", maxTopLimit))
+		}
+	}
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	var since time.Time
+	if window > 0 {
+		since = time.Now().Add(-window)
+	}
+
+	ranked, err := phraseRepository.GetTop(ctx, since, limit, maxRating)
+	if err != nil {
+		logger.Error("get top phrases", err)
+		return err
+	}
+	rankedResults := make([]model.RankedPhraseResult, len(ranked))
+	var lastModified time.Time
+	for i, rankedPhrase := range ranked {
+		rankedResults[i] = model.RankedPhraseResultFromRankedPhrase(rankedPhrase)
+		lastModified = rest.LatestUpdate(lastModified, rankedPhrase.Phrase.LastUpdated)
+	}
+
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
+		"results": rankedResults,
+	})
+}
+
+// embedSources fills in the source of every phrase result that has one when the request asks for it
+// with ?embed=source
+func embedSources(c *gin.Context, phraseResults []model.PhraseResult) error {
+	if c.Query("embed") != "source" {
+		return nil
+	}
+	ctx := commonContext.RequestContext(c)
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, phraseResult := range phraseResults {
+		if phraseResult.SourceId != nil && !seen[*phraseResult.SourceId] {
+			seen[*phraseResult.SourceId] = true
+			ids = append(ids, *phraseResult.SourceId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	sources, err := sourceRepository.GetByIds(ctx, ids)
+	if err != nil {
+		commonContext.Logger(ctx).Error("get sources to embed", err)
+		return err
+	}
+	sourceResults := make(map[int64]model.SourceResult, len(sources))
+	for _, source := range sources {
+		sourceResults[source.ID] = model.SourceResultFromSource(source)
+	}
+	for i, phraseResult := range phraseResults {
+		if phraseResult.SourceId == nil {
+			continue
+		}
+		if sourceResult, ok := sourceResults[*phraseResult.SourceId]; ok {
+			phraseResults[i].Source = &sourceResult
+		}
+	}
+	return nil
+}
+
+// GetDuplicates lists the clusters of suspected duplicate phrases of every character
+func GetDuplicates(c *gin.Context) {
+	rest.ErrorWrapper(getDuplicates, c)
+}
+
+func getDuplicates(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
 
-	c.JSON(http.StatusOK, model.PhraseResultFromPhrase(phrase))
+	phrases, err := phraseRepository.GetAll(ctx)
+	if err != nil {
+		logger.Error("get all phrases", err)
+		return err
+	}
+
+	clusters := DuplicateClusters(phrases, config.Current().Duplicates.SimilarityThreshold)
+	clusterResults := make([]model.DuplicateClusterResult, len(clusters))
+	for i, cluster := range clusters {
+		clusterResults[i] = model.DuplicateClusterResultFromCluster(cluster)
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"results": clusterResults,
+	})
 	return nil
 }
 
@@ -127,7 +471,7 @@ func DeletePhraseForCharacter(c *gin.Context) {
 	rest.ErrorWrapper(deletePhraseForCharacter, c)
 }
 
-func deletePhraseForCharacter(c *gin.Context) *rest.APIError {
+func deletePhraseForCharacter(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 
@@ -143,14 +487,15 @@ func deletePhraseForCharacter(c *gin.Context) *rest.APIError {
 		return rest.NewBadRequest(err.Error())
 	}
 
-	err = phraseRepository.Delete(c, characterId, id)
+	precondition, err := rest.IfMatch(c)
 	if err != nil {
-		switch err.(type) {
-		case customErrors.UnauthorizedError:
-			return rest.NewUnauthorized(err.Error())
-		default:
-			return rest.NewInternalServerError(err.Error())
-		}
+		return err
+	}
+
+	err = phraseRepository.Delete(ctx, characterId, id, precondition)
+	if err != nil {
+		logger.Error("delete phrase", err)
+		return err
 	}
 
 	c.Status(http.StatusGone)
@@ -2,9 +2,11 @@ package phrase
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/filter"
 	"github.com/airabinovich/memequotes_back/model"
 	"github.com/airabinovich/memequotes_back/utils"
 	"github.com/gin-gonic/gin"
@@ -16,7 +18,10 @@ import (
 	"time"
 )
 
-var phraseMockRepo phrasesMockRepository
+var (
+	phraseMockRepo phrasesMockRepository
+	sourceMockRepo sourcesMockRepository
+)
 
 func TestGetPhrasesWithNonNumericIdShouldFail(t *testing.T) {
 	t.Log("Calling with a non-numeric ID should return an error")
@@ -187,6 +192,92 @@ func TestSavePhraseOk(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestSavePhraseFiltered(t *testing.T) {
+	t.Log("A phrase with a rejected term should return Bad Request, and one with masked and NSFW terms should be saved flagged")
+
+	resetMocks()
+
+	filter.Initialize(bannedTermsRepository{
+		model.NewBannedTerm(1, "chanta", model.MatchExact, model.PolicyReject, nil),
+		model.NewBannedTerm(2, "garca", model.MatchStem, model.PolicyNSFW, nil),
+		model.NewBannedTerm(3, "bobo", model.MatchLeet, model.PolicyMask, nil),
+	})
+	defer filter.Initialize(nil)
+	assert.NoError(t, filter.Reload(context.Background()))
+
+	now := time.Now()
+	phraseMockRepo.On("Save", mock.Anything, mock.MatchedBy(func(phCmd model.PhraseCommand) bool {
+		return phCmd.NSFW && phCmd.Rating == model.RatingMature && phCmd.Content == "Qué ****, qué garcas"
+	})).Return(model.NewPhrase(1, 1, nil, "Qué ****, qué garcas", now, now), nil)
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/phrase", SaveNewPhrase)
+
+	w := httptest.NewRecorder()
+	body, _ := json.Marshal(model.NewPhraseCommand("Sos un chanta"))
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/phrase", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	body, _ = json.Marshal(model.NewPhraseCommand("Qué b0bo, qué garcas"))
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/phrase", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	phraseMockRepo.AssertNumberOfCalls(t, "Save", 1)
+}
+
+func TestSaveDuplicatePhraseShouldReturnConflict(t *testing.T) {
+	t.Log("Saving a phrase the character already has should return 409 pointing at the existing phrase")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	phraseMockRepo.On("Save", mock.Anything, mock.Anything).Return(model.Phrase{}, customErrors.NewDuplicateError("phrase 7 of character 1 is the same phrase", 7))
+
+	body, _ := json.Marshal(model.NewPhraseCommand("Miameee!"))
+	req := httptest.NewRequest(http.MethodPost, "/character/1/phrase", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/phrase", SaveNewPhrase)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "/character/1/phrase/7", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), "phrase 7")
+}
+
+func TestGetDuplicates(t *testing.T) {
+	t.Log("The duplicates report should group the similar phrases of each character")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	phraseMockRepo.On("GetAll", mock.Anything).Return([]model.Phrase{
+		model.NewPhrase(1, 1, nil, "Miameeee", now, now),
+		model.NewPhrase(2, 1, nil, "Tengo un sueño", now, now),
+		model.NewPhrase(3, 1, nil, "miameeeee!", now, now),
+		model.NewPhrase(4, 2, nil, "Miameeee", now, now),
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/duplicates", nil)
+
+	r := utils.TestRouter()
+	r.GET("/admin/duplicates", GetDuplicates)
+	r.ServeHTTP(w, req)
+
+	var response struct {
+		Results []model.DuplicateClusterResult `json:"results"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&response))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, int64(1), response.Results[0].CharacterId)
+	assert.Len(t, response.Results[0].Phrases, 2)
+}
+
 func TestDeletePhraseShouldReturnGone(t *testing.T) {
 	t.Log("Delete phrase should return Gone")
 
@@ -194,7 +285,7 @@ func TestDeletePhraseShouldReturnGone(t *testing.T) {
 
 	resetMocks()
 
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	req := httptest.NewRequest(http.MethodDelete, "/character/1/phrase/1", nil)
 
@@ -212,7 +303,7 @@ func TestDeletePhraseBadCharacterId(t *testing.T) {
 
 	resetMocks()
 
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	req := httptest.NewRequest(http.MethodDelete, "/character/john/phrase/1", nil)
 
@@ -230,7 +321,7 @@ func TestDeletePhraseBadPhraseId(t *testing.T) {
 
 	resetMocks()
 
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 
 	req := httptest.NewRequest(http.MethodDelete, "/character/1/phrase/lala", nil)
 
@@ -248,7 +339,7 @@ func TestDeletePhraseIncorrectCharacterId(t *testing.T) {
 
 	resetMocks()
 
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
+	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 		Return(customErrors.NewUnauthorizedError("character id not match"))
 
 	req := httptest.NewRequest(http.MethodDelete, "/character/1/phrase/1", nil)
@@ -267,7 +358,7 @@ func TestDeletePhraseDBError(t *testing.T) {
 
 	resetMocks()
 
-	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything).
+	phraseMockRepo.On("Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 		Return(errors.New("DB failed"))
 
 	req := httptest.NewRequest(http.MethodDelete, "/character/1/phrase/1", nil)
@@ -372,6 +463,7 @@ func TestGetPhraseForCharacterOK(t *testing.T) {
 
 	now := time.Now()
 	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusApproved
 	phraseMockRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(phrase, true, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/character/1/phrase/1", nil)
@@ -389,17 +481,443 @@ func TestGetPhraseForCharacterOK(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestResolvePhraseCharacter(t *testing.T) {
+	t.Log("A phrase found by its id alone should give the routes its character")
+
+	resetMocks()
+
+	now := time.Now()
+	phraseMockRepo.On("GetByID", mock.Anything, int64(4)).Return(model.NewPhrase(4, 2, nil, "miameeee", now, now), true, nil)
+	phraseMockRepo.On("GetByID", mock.Anything, int64(5)).Return(model.Phrase{}, false, nil)
+
+	var characterId string
+	r := utils.TestRouter()
+	r.POST("/phrase/:phrase-id/report", ResolvePhraseCharacter, func(c *gin.Context) {
+		characterId = c.Param("character-id")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/phrase/4/report", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", characterId)
+
+	characterId = ""
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/phrase/5/report", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, characterId)
+}
+
+func TestGetPhraseAboveMaxRating(t *testing.T) {
+	t.Log("A phrase rated above ?max_rating= should not be found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	phrase := model.NewPhrase(1, 1, nil, "qué garcas", now, now)
+	phrase.Status = model.PhraseStatusApproved
+	phrase.Rating = model.RatingMature
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(1)).Return(phrase, true, nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrase/:phrase-id", GetPhrase)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/character/1/phrase/1?max_rating=safe", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetPhraseEmbedsSource(t *testing.T) {
+	t.Log("Asking to embed the source should return the phrase with its source")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	sourceId, timestamp := int64(3), int64(754)
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusApproved
+	phrase.SourceId = &sourceId
+	phrase.SourceTimestamp = &timestamp
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(1)).Return(phrase, true, nil)
+	sourceMockRepo.On("GetByIds", mock.Anything, []int64{3}).
+		Return([]model.Source{model.NewSource(3, model.SourceShow, "Fort Night", now, now)}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/1/phrase/1?embed=source", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrase/:phrase-id", GetPhrase)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.PhraseResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(754), *actualResult.SourceTimestamp)
+	assert.Equal(t, "Fort Night", actualResult.Source.Title)
+}
+
+func TestGetPhraseWithoutEmbedding(t *testing.T) {
+	t.Log("The source should only be embedded when asked for")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	sourceId := int64(3)
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusApproved
+	phrase.SourceId = &sourceId
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(1)).Return(phrase, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/1/phrase/1", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrase/:phrase-id", GetPhrase)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.PhraseResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(3), *actualResult.SourceId)
+	assert.Nil(t, actualResult.Source)
+	sourceMockRepo.AssertNotCalled(t, "GetByIds", mock.Anything, mock.Anything)
+}
+
+func TestSavePhraseTimestampWithoutSourceShouldFail(t *testing.T) {
+	t.Log("A timestamp without a source should return Bad Request")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	timestamp := int64(754)
+	phCmd := model.NewPhraseCommand("miameeee")
+	phCmd.SourceTimestamp = &timestamp
+	body, _ := json.Marshal(phCmd)
+	req := httptest.NewRequest(http.MethodPost, "/character/1/phrase", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/phrase", SaveNewPhrase)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	phraseMockRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestSetPhraseSource(t *testing.T) {
+	t.Log("Setting the source should pass the appearance and the If-Match version to the repository")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	timestamp := int64(754)
+	appearance := model.NewAppearanceCommand(3, &timestamp)
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Version = 2
+	phraseMockRepo.On("SetSource", mock.Anything, int64(1), int64(1), &appearance, model.NewPrecondition(1)).Return(phrase, true, nil)
+
+	body, _ := json.Marshal(appearance)
+	req := httptest.NewRequest(http.MethodPut, "/character/1/phrase/1/source", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"v1"`)
+
+	r := utils.TestRouter()
+	r.PUT("/character/:character-id/phrase/:phrase-id/source", SetPhraseSource)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v2"`, w.Header().Get("ETag"))
+}
+
+func TestSetPhraseSourceNotFound(t *testing.T) {
+	t.Log("Setting a source that doesn't exist should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	phraseMockRepo.On("SetSource", mock.Anything, int64(1), int64(1), mock.Anything, mock.Anything).
+		Return(model.Phrase{}, true, customErrors.NewNotFoundError("source 3 not found"))
+
+	body, _ := json.Marshal(model.NewAppearanceCommand(3, nil))
+	req := httptest.NewRequest(http.MethodPut, "/character/1/phrase/1/source", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.PUT("/character/:character-id/phrase/:phrase-id/source", SetPhraseSource)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSetPhraseRating(t *testing.T) {
+	t.Log("Rating a phrase should pass the rating, the warnings and the If-Match version to the repository")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	ratingCmd := model.RatingCommand{Rating: model.RatingExplicit, ContentWarnings: []string{"sexual"}}
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Version = 2
+	phrase.Rating = model.RatingExplicit
+	phrase.ContentWarnings = "sexual"
+	phraseMockRepo.On("SetRating", mock.Anything, int64(1), int64(1), ratingCmd, model.NewPrecondition(1)).Return(phrase, true, nil)
+
+	body, _ := json.Marshal(ratingCmd)
+	req := httptest.NewRequest(http.MethodPut, "/character/1/phrase/1/rating", bytes.NewBuffer(body))
+	req.Header.Set("If-Match", `"v1"`)
+
+	r := utils.TestRouter()
+	r.PUT("/character/:character-id/phrase/:phrase-id/rating", SetPhraseRating)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result model.PhraseResult
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&result))
+	assert.Equal(t, model.RatingExplicit, result.Rating)
+	assert.Equal(t, []string{"sexual"}, result.ContentWarnings)
+
+	w = httptest.NewRecorder()
+	body, _ = json.Marshal(model.RatingCommand{Rating: "pg13"})
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/character/1/phrase/1/rating", bytes.NewBuffer(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	phraseMockRepo.AssertNumberOfCalls(t, "SetRating", 1)
+}
+
+func TestGetAllPhrasesForCharacterUpToMaxRating(t *testing.T) {
+	t.Log("Phrases rated above ?max_rating=, or with a rating that is not known, should be left out of the list")
+
+	resetMocks()
+
+	now := time.Now()
+	phrases := []model.Phrase{
+		model.NewPhrase(1, 1, nil, "miameeee", now, now),
+		model.NewPhrase(2, 1, nil, "qué garcas", now, now),
+		model.NewPhrase(3, 1, nil, "no me toquen", now, now),
+		model.NewPhrase(4, 1, nil, "el tren de Ricardo Fort", now, now),
+	}
+	phrases[1].Rating = model.RatingMature
+	phrases[2].Rating = model.RatingExplicit
+	phrases[3].Rating = "pg13"
+	phraseMockRepo.On("GetAllForCharacter", mock.Anything, int64(1)).Return(phrases, true, nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrases", GetAllPhrasesForCharacter)
+
+	for path, expected := range map[string][]int64{
+		"/character/1/phrases":                     {1, 2},
+		"/character/1/phrases?max_rating=safe":     {1},
+		"/character/1/phrases?max_rating=explicit": {1, 2},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+
+		actualResult := make(map[string][]model.PhraseResult)
+		assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+		ids := make([]int64, len(actualResult["results"]))
+		for i, phraseResult := range actualResult["results"] {
+			ids[i] = phraseResult.ID
+		}
+		assert.Equal(t, expected, ids, path)
+	}
+}
+
+func TestGetAllPhrasesForCharacterSortedByScore(t *testing.T) {
+	t.Log("Phrases sorted by score should come best voted first")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	unvoted := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	voted := model.NewPhrase(2, 1, nil, "el tren de Ricardo Fort pasa una sola vez en la vida", now, now)
+	voted.Upvotes = 3
+	voted.Score = Wilson(3, 0)
+	phraseMockRepo.On("GetAllForCharacter", mock.Anything, int64(1)).Return([]model.Phrase{unvoted, voted}, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/1/phrases?sort=score", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrases", GetAllPhrasesForCharacter)
+	r.ServeHTTP(w, req)
+
+	actualResult := make(map[string][]model.PhraseResult)
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(2), actualResult["results"][0].ID)
+	assert.Equal(t, int64(3), actualResult["results"][0].Upvotes)
+	assert.Equal(t, int64(1), actualResult["results"][1].ID)
+}
+
+func TestGetAllPhrasesForCharacterUnknownSort(t *testing.T) {
+	t.Log("Sorting by an unknown field should return Bad Request")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	phraseMockRepo.On("GetAllForCharacter", mock.Anything, int64(1)).Return([]model.Phrase{}, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/1/phrases?sort=content", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrases", GetAllPhrasesForCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestVotePhraseOk(t *testing.T) {
+	t.Log("A vote should be recorded for the client and return the phrase with its new score")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Upvotes = 1
+	phrase.Score = Wilson(1, 0)
+	phrase.Version = 2
+	phraseMockRepo.On("Vote", mock.Anything, int64(1), int64(1), mock.AnythingOfType("string"), model.Upvote).Return(phrase, true, nil)
+
+	body, _ := json.Marshal(model.NewVoteCommand(model.Upvote))
+	req := httptest.NewRequest(http.MethodPut, "/character/1/phrase/1/vote", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.PUT("/character/:character-id/phrase/:phrase-id/vote", VotePhrase)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.PhraseResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v2"`, w.Header().Get("ETag"))
+	assert.Equal(t, int64(1), actualResult.Upvotes)
+	assert.Equal(t, phrase.Score, actualResult.Score)
+}
+
+func TestVotePhraseBadValue(t *testing.T) {
+	t.Log("A vote other than 1 or -1 should return Bad Request")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	body, _ := json.Marshal(model.NewVoteCommand(2))
+	req := httptest.NewRequest(http.MethodPut, "/character/1/phrase/1/vote", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.PUT("/character/:character-id/phrase/:phrase-id/vote", VotePhrase)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUnvotePhraseNotFound(t *testing.T) {
+	t.Log("Removing the vote of a phrase that doesn't exist should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	phraseMockRepo.On("Vote", mock.Anything, int64(1), int64(9), mock.AnythingOfType("string"), 0).Return(model.Phrase{}, false, nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/character/1/phrase/9/vote", nil)
+
+	r := utils.TestRouter()
+	r.DELETE("/character/:character-id/phrase/:phrase-id/vote", UnvotePhrase)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetTopPhrasesOfTheWeek(t *testing.T) {
+	t.Log("Top phrases of the week should count the votes of the last 7 days")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	ranked := []model.RankedPhrase{
+		{Phrase: model.NewPhrase(2, 1, nil, "miameeee", now, now), Upvotes: 5, Score: Wilson(5, 0)},
+	}
+	phraseMockRepo.On("GetTop", mock.Anything, mock.MatchedBy(func(since time.Time) bool {
+		return since.Before(now.Add(-6*24*time.Hour)) && since.After(now.Add(-8*24*time.Hour))
+	}), 10, model.RatingMature).Return(ranked, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/phrases/top?window=week&limit=10", nil)
+
+	r := utils.TestRouter()
+	r.GET("/phrases/top", GetTopPhrases)
+	r.ServeHTTP(w, req)
+
+	actualResult := make(map[string][]model.RankedPhraseResult)
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, actualResult["results"], 1)
+	assert.Equal(t, int64(2), actualResult["results"][0].ID)
+	assert.Equal(t, int64(5), actualResult["results"][0].WindowUpvotes)
+}
+
+func TestGetTopPhrasesAllTimeByDefault(t *testing.T) {
+	t.Log("Top phrases should count every vote and return 20 phrases when the request doesn't say")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	phraseMockRepo.On("GetTop", mock.Anything, time.Time{}, 20, model.RatingMature).Return([]model.RankedPhrase{}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/phrases/top", nil)
+
+	r := utils.TestRouter()
+	r.GET("/phrases/top", GetTopPhrases)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	phraseMockRepo.AssertExpectations(t)
+}
+
+func TestGetTopPhrasesBadParameters(t *testing.T) {
+	t.Log("An unknown window or a limit out of range should return Bad Request")
+
+	r := utils.TestRouter()
+	r.GET("/phrases/top", GetTopPhrases)
+
+	for _, query := range []string{"window=month", "limit=0", "limit=101", "limit=many"} {
+		resetMocks()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/phrases/top?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
 func resetMocks() {
 	phraseMockRepo = phrasesMockRepository{}
+	sourceMockRepo = sourcesMockRepository{}
 	phraseRepository = &phraseMockRepo
+	sourceRepository = &sourceMockRepo
 }
 
 type phrasesMockRepository struct {
 	mock.Mock
 }
 
-func (repoMock *phrasesMockRepository) Get(c *gin.Context, characterId int64, id int64) (model.Phrase, bool, error) {
-	args := repoMock.Called(c, characterId, id)
+func (repoMock *phrasesMockRepository) Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id)
 
 	ph, ok := args.Get(0).(model.Phrase)
 	if !ok {
@@ -414,8 +932,8 @@ func (repoMock *phrasesMockRepository) Get(c *gin.Context, characterId int64, id
 	return ph, found, args.Error(2)
 }
 
-func (repoMock *phrasesMockRepository) GetAllForCharacter(c *gin.Context, characterId int64) ([]model.Phrase, bool, error) {
-	args := repoMock.Called(c, characterId)
+func (repoMock *phrasesMockRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId)
 
 	ph, ok := args.Get(0).([]model.Phrase)
 	if !ok {
@@ -430,18 +948,272 @@ func (repoMock *phrasesMockRepository) GetAllForCharacter(c *gin.Context, charac
 	return ph, found, args.Error(2)
 }
 
-func (repoMock *phrasesMockRepository) Save(c *gin.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
-	args := repoMock.Called(c, phCmd)
+func (repoMock *phrasesMockRepository) GetByID(ctx context.Context, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetBySlug(ctx context.Context, characterId int64, slug string) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, slug)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) SetSource(ctx context.Context, characterId int64, id int64, appearance *model.AppearanceCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, appearance, precondition)
 
 	ph, ok := args.Get(0).(model.Phrase)
 	if !ok {
 		panic(errors.New("mock error"))
 	}
 
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) SetRating(ctx context.Context, characterId int64, id int64, ratingCmd model.RatingCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, ratingCmd, precondition)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) Vote(ctx context.Context, characterId int64, id int64, voter string, value int) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id, voter, value)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) GetTop(ctx context.Context, since time.Time, limit int, maxRating string) ([]model.RankedPhrase, error) {
+	args := repoMock.Called(ctx, since, limit, maxRating)
+
+	ranked, ok := args.Get(0).([]model.RankedPhrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ranked, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetByStatus(ctx context.Context, status string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, status)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetSubmissions(ctx context.Context, submitter string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, submitter)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
 	return ph, args.Error(1)
 }
 
-func (repoMock *phrasesMockRepository) Delete(c *gin.Context, characterId int64, id int64) error {
-	args := repoMock.Called(c, characterId, id)
+func (repoMock *phrasesMockRepository) Moderate(ctx context.Context, ids []int64, status string, moderator string, reason *string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, ids, status, moderator, reason)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) GetAll(ctx context.Context) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx)
+
+	ph, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) Save(ctx context.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
+	args := repoMock.Called(ctx, phCmd)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Error(1)
+}
+
+func (repoMock *phrasesMockRepository) DeleteAllForCharacter(ctx context.Context, characterId int64) error {
+	args := repoMock.Called(ctx, characterId)
 	return args.Error(0)
-}
\ No newline at end of file
+}
+
+func (repoMock *phrasesMockRepository) Delete(ctx context.Context, characterId int64, id int64, precondition model.Precondition) error {
+	args := repoMock.Called(ctx, characterId, id, precondition)
+	return args.Error(0)
+}
+func TestGetPhraseNotModified(t *testing.T) {
+	t.Log("Get Phrase should return 304 when the client's copy is up to date")
+
+	resetMocks()
+
+	now := time.Now()
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusApproved
+	phraseMockRepo.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(phrase, true, nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/phrase/:phrase-id", GetPhrase)
+
+	w := utils.PerformRequest(r, http.MethodGet, "/character/1/phrase/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = utils.PerformRequest(r, http.MethodGet, "/character/1/phrase/1", map[string]string{"If-None-Match": w.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+type sourcesMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *sourcesMockRepository) Get(ctx context.Context, id int64) (model.Source, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	source, ok := args.Get(0).(model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return source, found, args.Error(2)
+}
+
+func (repoMock *sourcesMockRepository) GetByIds(ctx context.Context, ids []int64) ([]model.Source, error) {
+	args := repoMock.Called(ctx, ids)
+
+	sources, ok := args.Get(0).([]model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return sources, args.Error(1)
+}
+
+func (repoMock *sourcesMockRepository) GetAll(ctx context.Context) ([]model.Source, error) {
+	args := repoMock.Called(ctx)
+
+	sources, ok := args.Get(0).([]model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return sources, args.Error(1)
+}
+
+func (repoMock *sourcesMockRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Source, bool, error) {
+	args := repoMock.Called(ctx, characterId)
+
+	sources, ok := args.Get(0).([]model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return sources, found, args.Error(2)
+}
+
+func (repoMock *sourcesMockRepository) GetPhrases(ctx context.Context, id int64) ([]model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	phrases, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return phrases, found, args.Error(2)
+}
+
+func (repoMock *sourcesMockRepository) Save(ctx context.Context, srcCmd model.SourceCommand) (model.Source, error) {
+	args := repoMock.Called(ctx, srcCmd)
+
+	source, ok := args.Get(0).(model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return source, args.Error(1)
+}
+
+// bannedTermsRepository holds the terms of the content filter, which only reads them
+type bannedTermsRepository []model.BannedTerm
+
+func (repo bannedTermsRepository) GetAll(ctx context.Context) ([]model.BannedTerm, error) {
+	return repo, nil
+}
+
+func (repo bannedTermsRepository) Save(ctx context.Context, termCmd model.BannedTermCommand) (model.BannedTerm, error) {
+	return model.BannedTerm{}, errors.New("not implemented")
+}
+
+func (repo bannedTermsRepository) Delete(ctx context.Context, id int64) error {
+	return errors.New("not implemented")
+}
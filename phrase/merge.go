@@ -0,0 +1,32 @@
+package phrase
+
+import (
+	"sort"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+// PlanMove tells what happens to the phrases of a character merged into another. The target's duplicates of
+// a phrase, after normalization, make it collide, and the rest move with a slug that is free in the target
+func PlanMove(targetPhrases []model.Phrase, sourcePhrases []model.Phrase) ([]model.Phrase, []model.PhraseCollision, error) {
+	sorted := append([]model.Phrase{}, sourcePhrases...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	taken := append([]model.Phrase{}, targetPhrases...)
+	moved := make([]model.Phrase, 0, len(sorted))
+	collisions := make([]model.PhraseCollision, 0)
+	for _, phrase := range sorted {
+		if duplicate, found := findDuplicate(phrase.Content, taken); found {
+			collisions = append(collisions, model.PhraseCollision{SourcePhrase: phrase, TargetPhrase: duplicate})
+			continue
+		}
+		phraseSlug, err := uniqueSlug(phrase.Content, taken)
+		if err != nil {
+			return nil, nil, err
+		}
+		phrase.Slug = phraseSlug
+		moved = append(moved, phrase)
+		taken = append(taken, phrase)
+	}
+	return moved, collisions, nil
+}
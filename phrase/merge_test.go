@@ -0,0 +1,50 @@
+package phrase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanMove(t *testing.T) {
+	t.Log("Phrases the target already has should collide and the rest should move with a free slug")
+
+	now := time.Now()
+	target := model.NewPhrase(1, 1, nil, "Miameeee", now, now)
+	target.Slug = "miameeee"
+	otherTarget := model.NewPhrase(2, 1, nil, "Tengo un sueño", now, now)
+	otherTarget.Slug = "tengo-un-sueno"
+
+	duplicate := model.NewPhrase(11, 7, nil, "miameeee!!", now, now)
+	sameSlug := model.NewPhrase(10, 7, nil, "Tengo un sueño, sí", now, now)
+	sameSlug.Slug = "tengo-un-sueno-si"
+	clash := model.NewPhrase(12, 7, nil, "Tengo un sueño... sí", now, now)
+
+	moved, collisions, err := PlanMove([]model.Phrase{target, otherTarget}, []model.Phrase{duplicate, clash, sameSlug})
+
+	assert.NoError(t, err)
+	assert.Len(t, moved, 1)
+	assert.Equal(t, int64(10), moved[0].ID)
+	assert.Equal(t, "tengo-un-sueno-si", moved[0].Slug)
+	assert.Len(t, collisions, 2)
+	assert.Equal(t, int64(1), collisions[0].TargetPhrase.ID)
+	assert.Equal(t, int64(10), collisions[1].TargetPhrase.ID)
+}
+
+func TestPlanMoveRenamesTakenSlugs(t *testing.T) {
+	t.Log("A moved phrase whose slug the target has should get a new one")
+
+	now := time.Now()
+	target := model.NewPhrase(1, 1, nil, "Miame", now, now)
+	target.Slug = "miame-hoy"
+	source := model.NewPhrase(10, 7, nil, "Miame hoy", now, now)
+	source.Slug = "miame-hoy"
+
+	moved, collisions, err := PlanMove([]model.Phrase{target}, []model.Phrase{source})
+
+	assert.NoError(t, err)
+	assert.Empty(t, collisions)
+	assert.Equal(t, "miame-hoy-2", moved[0].Slug)
+}
@@ -0,0 +1,107 @@
+package phrase
+
+import (
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+// GetModerationQueue returns the phrases waiting for a moderator wrapped in a json object, the ones submitted
+// first first. ?status=rejected lists the rejected phrases instead
+func GetModerationQueue(c *gin.Context) {
+	rest.ErrorWrapper(getModerationQueue, c)
+}
+
+func getModerationQueue(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	status := c.DefaultQuery("status", model.PhraseStatusPending)
+	if status != model.PhraseStatusPending && status != model.PhraseStatusRejected {
+		return rest.NewBadRequest("status must be pending or rejected")
+	}
+
+	phrases, err := phraseRepository.GetByStatus(ctx, status)
+	if err != nil {
+		logger.Error("get phrases by status", err)
+		return err
+	}
+	return submissionsJSON(c, phrases)
+}
+
+// GetMySubmissions returns the phrases submitted by the client wrapped in a json object, newest first, with
+// their status and the reason a moderator gave for it
+func GetMySubmissions(c *gin.Context) {
+	rest.ErrorWrapper(getMySubmissions, c)
+}
+
+func getMySubmissions(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	phrases, err := phraseRepository.GetSubmissions(ctx, commonContext.ClientID(ctx))
+	if err != nil {
+		logger.Error("get submitted phrases", err)
+		return err
+	}
+	return submissionsJSON(c, phrases)
+}
+
+func submissionsJSON(c *gin.Context, phrases []model.Phrase) error {
+	submissions := make([]model.SubmissionResult, len(phrases))
+	for i, phrase := range phrases {
+		submissions[i] = model.SubmissionResultFromPhrase(phrase)
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"results": submissions,
+	})
+	return nil
+}
+
+// ApprovePhrases publishes phrases at once
+func ApprovePhrases(c *gin.Context) {
+	rest.ErrorWrapper(approvePhrases, c)
+}
+
+func approvePhrases(c *gin.Context) error {
+	return moderatePhrases(c, model.PhraseStatusApproved)
+}
+
+// RejectPhrases takes phrases out of the queue at once, with the reason their submitters see
+func RejectPhrases(c *gin.Context) {
+	rest.ErrorWrapper(rejectPhrases, c)
+}
+
+func rejectPhrases(c *gin.Context) error {
+	return moderatePhrases(c, model.PhraseStatusRejected)
+}
+
+func moderatePhrases(c *gin.Context, status string) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	var modCmd model.ModerationCommand
+	if err := c.ShouldBindJSON(&modCmd); err != nil {
+		logger.Error("moderating phrases bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	var reason *string
+	if trimmed := strings.TrimSpace(modCmd.Reason); trimmed != "" {
+		reason = &trimmed
+	}
+	if reason == nil && status == model.PhraseStatusRejected {
+		return rest.NewBadRequest("rejecting phrases needs a reason")
+	}
+
+	logger.Debug(fmt.Sprintf("Moderating phrases %v as %s", modCmd.PhraseIds, status))
+	phrases, err := phraseRepository.Moderate(ctx, modCmd.PhraseIds, status, commonContext.ClientID(ctx), reason)
+	if err != nil {
+		logger.Error("moderate phrases", err)
+		return err
+	}
+	return submissionsJSON(c, phrases)
+}
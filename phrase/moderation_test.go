@@ -0,0 +1,189 @@
+package phrase
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/airabinovich/memequotes_back/auth"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSavePhraseStatusByRole(t *testing.T) {
+	t.Log("Phrases of clients below trusted should wait for a moderator, the ones of trusted clients are approved")
+
+	for role, status := range map[string]string{
+		auth.RoleAnonymous: model.PhraseStatusPending,
+		auth.RoleUser:      model.PhraseStatusPending,
+		auth.RoleTrusted:   model.PhraseStatusApproved,
+		auth.RoleAdmin:     model.PhraseStatusApproved,
+	} {
+		resetMocks()
+
+		now := time.Now()
+		phraseMockRepo.On("Save", mock.Anything, mock.MatchedBy(func(phCmd model.PhraseCommand) bool {
+			return phCmd.Status == status && phCmd.SubmittedBy == "key:fort"
+		})).Return(model.NewPhrase(1, 1, nil, "miameee", now, now), nil)
+
+		body, _ := json.Marshal(model.NewPhraseCommand("miameee"))
+		w := httptest.NewRecorder()
+		r := clientRouter("key:fort", role)
+		r.POST("/character/:character-id/phrase", SaveNewPhrase)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/phrase", bytes.NewBuffer(body)))
+
+		assert.Equal(t, http.StatusOK, w.Code, role)
+		phraseMockRepo.AssertExpectations(t)
+	}
+}
+
+func TestGetPendingPhraseVisibility(t *testing.T) {
+	t.Log("A pending phrase should only be seen by its submitter and by moderators, and not by shared caches")
+
+	now := time.Now()
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusPending
+	phrase.SubmittedBy = "key:fort"
+
+	for _, client := range []struct {
+		id     string
+		role   string
+		status int
+	}{
+		{"key:fort", auth.RoleUser, http.StatusOK},
+		{"key:mods", auth.RoleModerator, http.StatusOK},
+		{"key:other", auth.RoleTrusted, http.StatusNotFound},
+		{"192.0.2.1", auth.RoleAnonymous, http.StatusNotFound},
+	} {
+		resetMocks()
+		phraseMockRepo.On("Get", mock.Anything, int64(1), int64(1)).Return(phrase, true, nil)
+
+		w := httptest.NewRecorder()
+		r := clientRouter(client.id, client.role)
+		r.GET("/character/:character-id/phrase/:phrase-id", GetPhrase)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/character/1/phrase/1", nil))
+
+		assert.Equal(t, client.status, w.Code, client.id)
+		if w.Code == http.StatusOK {
+			assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+func TestGetModerationQueue(t *testing.T) {
+	t.Log("The moderation queue should list the pending phrases by default")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusPending
+	phraseMockRepo.On("GetByStatus", mock.Anything, model.PhraseStatusPending).Return([]model.Phrase{phrase}, nil)
+
+	r := utils.TestRouter()
+	r.GET("/moderation/phrases", GetModerationQueue)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/moderation/phrases", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"pending"`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/moderation/phrases?status=approved", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRejectPhrases(t *testing.T) {
+	t.Log("Rejecting phrases should record the moderator and the reason, and needs a reason")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	reason := "Not a quote"
+	phrase := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusRejected
+	phrase.ModerationReason = &reason
+	phrase.DateModerated = &now
+	phraseMockRepo.On("Moderate", mock.Anything, []int64{1, 2}, model.PhraseStatusRejected, "key:mods", &reason).
+		Return([]model.Phrase{phrase}, nil)
+
+	r := clientRouter("key:mods", auth.RoleModerator)
+	r.POST("/moderation/phrases/reject", RejectPhrases)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/moderation/phrases/reject",
+		bytes.NewBufferString(`{"phrase_ids": [1, 2], "reason": " Not a quote "}`)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"moderation_reason":"Not a quote"`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/moderation/phrases/reject",
+		bytes.NewBufferString(`{"phrase_ids": [1, 2]}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestApprovePhrasesNotFound(t *testing.T) {
+	t.Log("Approving phrases that don't exist should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	phraseMockRepo.On("Moderate", mock.Anything, []int64{9}, model.PhraseStatusApproved, mock.Anything, (*string)(nil)).
+		Return([]model.Phrase{}, customErrors.NewNotFoundError("phrases [9] not found"))
+
+	r := utils.TestRouter()
+	r.POST("/moderation/phrases/approve", ApprovePhrases)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/moderation/phrases/approve", bytes.NewBufferString(`{"phrase_ids": [9]}`)))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetMySubmissions(t *testing.T) {
+	t.Log("A client should see its submissions with their status")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	pending := model.NewPhrase(2, 1, nil, "el tren de Ricardo Fort pasa una sola vez en la vida", now, now)
+	pending.Status = model.PhraseStatusPending
+	approved := model.NewPhrase(1, 1, nil, "miameeee", now, now)
+	approved.Status = model.PhraseStatusApproved
+	phraseMockRepo.On("GetSubmissions", mock.Anything, "key:fort").Return([]model.Phrase{pending, approved}, nil)
+
+	r := clientRouter("key:fort", auth.RoleUser)
+	r.GET("/phrases/submissions", GetMySubmissions)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/phrases/submissions", nil))
+
+	var actual struct {
+		Results []model.SubmissionResult `json:"results"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actual))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, actual.Results, 2) {
+		assert.Equal(t, model.PhraseStatusPending, actual.Results[0].Status)
+		assert.Equal(t, model.PhraseStatusApproved, actual.Results[1].Status)
+	}
+}
+
+// clientRouter makes requests on behalf of a client with a role, like the API key and client id middlewares do
+func clientRouter(clientID string, role string) *gin.Engine {
+	r := utils.TestRouter()
+	r.Use(func(c *gin.Context) {
+		ctx := commonContext.WithRole(commonContext.WithClientID(commonContext.RequestContext(c), clientID), role)
+		commonContext.WithRequestContext(ctx, c)
+	})
+	return r
+}
@@ -0,0 +1,51 @@
+package phrase
+
+import (
+	"math"
+	"sort"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+// wilsonZ is the quantile of the normal distribution for a 95% confidence
+const wilsonZ = 1.96
+
+// Wilson is the lower bound of the Wilson score interval of the votes of a phrase: the share of upvotes it
+// has, at least, with 95% confidence. A few votes give a low bound, so a phrase needs many votes to rank high
+func Wilson(upvotes int64, downvotes int64) float64 {
+	n := float64(upvotes + downvotes)
+	if n == 0 {
+		return 0
+	}
+	p := float64(upvotes) / n
+	z2 := wilsonZ * wilsonZ
+	bound := (p + z2/(2*n) - wilsonZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+	// Rounded to drop the floating point noise, which leaves a tiny bound when there are no upvotes
+	return math.Max(0, math.Round(bound*1e9)/1e9)
+}
+
+// rank sorts the phrases from the best score down, breaking ties by the most upvotes and then the oldest phrase
+func rank(ranked []model.RankedPhrase) {
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if ranked[i].Upvotes != ranked[j].Upvotes {
+			return ranked[i].Upvotes > ranked[j].Upvotes
+		}
+		return ranked[i].Phrase.ID < ranked[j].Phrase.ID
+	})
+}
+
+// sortByScore sorts phrases by their stored score, like rank
+func sortByScore(phrases []model.Phrase) {
+	sort.SliceStable(phrases, func(i, j int) bool {
+		if phrases[i].Score != phrases[j].Score {
+			return phrases[i].Score > phrases[j].Score
+		}
+		if phrases[i].Upvotes != phrases[j].Upvotes {
+			return phrases[i].Upvotes > phrases[j].Upvotes
+		}
+		return phrases[i].ID < phrases[j].ID
+	})
+}
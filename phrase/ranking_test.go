@@ -0,0 +1,37 @@
+package phrase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWilson(t *testing.T) {
+	t.Log("The score should grow with the share of upvotes and with the number of votes")
+
+	assert.Equal(t, 0.0, Wilson(0, 0))
+	assert.Equal(t, 0.0, Wilson(0, 5))
+	assert.InDelta(t, 0.5481, Wilson(12, 3), 0.0001)
+	assert.True(t, Wilson(100, 0) > Wilson(5, 0))
+	assert.True(t, Wilson(5, 0) > Wilson(1, 0))
+	assert.True(t, Wilson(90, 10) > Wilson(9, 1))
+}
+
+func TestRank(t *testing.T) {
+	t.Log("Phrases should be ranked by score, then by upvotes and then by id")
+
+	now := time.Now()
+	ranked := []model.RankedPhrase{
+		{Phrase: model.NewPhrase(3, 1, nil, "a", now, now), Upvotes: 1, Score: Wilson(1, 0)},
+		{Phrase: model.NewPhrase(2, 1, nil, "b", now, now), Upvotes: 1, Score: Wilson(1, 0)},
+		{Phrase: model.NewPhrase(1, 1, nil, "c", now, now), Upvotes: 10, Downvotes: 1, Score: Wilson(10, 1)},
+	}
+
+	rank(ranked)
+
+	assert.Equal(t, int64(1), ranked[0].Phrase.ID)
+	assert.Equal(t, int64(2), ranked[1].Phrase.ID)
+	assert.Equal(t, int64(3), ranked[2].Phrase.ID)
+}
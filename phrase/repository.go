@@ -1,96 +1,709 @@
 package phrase
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"github.com/airabinovich/memequotes_back/config"
 	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
 	customErrors "github.com/airabinovich/memequotes_back/errors"
 	"github.com/airabinovich/memequotes_back/model"
-	"github.com/gin-gonic/gin"
+	"github.com/airabinovich/memequotes_back/slug"
 	"github.com/jinzhu/gorm"
 	"time"
 )
 
+// maxSlugLength is how much of the start of a phrase goes in its slug
+const maxSlugLength = 60
+
 type DBPhraseRepository struct {
-	db *gorm.DB
+	cluster *database.Cluster
 }
 
-func NewDBPhraseRepository(db *gorm.DB) DBPhraseRepository {
+func NewDBPhraseRepository(cluster *database.Cluster) DBPhraseRepository {
 	return DBPhraseRepository{
-		db: db,
+		cluster: cluster,
 	}
 }
 
-func (repo DBPhraseRepository) Get(c *gin.Context, characterId int64, id int64) (model.Phrase, bool, error) {
-	ctx := commonContext.RequestContext(c)
+func (repo DBPhraseRepository) Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error) {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Getting Phrase with characterId %d and id %d", characterId, id))
+	phrase, found, err := repo.GetByID(ctx, id)
+	if err != nil || !found {
+		return model.Phrase{}, found, err
+	}
+
+	if phrase.CharacterId != characterId {
+		return model.Phrase{}, false, customErrors.NewUnauthorizedError("phrase doesn't belong to character")
+	}
+
+	return phrase, true, nil
+}
+
+func (repo DBPhraseRepository) GetByID(ctx context.Context, id int64) (model.Phrase, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Phrase with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
 
 	phrase := model.Phrase{}
-	db := repo.db.Where("id = ?", id).First(&phrase)
-	notFound := db.RecordNotFound()
-	if db.Error != nil && !notFound {
-		return model.Phrase{}, false, db.Error
+	notFound := false
+	err := database.RetryRead(ctx, "getting phrase", func() error {
+		result := db.Where("id = ?", id).First(&phrase)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return model.Phrase{}, false, database.TranslateError(err)
 	}
 
 	if notFound {
 		return model.Phrase{}, false, nil
 	}
+	return phrase, true, nil
+}
 
-	if phrase.CharacterId != characterId {
-		return model.Phrase{}, false, customErrors.NewUnauthorizedError("phrase doesn't belong to character")
-	}
+func (repo DBPhraseRepository) GetBySlug(ctx context.Context, characterId int64, phraseSlug string) (model.Phrase, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Phrase with characterId %d and slug %s", characterId, phraseSlug))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
 
+	phrase := model.Phrase{}
+	notFound := false
+	err := database.RetryRead(ctx, "getting phrase by slug", func() error {
+		result := db.Where("character_id = ? AND slug = ?", characterId, phraseSlug).Find(&phrase)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return model.Phrase{}, false, database.TranslateError(err)
+	}
 	return phrase, !notFound, nil
 }
 
-func (repo DBPhraseRepository) GetAllForCharacter(c *gin.Context, characterId int64) ([]model.Phrase, bool, error) {
-	ctx := commonContext.RequestContext(c)
+func (repo DBPhraseRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Phrase, bool, error) {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Getting Phrase with characterId %d", characterId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
 
 	phrases := make([]model.Phrase, 0)
-	db := repo.db.Where("character_id = ?", characterId).Find(&phrases)
-	notFound := db.RecordNotFound()
-	if db.Error != nil && !notFound {
-		return nil, false, db.Error
+	notFound := false
+	err := database.RetryRead(ctx, "getting phrases for character", func() error {
+		result := db.Where("character_id = ? AND status = ?", characterId, model.PhraseStatusApproved).Find(&phrases)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return nil, false, database.TranslateError(err)
 	}
 	return phrases, !notFound, nil
 }
 
-func (repo DBPhraseRepository) Save(c *gin.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
-	ctx := commonContext.RequestContext(c)
+// GetAll retrieves every phrase of every character, ordered by character
+func (repo DBPhraseRepository) GetAll(ctx context.Context) ([]model.Phrase, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug("Getting all phrases")
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	phrases := make([]model.Phrase, 0)
+	err := database.RetryRead(ctx, "getting all phrases", func() error {
+		return db.Order("character_id, id").Find(&phrases).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return phrases, nil
+}
+
+// Save stores the phrase unless the character already has it after normalization. Similar phrases are only
+// logged, they are listed by the duplicates report
+func (repo DBPhraseRepository) Save(ctx context.Context, phCmd model.PhraseCommand) (model.Phrase, error) {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Creating Phrase for character %d", phCmd.CharacterId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
 	now := time.Now()
 	phrase := model.NewPhrase(0, phCmd.CharacterId, nil, phCmd.Content, now, now)
-	if !repo.db.NewRecord(phrase) {
-		return model.Phrase{}, errors.New("phrase already exists")
+	phrase.SourceId = phCmd.SourceId
+	phrase.SourceTimestamp = phCmd.SourceTimestamp
+	phrase.Status = phCmd.Status
+	if phrase.Status == "" {
+		phrase.Status = model.PhraseStatusPending
 	}
-	if err := repo.db.Create(&phrase).Error; err != nil {
+	phrase.SubmittedBy = phCmd.SubmittedBy
+	phrase.Rating = model.StrongerRating(phCmd.Rating, model.RatingSafe)
+	phrase.ContentWarnings = model.JoinWarnings(phCmd.ContentWarnings)
+	phrase.NSFW = phCmd.NSFW || phrase.Rating != model.RatingSafe
+	phrase.Version = 1
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "creating phrase", func(tx *gorm.DB) error {
+		// Locking the character makes concurrent saves of the same phrase wait for each other's check
+		ch := model.Character{}
+		result := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", phCmd.CharacterId).Find(&ch)
+		if result.RecordNotFound() {
+			return customErrors.NewNotFoundError(fmt.Sprintf("character %d not found", phCmd.CharacterId))
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if phCmd.SourceId != nil {
+			if err := checkSourceExists(tx, *phCmd.SourceId); err != nil {
+				return err
+			}
+		}
+
+		existing := make([]model.Phrase, 0)
+		if err := tx.Where("character_id = ?", phCmd.CharacterId).Find(&existing).Error; err != nil {
+			return err
+		}
+		// Rejected phrases keep their slug, but may be submitted again
+		candidates := make([]model.Phrase, 0, len(existing))
+		for _, phrase := range existing {
+			if phrase.Status != model.PhraseStatusRejected {
+				candidates = append(candidates, phrase)
+			}
+		}
+		if duplicate, found := findDuplicate(phCmd.Content, candidates); found {
+			return customErrors.NewDuplicateError(fmt.Sprintf("phrase %d of character %d is the same phrase", duplicate.ID, duplicate.CharacterId), duplicate.ID)
+		}
+		if similar := findSimilar(phCmd.Content, candidates, config.Current().Duplicates.SimilarityThreshold); len(similar) > 0 {
+			logger.Info(fmt.Sprintf("New phrase for character %d is similar to phrases %v", phCmd.CharacterId, similar))
+		}
+		phraseSlug, err := uniqueSlug(phCmd.Content, existing)
+		if err != nil {
+			return err
+		}
+		phrase.Slug = phraseSlug
+
+		return tx.Create(&phrase).Error
+	})
+	if err != nil {
 		logger.Error("creating phrase", err)
-		return model.Phrase{}, err
+		return model.Phrase{}, database.TranslateError(err)
 	}
 	return phrase, nil
 }
 
-func (repo DBPhraseRepository) Delete(c *gin.Context, characterId int64, id int64) error {
-	ctx := commonContext.RequestContext(c)
+func (repo DBPhraseRepository) Delete(ctx context.Context, characterId int64, id int64, precondition model.Precondition) error {
 	logger := commonContext.Logger(ctx)
 	logger.Debug(fmt.Sprintf("Getting Phrase with characterId %d and id %d", characterId, id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Writer(ctx)
 
-	phrase, found, err := repo.Get(c, characterId, id)
+	phrase, found, err := repo.Get(ctx, characterId, id)
 	if err != nil {
 		return err
 	}
 	if !found {
 		return nil
 	}
+	if precondition.Unconditional() {
+		if err := db.Delete(&phrase).Error; err != nil {
+			return database.TranslateError(err)
+		}
+		return nil
+	}
 
-	db := repo.db.Delete(&phrase)
-	if db.Error != nil {
-		return db.Error
+	// The version check makes the delete fail if another write got in since the phrase was read
+	if !precondition.Allows(phrase.Version) {
+		return phraseModifiedError(phrase)
+	}
+	result := db.Where("version = ?", phrase.Version).Delete(&phrase)
+	if result.Error != nil {
+		return database.TranslateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return phraseModifiedError(phrase)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func (repo DBPhraseRepository) DeleteAllForCharacter(ctx context.Context, characterId int64) error {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Deleting every Phrase of character %d", characterId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if err := repo.cluster.Writer(ctx).Where("character_id = ?", characterId).Delete(&model.Phrase{}).Error; err != nil {
+		return database.TranslateError(err)
+	}
+	return nil
+}
+
+func (repo DBPhraseRepository) SetSource(ctx context.Context, characterId int64, id int64, appearance *model.AppearanceCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Setting the source of Phrase %d of character %d", id, characterId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	phrase := model.Phrase{}
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "setting phrase source", func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND character_id = ?", id, characterId).Find(&phrase)
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if !precondition.Allows(phrase.Version) {
+			return phraseModifiedError(phrase)
+		}
+
+		var sourceId, sourceTimestamp *int64
+		if appearance != nil {
+			if err := checkSourceExists(tx, appearance.SourceId); err != nil {
+				return err
+			}
+			sourceId, sourceTimestamp = &appearance.SourceId, appearance.SourceTimestamp
+		}
+
+		// The version check makes the update fail if another write got in since the phrase was read
+		read := phrase
+		now := time.Now()
+		result = tx.Model(&phrase).Where("version = ?", read.Version).Updates(map[string]interface{}{
+			"source_id":        sourceId,
+			"source_timestamp": sourceTimestamp,
+			"last_updated":     now,
+			"version":          read.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return phraseModifiedError(read)
+		}
+
+		phrase.SourceId = sourceId
+		phrase.SourceTimestamp = sourceTimestamp
+		phrase.LastUpdated = now
+		phrase.Version = read.Version + 1
+		return nil
+	})
+	if err != nil {
+		logger.Error("setting phrase source", err)
+		return model.Phrase{}, found, database.TranslateError(err)
+	}
+	if !found {
+		return model.Phrase{}, false, nil
+	}
+	return phrase, true, nil
+}
+
+func (repo DBPhraseRepository) SetRating(ctx context.Context, characterId int64, id int64, ratingCmd model.RatingCommand, precondition model.Precondition) (model.Phrase, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Rating Phrase %d of character %d as %s", id, characterId, ratingCmd.Rating))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	phrase := model.Phrase{}
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "rating phrase", func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND character_id = ?", id, characterId).Find(&phrase)
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if !precondition.Allows(phrase.Version) {
+			return phraseModifiedError(phrase)
+		}
+
+		// The version check makes the update fail if another write got in since the phrase was read
+		read := phrase
+		now := time.Now()
+		warnings := model.JoinWarnings(ratingCmd.ContentWarnings)
+		nsfw := ratingCmd.Rating != model.RatingSafe
+		result = tx.Model(&phrase).Where("version = ?", read.Version).Updates(map[string]interface{}{
+			"rating":           ratingCmd.Rating,
+			"content_warnings": warnings,
+			"nsfw":             nsfw,
+			"last_updated":     now,
+			"version":          read.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return phraseModifiedError(read)
+		}
+		// The dialogue lines taken from the phrase are rated like it, and their dialogues change with them
+		err := tx.Exec("UPDATE dialogues SET last_updated = ?, version = version + 1 WHERE id IN "+
+			"(SELECT dialogue_id FROM dialogue_lines WHERE phrase_id = ? AND rating <> ?)", now, id, ratingCmd.Rating).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&model.DialogueLine{}).Where("phrase_id = ?", id).Update("rating", ratingCmd.Rating).Error; err != nil {
+			return err
+		}
+
+		phrase.Rating = ratingCmd.Rating
+		phrase.ContentWarnings = warnings
+		phrase.NSFW = nsfw
+		phrase.LastUpdated = now
+		phrase.Version = read.Version + 1
+		return nil
+	})
+	if err != nil {
+		logger.Error("rating phrase", err)
+		return model.Phrase{}, found, database.TranslateError(err)
+	}
+	if !found {
+		return model.Phrase{}, false, nil
+	}
+	return phrase, true, nil
+}
+
+func (repo DBPhraseRepository) Vote(ctx context.Context, characterId int64, id int64, voter string, value int) (model.Phrase, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Voting %d on Phrase %d of character %d", value, id, characterId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	phrase := model.Phrase{}
+	found := false
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "voting phrase", func(tx *gorm.DB) error {
+		// The lock keeps concurrent votes from losing each other's count. Phrases are voted once approved
+		result := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("id = ? AND character_id = ? AND status = ?", id, characterId, model.PhraseStatusApproved).Find(&phrase)
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		vote := model.Vote{}
+		result = tx.Where("phrase_id = ? AND voter = ?", id, voter).Find(&vote)
+		hadVote := !result.RecordNotFound()
+		if hadVote && result.Error != nil {
+			return result.Error
+		}
+		// GORM writes the updated values back into the vote, so the one it had is kept to take it from the count
+		previous := vote.Value
+		if previous == value {
+			return nil
+		}
+
+		now := time.Now()
+		var err error
+		switch {
+		case value == 0:
+			err = tx.Where("phrase_id = ? AND voter = ?", id, voter).Delete(&model.Vote{}).Error
+		case hadVote:
+			err = tx.Model(&vote).Where("phrase_id = ? AND voter = ?", id, voter).Updates(map[string]interface{}{
+				"value":        value,
+				"last_updated": now,
+			}).Error
+		default:
+			vote = model.Vote{PhraseId: id, Voter: voter, Value: value, DateCreated: now, LastUpdated: now}
+			err = tx.Create(&vote).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		upvotes, downvotes := phrase.Upvotes, phrase.Downvotes
+		if hadVote {
+			upvotes, downvotes = countVote(upvotes, downvotes, previous, -1)
+		}
+		upvotes, downvotes = countVote(upvotes, downvotes, value, 1)
+		// Votes are counted, they don't edit the phrase, so its version and last update stay and the
+		// preconditions and validators of the phrase don't change with every vote
+		score := Wilson(upvotes, downvotes)
+		result = tx.Model(&phrase).UpdateColumns(map[string]interface{}{
+			"upvotes":   upvotes,
+			"downvotes": downvotes,
+			"score":     score,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		phrase.Upvotes = upvotes
+		phrase.Downvotes = downvotes
+		phrase.Score = score
+		return nil
+	})
+	if err != nil {
+		logger.Error("voting phrase", err)
+		return model.Phrase{}, found, database.TranslateError(err)
+	}
+	if !found {
+		return model.Phrase{}, false, nil
+	}
+	return phrase, true, nil
+}
+
+// countVote adds a vote to the votes of a phrase, or takes it out with a delta of -1
+func countVote(upvotes int64, downvotes int64, value int, delta int64) (int64, int64) {
+	switch value {
+	case model.Upvote:
+		upvotes += delta
+	case model.Downvote:
+		downvotes += delta
+	}
+	return upvotes, downvotes
+}
+
+func (repo DBPhraseRepository) GetTop(ctx context.Context, since time.Time, limit int, maxRating string) ([]model.RankedPhrase, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting top %d Phrases up to %s since %v", limit, maxRating, since))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	ranked := make([]model.RankedPhrase, 0)
+	err := database.RetryRead(ctx, "getting top phrases", func() error {
+		ranked = ranked[:0]
+		if since.IsZero() {
+			// Every vote is counted in the stored score, so the phrases are sorted by it
+			phrases := make([]model.Phrase, 0)
+			if err := db.Joins(model.JoinVisibleCharacters).Where("upvotes + downvotes > 0 AND status = ? AND rating IN (?)", model.PhraseStatusApproved, model.RatingsUpTo(maxRating)).Order("score DESC, upvotes DESC, phrases.id").Limit(limit).Find(&phrases).Error; err != nil {
+				return err
+			}
+			for _, phrase := range phrases {
+				ranked = append(ranked, model.RankedPhrase{Phrase: phrase, Upvotes: phrase.Upvotes, Downvotes: phrase.Downvotes, Score: phrase.Score})
+			}
+			return nil
+		}
+
+		var tallies []struct {
+			PhraseId  int64
+			Upvotes   int64
+			Downvotes int64
+		}
+		err := db.Table("phrase_votes").
+			Select("phrase_id, SUM(value = ?) AS upvotes, SUM(value = ?) AS downvotes", model.Upvote, model.Downvote).
+			Joins("JOIN phrases ON phrases.id = phrase_votes.phrase_id AND phrases.status = ? AND phrases.rating IN (?)",
+				model.PhraseStatusApproved, model.RatingsUpTo(maxRating)).
+			Joins(model.JoinVisibleCharacters).
+			Where("phrase_votes.last_updated >= ?", since).Group("phrase_id").Scan(&tallies).Error
+		if err != nil {
+			return err
+		}
+		for _, tally := range tallies {
+			ranked = append(ranked, model.RankedPhrase{
+				Phrase:    model.Phrase{ID: tally.PhraseId},
+				Upvotes:   tally.Upvotes,
+				Downvotes: tally.Downvotes,
+				Score:     Wilson(tally.Upvotes, tally.Downvotes),
+			})
+		}
+		rank(ranked)
+		if len(ranked) > limit {
+			ranked = ranked[:limit]
+		}
+
+		ids := make([]int64, len(ranked))
+		for i := range ranked {
+			ids[i] = ranked[i].Phrase.ID
+		}
+		phrases := make([]model.Phrase, 0)
+		if len(ids) > 0 {
+			if err := db.Where("id IN (?)", ids).Find(&phrases).Error; err != nil {
+				return err
+			}
+		}
+		byId := make(map[int64]model.Phrase, len(phrases))
+		for _, phrase := range phrases {
+			byId[phrase.ID] = phrase
+		}
+		for i := range ranked {
+			ranked[i].Phrase = byId[ranked[i].Phrase.ID]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return ranked, nil
+}
+
+func (repo DBPhraseRepository) GetByStatus(ctx context.Context, status string) ([]model.Phrase, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting %s phrases", status))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	phrases := make([]model.Phrase, 0)
+	err := database.RetryRead(ctx, "getting phrases by status", func() error {
+		return db.Where("status = ?", status).Order("date_created, id").Find(&phrases).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return phrases, nil
+}
+
+func (repo DBPhraseRepository) GetSubmissions(ctx context.Context, submitter string) ([]model.Phrase, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting phrases submitted by %s", submitter))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	phrases := make([]model.Phrase, 0)
+	err := database.RetryRead(ctx, "getting submitted phrases", func() error {
+		return db.Where("submitted_by = ?", submitter).Order("date_created DESC, id DESC").Find(&phrases).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return phrases, nil
+}
+
+// Moderate updates the phrases in one transaction, so a bulk decision is taken for all of them or for none
+func (repo DBPhraseRepository) Moderate(ctx context.Context, ids []int64, status string, moderator string, reason *string) ([]model.Phrase, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Moderating Phrases %v as %s", ids, status))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	phrases := make([]model.Phrase, 0)
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "moderating phrases", func(tx *gorm.DB) error {
+		phrases = phrases[:0]
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id IN (?)", ids).Order("id").Find(&phrases).Error; err != nil {
+			return err
+		}
+		if missing := missingPhrases(ids, phrases); len(missing) > 0 {
+			return customErrors.NewNotFoundError(fmt.Sprintf("phrases %v not found", missing))
+		}
+
+		now := time.Now()
+		for i := range phrases {
+			read := phrases[i].Version
+			err := tx.Model(&phrases[i]).Updates(map[string]interface{}{
+				"status":            status,
+				"moderated_by":      moderator,
+				"moderation_reason": reason,
+				"date_moderated":    now,
+				"last_updated":      now,
+				"version":           read + 1,
+			}).Error
+			if err != nil {
+				return err
+			}
+			phrases[i].Status = status
+			phrases[i].ModeratedBy = &moderator
+			phrases[i].ModerationReason = reason
+			phrases[i].DateModerated = &now
+			phrases[i].LastUpdated = now
+			phrases[i].Version = read + 1
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("moderating phrases", err)
+		return nil, database.TranslateError(err)
+	}
+	return phrases, nil
+}
+
+// missingPhrases returns the ids that none of the phrases has
+func missingPhrases(ids []int64, phrases []model.Phrase) []int64 {
+	found := make(map[int64]bool, len(phrases))
+	for _, phrase := range phrases {
+		found[phrase.ID] = true
+	}
+	missing := make([]int64, 0)
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// checkSourceExists fails with a NotFoundError when a phrase references a source that doesn't exist
+func checkSourceExists(tx *gorm.DB, sourceId int64) error {
+	result := tx.Where("id = ?", sourceId).Find(&model.Source{})
+	if result.RecordNotFound() {
+		return customErrors.NewNotFoundError(fmt.Sprintf("source %d not found", sourceId))
+	}
+	return result.Error
+}
+
+// uniqueSlug makes a slug out of the start of a phrase that no other phrase of its character goes by
+func uniqueSlug(content string, characterPhrases []model.Phrase) (string, error) {
+	taken := make(map[string]bool, len(characterPhrases))
+	for _, phrase := range characterPhrases {
+		taken[phrase.Slug] = true
+	}
+	return slug.Unique(slug.Make(content, maxSlugLength), "phrase", func(candidate string) (bool, error) {
+		return taken[candidate], nil
+	})
+}
+
+// BackfillSlugs gives a slug to the phrases created before they had one. Returns how many got one.
+// Every phrase is updated in its own transaction, so the timeout applies to each of them
+func (repo DBPhraseRepository) BackfillSlugs(ctx context.Context) (int, error) {
+	var pending []struct {
+		ID          int64
+		CharacterId int64
+		Content     string
+	}
+	readCtx, cancel := database.WithTimeout(ctx)
+	err := repo.cluster.Writer(readCtx).Table("phrases").Select("id, character_id, content").Where("slug IS NULL").Scan(&pending).Error
+	cancel()
+	if err != nil {
+		return 0, database.TranslateError(err)
+	}
+
+	for i, phrase := range pending {
+		if err := repo.backfillSlug(ctx, phrase.ID, phrase.CharacterId, phrase.Content); err != nil {
+			return i, database.TranslateError(err)
+		}
+	}
+	return len(pending), nil
+}
+
+func (repo DBPhraseRepository) backfillSlug(ctx context.Context, id int64, characterId int64, content string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Writer(ctx)
+
+	return database.WithTransaction(ctx, db, "backfilling phrase slug", func(tx *gorm.DB) error {
+		var slugs []string
+		if err := tx.Table("phrases").Where("character_id = ? AND slug IS NOT NULL", characterId).Pluck("slug", &slugs).Error; err != nil {
+			return err
+		}
+		characterPhrases := make([]model.Phrase, len(slugs))
+		for i, taken := range slugs {
+			characterPhrases[i].Slug = taken
+		}
+		phraseSlug, err := uniqueSlug(content, characterPhrases)
+		if err != nil {
+			return err
+		}
+		return tx.Table("phrases").Where("id = ? AND slug IS NULL", id).Update("slug", phraseSlug).Error
+	})
+}
+
+func phraseModifiedError(phrase model.Phrase) error {
+	return customErrors.NewPreconditionFailedError(fmt.Sprintf("phrase %d was modified, its version is no longer %d", phrase.ID, phrase.Version))
+}
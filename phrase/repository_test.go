@@ -0,0 +1,148 @@
+package phrase
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/airabinovich/memequotes_back/database"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeDB answers the queries on a table with the rows it has for it, and records every statement it runs
+type fakeDB struct {
+	tables map[string]fakeRows // By table name
+	execs  []fakeExec
+}
+
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.execs = append(s.db.execs, fakeExec{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	for table, rows := range s.db.tables {
+		if strings.Contains(s.query, "FROM `"+table+"`") {
+			return &fakeResult{rows: rows}, nil
+		}
+	}
+	return &fakeResult{}, nil
+}
+
+type fakeResult struct {
+	rows fakeRows
+	next int
+}
+
+func (r *fakeResult) Columns() []string { return r.rows.columns }
+func (r *fakeResult) Close() error      { return nil }
+
+func (r *fakeResult) Next(dest []driver.Value) error {
+	if r.next == len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
+
+// exec returns the first statement run that starts like the query
+func (f *fakeDB) exec(prefix string) (fakeExec, bool) {
+	for _, exec := range f.execs {
+		if strings.HasPrefix(exec.query, prefix) {
+			return exec, true
+		}
+	}
+	return fakeExec{}, false
+}
+
+func fakeRepository(t *testing.T, fake *fakeDB) DBPhraseRepository {
+	db, err := gorm.Open("mysql", sql.OpenDB(fake))
+	assert.NoError(t, err)
+	return NewDBPhraseRepository(database.NewCluster(db))
+}
+
+var phraseColumns = []string{"id", "character_id", "content", "upvotes", "downvotes", "status", "version"}
+
+func TestVoteChangedCountsBothVotes(t *testing.T) {
+	t.Log("Changing a vote should take the previous vote from the count and add the new one")
+
+	fake := &fakeDB{tables: map[string]fakeRows{
+		"phrases": {phraseColumns, [][]driver.Value{
+			{int64(1), int64(1), "miameeee", int64(3), int64(1), model.PhraseStatusApproved, int64(4)},
+		}},
+		"phrase_votes": {[]string{"phrase_id", "voter", "value"}, [][]driver.Value{
+			{int64(1), "ip:10.0.0.1", int64(model.Upvote)},
+		}},
+	}}
+
+	phrase, found, err := fakeRepository(t, fake).Vote(context.Background(), 1, 1, "ip:10.0.0.1", model.Downvote)
+
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(2), phrase.Upvotes)
+	assert.Equal(t, int64(2), phrase.Downvotes)
+	assert.Equal(t, Wilson(2, 2), phrase.Score)
+	assert.Equal(t, int64(4), phrase.Version)
+
+	counted, ok := fake.exec("UPDATE `phrases`")
+	assert.True(t, ok)
+	// Columns are set in the order of their names: downvotes, score, upvotes
+	assert.Equal(t, []driver.Value{int64(2), Wilson(2, 2), int64(2), int64(1)}, counted.args)
+}
+
+func TestModerateBumpsVersionOnce(t *testing.T) {
+	t.Log("Moderated phrases should be returned with the version they are saved with")
+
+	fake := &fakeDB{tables: map[string]fakeRows{
+		"phrases": {phraseColumns, [][]driver.Value{
+			{int64(1), int64(1), "miameeee", int64(0), int64(0), model.PhraseStatusPending, int64(4)},
+		}},
+	}}
+
+	phrases, err := fakeRepository(t, fake).Moderate(context.Background(), []int64{1}, model.PhraseStatusApproved, "ip:10.0.0.1", nil)
+
+	assert.NoError(t, err)
+	assert.Len(t, phrases, 1)
+	assert.Equal(t, int64(5), phrases[0].Version)
+
+	moderated, ok := fake.exec("UPDATE `phrases`")
+	assert.True(t, ok)
+	assert.Contains(t, moderated.args, driver.Value(int64(5)))
+}
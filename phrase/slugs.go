@@ -0,0 +1,86 @@
+package phrase
+
+import (
+	"fmt"
+	"strconv"
+
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/airabinovich/memequotes_back/slug"
+	"github.com/gin-gonic/gin"
+)
+
+// ResolvePhrase lets the routes with a :phrase-id take the phrase's slug instead of its id. It runs after
+// character.ResolveCharacter, since phrase slugs are only unique among the phrases of a character
+func ResolvePhrase(c *gin.Context) {
+	rest.ErrorWrapper(resolvePhrase, c)
+}
+
+func resolvePhrase(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	phraseSlug := c.Param("phrase-id")
+	if slug.IsID(phraseSlug) {
+		c.Next()
+		return nil
+	}
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		c.Abort()
+		return rest.NewBadRequest(err.Error())
+	}
+
+	phrase, found, err := phraseRepository.GetBySlug(ctx, characterId, phraseSlug)
+	if err != nil {
+		logger.Error("get phrase by slug", err)
+		c.Abort()
+		return err
+	}
+	if !found {
+		c.Abort()
+		return rest.NewResourceNotFound(fmt.Sprintf("phrase %s not found", phraseSlug))
+	}
+
+	for i, param := range c.Params {
+		if param.Key == "phrase-id" {
+			c.Params[i].Value = strconv.FormatInt(phrase.ID, 10)
+		}
+	}
+	c.Next()
+	return nil
+}
+
+// ResolvePhraseCharacter lets the routes with a :phrase-id and no :character-id find the phrase by its id alone. It
+// adds the phrase's character as :character-id, so character.ResolveCharacter and the handlers of the routes
+// nested in a character can run after it
+func ResolvePhraseCharacter(c *gin.Context) {
+	rest.ErrorWrapper(resolvePhraseCharacter, c)
+}
+
+func resolvePhraseCharacter(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("phrase-id"), 10, 64)
+	if err != nil {
+		c.Abort()
+		return rest.NewBadRequest(err.Error())
+	}
+
+	phrase, found, err := phraseRepository.GetByID(ctx, id)
+	if err != nil {
+		logger.Error("get phrase by id", err)
+		c.Abort()
+		return err
+	}
+	if !found {
+		c.Abort()
+		return rest.NewResourceNotFound(fmt.Sprintf("phrase %d not found", id))
+	}
+
+	c.Params = append(c.Params, gin.Param{Key: "character-id", Value: strconv.FormatInt(phrase.CharacterId, 10)})
+	c.Next()
+	return nil
+}
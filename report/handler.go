@@ -0,0 +1,274 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var reportRepository repository.ReportRepository
+var phraseRepository repository.PhraseRepository
+var characterRepository repository.CharacterRepository
+
+func Initialize(repRepo repository.ReportRepository, phRepo repository.PhraseRepository, chRepo repository.CharacterRepository) {
+	reportRepository = repRepo
+	phraseRepository = phRepo
+	characterRepository = chRepo
+}
+
+// ReportPhrase files the client's report on a phrase. The phrase goes back to the moderation queue once it
+// has reports.hide_threshold open reports
+func ReportPhrase(c *gin.Context) {
+	rest.ErrorWrapper(reportPhrase, c)
+}
+
+func reportPhrase(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric characterId", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("phrase-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting phrase with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	var reportCmd model.ReportCommand
+	if err := c.ShouldBindJSON(&reportCmd); err != nil {
+		logger.Error("reporting phrase bad body format", err)
+		return rest.NewValidationError(err)
+	}
+
+	phrase, found, err := phraseRepository.Get(ctx, characterId, id)
+	if err != nil {
+		logger.Error("get phrase by id", err)
+		return err
+	}
+	if !found || phrase.Status != model.PhraseStatusApproved {
+		return rest.NewResourceNotFound("phrase not found")
+	}
+
+	report, open, err := reportRepository.Save(ctx, newReport(ctx, model.ReportTargetPhrase, id, characterId, reportCmd))
+	if err != nil {
+		logger.Error("error reporting phrase", err)
+		return err
+	}
+	if threshold := config.Current().Reports.HideThreshold; open >= threshold {
+		// The report is already filed, so a failure is only logged and the next report hides the phrase
+		reason := fmt.Sprintf("hidden after %d reports", open)
+		if _, err := phraseRepository.Moderate(ctx, []int64{id}, model.PhraseStatusPending, model.HiddenByReports, &reason); err != nil {
+			logger.Error("hiding reported phrase", err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, model.ReportResultFromReport(report))
+	return nil
+}
+
+// ReportCharacter files the client's report on a character. The character is hidden once it has
+// reports.hide_threshold open reports
+func ReportCharacter(c *gin.Context) {
+	rest.ErrorWrapper(reportCharacter, c)
+}
+
+func reportCharacter(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	var reportCmd model.ReportCommand
+	if err := c.ShouldBindJSON(&reportCmd); err != nil {
+		logger.Error("reporting character bad body format", err)
+		return rest.NewValidationError(err)
+	}
+
+	ch, found, err := characterRepository.Get(ctx, id)
+	if err != nil {
+		logger.Error("get character by id", err)
+		return err
+	}
+	if !found || ch.Hidden {
+		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", id))
+	}
+
+	report, open, err := reportRepository.Save(ctx, newReport(ctx, model.ReportTargetCharacter, id, id, reportCmd))
+	if err != nil {
+		logger.Error("error reporting character", err)
+		return err
+	}
+	if threshold := config.Current().Reports.HideThreshold; open >= threshold {
+		// The report is already filed, so a failure is only logged and the next report hides the character
+		if _, _, err := characterRepository.SetHidden(ctx, id, true); err != nil {
+			logger.Error("hiding reported character", err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, model.ReportResultFromReport(report))
+	return nil
+}
+
+func newReport(ctx context.Context, targetType string, targetId int64, characterId int64, reportCmd model.ReportCommand) model.Report {
+	reportCmd.Details = strings.TrimSpace(reportCmd.Details)
+	return model.NewReport(0, targetType, targetId, characterId, commonContext.ClientID(ctx), reportCmd)
+}
+
+// GetReports returns the open reports grouped by the phrase or character reported, wrapped in a json object.
+// The most reported content comes first. ?status=resolved|dismissed lists the closed reports instead
+func GetReports(c *gin.Context) {
+	rest.ErrorWrapper(getReports, c)
+}
+
+func getReports(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	status := c.DefaultQuery("status", model.ReportStatusOpen)
+	if status != model.ReportStatusOpen && status != model.ReportStatusResolved && status != model.ReportStatusDismissed {
+		return rest.NewBadRequest("status must be open, resolved or dismissed")
+	}
+
+	reports, err := reportRepository.GetByStatus(ctx, status)
+	if err != nil {
+		logger.Error("get reports by status", err)
+		return err
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"results": groupReports(reports),
+	})
+	return nil
+}
+
+// ResolveReports agrees with the reports on a phrase or a character and takes the content down: the phrase
+// is rejected with the note as its reason, and the character stays hidden
+func ResolveReports(c *gin.Context) {
+	rest.ErrorWrapper(resolveReports, c)
+}
+
+func resolveReports(c *gin.Context) error {
+	return reviewReports(c, model.ReportStatusResolved)
+}
+
+// DismissReports disagrees with the reports on a phrase or a character and publishes the content again if the
+// reports hid it
+func DismissReports(c *gin.Context) {
+	rest.ErrorWrapper(dismissReports, c)
+}
+
+func dismissReports(c *gin.Context) error {
+	return reviewReports(c, model.ReportStatusDismissed)
+}
+
+func reviewReports(c *gin.Context, status string) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	var reviewCmd model.ReviewCommand
+	if err := c.ShouldBindJSON(&reviewCmd); err != nil {
+		logger.Error("reviewing reports bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	var note *string
+	if trimmed := strings.TrimSpace(reviewCmd.Note); trimmed != "" {
+		note = &trimmed
+	}
+	if note == nil && status == model.ReportStatusResolved && reviewCmd.TargetType == model.ReportTargetPhrase {
+		return rest.NewBadRequest("resolving the reports on a phrase needs a note")
+	}
+
+	reports, err := reportRepository.GetOpen(ctx, reviewCmd.TargetType, reviewCmd.TargetId)
+	if err != nil {
+		logger.Error("get open reports", err)
+		return err
+	}
+	if len(reports) == 0 {
+		return rest.NewResourceNotFound(fmt.Sprintf("%s %d has no open reports", reviewCmd.TargetType, reviewCmd.TargetId))
+	}
+
+	// The content is taken down or published before the reports are closed, so a failure leaves them open
+	// for the moderator to try again
+	logger.Debug(fmt.Sprintf("Reviewing reports on %s %d as %s", reviewCmd.TargetType, reviewCmd.TargetId, status))
+	if reviewCmd.TargetType == model.ReportTargetPhrase {
+		err = reviewPhrase(ctx, reports[0].CharacterId, reviewCmd.TargetId, status, note)
+	} else {
+		_, _, err = characterRepository.SetHidden(ctx, reviewCmd.TargetId, status == model.ReportStatusResolved)
+	}
+	if err != nil {
+		logger.Error("reviewing reported content", err)
+		return err
+	}
+
+	closed, err := reportRepository.Close(ctx, reviewCmd.TargetType, reviewCmd.TargetId, status, commonContext.ClientID(ctx), note)
+	if err != nil {
+		logger.Error("close reports", err)
+		return err
+	}
+	c.JSON(http.StatusOK, groupReports(closed)[0])
+	return nil
+}
+
+// reviewPhrase rejects a reported phrase, or approves it again when dismissing the reports that hid it. A
+// phrase removed since it was reported is left alone
+func reviewPhrase(ctx context.Context, characterId int64, id int64, status string, note *string) error {
+	phrase, found, err := phraseRepository.Get(ctx, characterId, id)
+	if err != nil || !found {
+		return err
+	}
+
+	moderator := commonContext.ClientID(ctx)
+	if status == model.ReportStatusResolved && phrase.Status != model.PhraseStatusRejected {
+		_, err = phraseRepository.Moderate(ctx, []int64{id}, model.PhraseStatusRejected, moderator, note)
+	}
+	hiddenByReports := phrase.ModeratedBy != nil && *phrase.ModeratedBy == model.HiddenByReports
+	if status == model.ReportStatusDismissed && phrase.Status == model.PhraseStatusPending && hiddenByReports {
+		_, err = phraseRepository.Moderate(ctx, []int64{id}, model.PhraseStatusApproved, moderator, nil)
+	}
+	return err
+}
+
+// groupReports puts together the reports on the same content, the most reported content first
+func groupReports(reports []model.Report) []model.ReportedTarget {
+	targets := make([]model.ReportedTarget, 0)
+	indexes := make(map[string]int)
+	for _, report := range reports {
+		key := fmt.Sprintf("%s:%d", report.TargetType, report.TargetId)
+		i, ok := indexes[key]
+		if !ok {
+			i = len(targets)
+			indexes[key] = i
+			targets = append(targets, model.ReportedTarget{
+				TargetType:  report.TargetType,
+				TargetId:    report.TargetId,
+				CharacterId: report.CharacterId,
+				Categories:  make(map[string]int),
+				Reports:     make([]model.ReportResult, 0),
+			})
+		}
+		targets[i].Count++
+		targets[i].Categories[report.Category]++
+		targets[i].Reports = append(targets[i].Reports, model.ReportResultFromReport(report))
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].Count > targets[j].Count
+	})
+	return targets
+}
@@ -0,0 +1,352 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/airabinovich/memequotes_back/auth"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var reportMockRepo reportMockRepository
+var phraseMockRepo phrasesMockRepository
+var characterMockRepo characterMockRepository
+
+func TestReportPhraseHidesAtThreshold(t *testing.T) {
+	t.Log("Reporting a phrase should send it back to the moderation queue once it has enough open reports")
+
+	now := time.Now()
+	phrase := model.NewPhrase(4, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusApproved
+
+	for open, hides := range map[int]bool{4: false, 5: true} {
+		resetMocks()
+		phraseMockRepo.On("Get", mock.Anything, int64(1), int64(4)).Return(phrase, true, nil)
+		reportMockRepo.On("Save", mock.Anything, mock.MatchedBy(func(report model.Report) bool {
+			return report.TargetType == model.ReportTargetPhrase && report.TargetId == 4 && report.CharacterId == 1 &&
+				report.Reporter == "key:partner" && report.Category == model.ReportCategoryMisattributed &&
+				report.Details == "From another show" && report.Status == model.ReportStatusOpen
+		})).Return(model.Report{ID: 9, Category: model.ReportCategoryMisattributed, Status: model.ReportStatusOpen}, open, nil)
+		if hides {
+			phraseMockRepo.On("Moderate", mock.Anything, []int64{4}, model.PhraseStatusPending, model.HiddenByReports, mock.Anything).
+				Return([]model.Phrase{phrase}, nil)
+		}
+
+		w := httptest.NewRecorder()
+		r := clientRouter("key:partner", auth.RoleUser)
+		r.POST("/character/:character-id/phrase/:phrase-id/report", ReportPhrase)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/phrase/4/report",
+			bytes.NewBufferString(`{"category": "misattributed", "details": " From another show "}`)))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"open"`)
+		reportMockRepo.AssertExpectations(t)
+		phraseMockRepo.AssertExpectations(t)
+		if !hides {
+			phraseMockRepo.AssertNotCalled(t, "Moderate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		}
+	}
+}
+
+func TestReportPhraseNotApproved(t *testing.T) {
+	t.Log("Reporting a phrase that is not approved should return Not Found")
+
+	resetMocks()
+
+	now := time.Now()
+	phrase := model.NewPhrase(4, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusPending
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(4)).Return(phrase, true, nil)
+
+	w := httptest.NewRecorder()
+	r := clientRouter("key:partner", auth.RoleUser)
+	r.POST("/character/:character-id/phrase/:phrase-id/report", ReportPhrase)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/phrase/4/report", bytes.NewBufferString(`{"category": "spam"}`)))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	reportMockRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestReportPhraseBadCategory(t *testing.T) {
+	t.Log("Reporting a phrase with an unknown category should return Bad Request")
+
+	resetMocks()
+
+	w := httptest.NewRecorder()
+	r := clientRouter("key:partner", auth.RoleUser)
+	r.POST("/character/:character-id/phrase/:phrase-id/report", ReportPhrase)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/phrase/4/report", bytes.NewBufferString(`{"category": "boring"}`)))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestReportCharacterHidesAtThreshold(t *testing.T) {
+	t.Log("Reporting a character should hide it once it has enough open reports")
+
+	resetMocks()
+
+	now := time.Now()
+	ch := model.NewCharacter(1, "Fort", now, now)
+	characterMockRepo.On("Get", mock.Anything, int64(1)).Return(ch, true, nil)
+	reportMockRepo.On("Save", mock.Anything, mock.MatchedBy(func(report model.Report) bool {
+		return report.TargetType == model.ReportTargetCharacter && report.TargetId == 1 && report.CharacterId == 1
+	})).Return(model.Report{ID: 9, Category: model.ReportCategoryOffensive, Status: model.ReportStatusOpen}, 5, nil)
+	ch.Hidden = true
+	characterMockRepo.On("SetHidden", mock.Anything, int64(1), true).Return(ch, true, nil)
+
+	w := httptest.NewRecorder()
+	r := clientRouter("key:partner", auth.RoleUser)
+	r.POST("/character/:character-id/report", ReportCharacter)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/report", bytes.NewBufferString(`{"category": "offensive"}`)))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	characterMockRepo.AssertExpectations(t)
+}
+
+func TestGetReportsGroupedByTarget(t *testing.T) {
+	t.Log("Reports should be grouped by the content reported, the most reported first")
+
+	resetMocks()
+
+	now := time.Now()
+	reports := []model.Report{
+		{ID: 1, TargetType: model.ReportTargetCharacter, TargetId: 1, CharacterId: 1, Category: model.ReportCategorySpam, Status: model.ReportStatusOpen, DateCreated: now, LastUpdated: now},
+		{ID: 2, TargetType: model.ReportTargetPhrase, TargetId: 4, CharacterId: 1, Category: model.ReportCategoryOffensive, Status: model.ReportStatusOpen, DateCreated: now, LastUpdated: now},
+		{ID: 3, TargetType: model.ReportTargetPhrase, TargetId: 4, CharacterId: 1, Category: model.ReportCategoryOffensive, Status: model.ReportStatusOpen, DateCreated: now, LastUpdated: now},
+	}
+	reportMockRepo.On("GetByStatus", mock.Anything, model.ReportStatusOpen).Return(reports, nil)
+
+	w := httptest.NewRecorder()
+	r := utils.TestRouter()
+	r.GET("/moderation/reports", GetReports)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/moderation/reports", nil))
+
+	var actual struct {
+		Results []model.ReportedTarget `json:"results"`
+	}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actual))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, actual.Results, 2) {
+		assert.Equal(t, model.ReportTargetPhrase, actual.Results[0].TargetType)
+		assert.Equal(t, 2, actual.Results[0].Count)
+		assert.Equal(t, 2, actual.Results[0].Categories[model.ReportCategoryOffensive])
+		assert.Equal(t, model.ReportTargetCharacter, actual.Results[1].TargetType)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/moderation/reports?status=pending", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDismissReportsRestoresPhrase(t *testing.T) {
+	t.Log("Dismissing the reports that hid a phrase should approve it again and close the reports")
+
+	resetMocks()
+
+	now := time.Now()
+	hiddenBy := model.HiddenByReports
+	phrase := model.NewPhrase(4, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusPending
+	phrase.ModeratedBy = &hiddenBy
+	report := model.Report{ID: 2, TargetType: model.ReportTargetPhrase, TargetId: 4, CharacterId: 1, Status: model.ReportStatusOpen, DateCreated: now, LastUpdated: now}
+	closed := report
+	closed.Status = model.ReportStatusDismissed
+	reportMockRepo.On("GetOpen", mock.Anything, model.ReportTargetPhrase, int64(4)).Return([]model.Report{report}, nil)
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(4)).Return(phrase, true, nil)
+	phraseMockRepo.On("Moderate", mock.Anything, []int64{4}, model.PhraseStatusApproved, "key:mods", (*string)(nil)).
+		Return([]model.Phrase{phrase}, nil)
+	reportMockRepo.On("Close", mock.Anything, model.ReportTargetPhrase, int64(4), model.ReportStatusDismissed, "key:mods", (*string)(nil)).
+		Return([]model.Report{closed}, nil)
+
+	w := httptest.NewRecorder()
+	r := clientRouter("key:mods", auth.RoleModerator)
+	r.POST("/moderation/reports/dismiss", DismissReports)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/moderation/reports/dismiss",
+		bytes.NewBufferString(`{"target_type": "phrase", "target_id": 4}`)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"dismissed"`)
+	phraseMockRepo.AssertExpectations(t)
+	reportMockRepo.AssertExpectations(t)
+}
+
+func TestResolveReportsRejectsPhrase(t *testing.T) {
+	t.Log("Resolving the reports on a phrase should reject it with the note, and needs a note")
+
+	resetMocks()
+
+	now := time.Now()
+	note := "Misattributed"
+	phrase := model.NewPhrase(4, 1, nil, "miameeee", now, now)
+	phrase.Status = model.PhraseStatusApproved
+	report := model.Report{ID: 2, TargetType: model.ReportTargetPhrase, TargetId: 4, CharacterId: 1, Status: model.ReportStatusOpen, DateCreated: now, LastUpdated: now}
+	reportMockRepo.On("GetOpen", mock.Anything, model.ReportTargetPhrase, int64(4)).Return([]model.Report{report}, nil)
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(4)).Return(phrase, true, nil)
+	phraseMockRepo.On("Moderate", mock.Anything, []int64{4}, model.PhraseStatusRejected, "key:mods", &note).
+		Return([]model.Phrase{phrase}, nil)
+	reportMockRepo.On("Close", mock.Anything, model.ReportTargetPhrase, int64(4), model.ReportStatusResolved, "key:mods", &note).
+		Return([]model.Report{report}, nil)
+
+	r := clientRouter("key:mods", auth.RoleModerator)
+	r.POST("/moderation/reports/resolve", ResolveReports)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/moderation/reports/resolve",
+		bytes.NewBufferString(`{"target_type": "phrase", "target_id": 4}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/moderation/reports/resolve",
+		bytes.NewBufferString(`{"target_type": "phrase", "target_id": 4, "note": "Misattributed"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	phraseMockRepo.AssertExpectations(t)
+	reportMockRepo.AssertExpectations(t)
+}
+
+func TestReviewReportsNotFound(t *testing.T) {
+	t.Log("Reviewing content without open reports should return Not Found and leave the content alone")
+
+	resetMocks()
+
+	reportMockRepo.On("GetOpen", mock.Anything, model.ReportTargetCharacter, int64(1)).Return([]model.Report{}, nil)
+
+	w := httptest.NewRecorder()
+	r := utils.TestRouter()
+	r.POST("/moderation/reports/dismiss", DismissReports)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/moderation/reports/dismiss",
+		bytes.NewBufferString(`{"target_type": "character", "target_id": 1}`)))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	characterMockRepo.AssertNotCalled(t, "SetHidden", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func resetMocks() {
+	reportMockRepo = reportMockRepository{}
+	phraseMockRepo = phrasesMockRepository{}
+	characterMockRepo = characterMockRepository{}
+	Initialize(&reportMockRepo, &phraseMockRepo, &characterMockRepo)
+}
+
+// clientRouter makes requests on behalf of a client with a role, like the API key and client id middlewares do
+func clientRouter(clientID string, role string) *gin.Engine {
+	r := utils.TestRouter()
+	r.Use(func(c *gin.Context) {
+		ctx := commonContext.WithRole(commonContext.WithClientID(commonContext.RequestContext(c), clientID), role)
+		commonContext.WithRequestContext(ctx, c)
+	})
+	return r
+}
+
+type reportMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *reportMockRepository) Save(ctx context.Context, report model.Report) (model.Report, int, error) {
+	args := repoMock.Called(ctx, report)
+
+	saved, ok := args.Get(0).(model.Report)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return saved, args.Int(1), args.Error(2)
+}
+
+func (repoMock *reportMockRepository) GetByStatus(ctx context.Context, status string) ([]model.Report, error) {
+	args := repoMock.Called(ctx, status)
+
+	reports, ok := args.Get(0).([]model.Report)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return reports, args.Error(1)
+}
+
+func (repoMock *reportMockRepository) GetOpen(ctx context.Context, targetType string, targetId int64) ([]model.Report, error) {
+	args := repoMock.Called(ctx, targetType, targetId)
+
+	reports, ok := args.Get(0).([]model.Report)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return reports, args.Error(1)
+}
+
+func (repoMock *reportMockRepository) Close(ctx context.Context, targetType string, targetId int64, status string, moderator string, note *string) ([]model.Report, error) {
+	args := repoMock.Called(ctx, targetType, targetId, status, moderator, note)
+
+	reports, ok := args.Get(0).([]model.Report)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return reports, args.Error(1)
+}
+
+type phrasesMockRepository struct {
+	mock.Mock
+	repository.PhraseRepository
+}
+
+func (repoMock *phrasesMockRepository) Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, args.Bool(1), args.Error(2)
+}
+
+func (repoMock *phrasesMockRepository) Moderate(ctx context.Context, ids []int64, status string, moderator string, reason *string) ([]model.Phrase, error) {
+	args := repoMock.Called(ctx, ids, status, moderator, reason)
+
+	phs, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return phs, args.Error(1)
+}
+
+type characterMockRepository struct {
+	mock.Mock
+	repository.CharacterRepository
+}
+
+func (repoMock *characterMockRepository) Get(ctx context.Context, id int64) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	ch, ok := args.Get(0).(model.Character)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ch, args.Bool(1), args.Error(2)
+}
+
+func (repoMock *characterMockRepository) SetHidden(ctx context.Context, id int64, hidden bool) (model.Character, bool, error) {
+	args := repoMock.Called(ctx, id, hidden)
+
+	ch, ok := args.Get(0).(model.Character)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ch, args.Bool(1), args.Error(2)
+}
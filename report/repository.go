@@ -0,0 +1,162 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type DBReportRepository struct {
+	cluster *database.Cluster
+}
+
+func NewDBReportRepository(cluster *database.Cluster) DBReportRepository {
+	return DBReportRepository{
+		cluster: cluster,
+	}
+}
+
+func (repo DBReportRepository) Save(ctx context.Context, report model.Report) (model.Report, int, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Reporting %s %d as %s", report.TargetType, report.TargetId, report.Category))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	open := 0
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "reporting content", func(tx *gorm.DB) error {
+		// Locking the reports on the content makes concurrent reports count one after the other, so the one
+		// reaching the threshold sees it
+		var reports []model.Report
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("target_type = ? AND target_id = ?", report.TargetType, report.TargetId).
+			Find(&reports).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var existing *model.Report
+		for i := range reports {
+			if reports[i].Reporter == report.Reporter {
+				existing = &reports[i]
+			}
+			if reports[i].Status == model.ReportStatusOpen {
+				open++
+			}
+		}
+		if existing == nil {
+			report.DateCreated = now
+			report.LastUpdated = now
+			open++
+			return tx.Create(&report).Error
+		}
+		if existing.Status != model.ReportStatusOpen {
+			return customErrors.NewConflictError(fmt.Sprintf("your report on %s %d was already reviewed", report.TargetType, report.TargetId))
+		}
+
+		err = tx.Model(existing).Updates(map[string]interface{}{
+			"category":     report.Category,
+			"details":      report.Details,
+			"last_updated": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		existing.Category = report.Category
+		existing.Details = report.Details
+		existing.LastUpdated = now
+		report = *existing
+		return nil
+	})
+	if err != nil {
+		logger.Error("reporting content", err)
+		return model.Report{}, 0, database.TranslateError(err)
+	}
+	return report, open, nil
+}
+
+func (repo DBReportRepository) GetByStatus(ctx context.Context, status string) ([]model.Report, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Reports with status %s", status))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	reports := make([]model.Report, 0)
+	err := database.RetryRead(ctx, "getting reports by status", func() error {
+		return db.Where("status = ?", status).Order("target_type, target_id, date_created, id").Find(&reports).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return reports, nil
+}
+
+func (repo DBReportRepository) GetOpen(ctx context.Context, targetType string, targetId int64) ([]model.Report, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting open Reports on %s %d", targetType, targetId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	reports := make([]model.Report, 0)
+	err := database.RetryRead(ctx, "getting open reports", func() error {
+		return db.Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetId, model.ReportStatusOpen).
+			Order("date_created, id").Find(&reports).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return reports, nil
+}
+
+func (repo DBReportRepository) Close(ctx context.Context, targetType string, targetId int64, status string, moderator string, note *string) ([]model.Report, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Closing Reports on %s %d as %s", targetType, targetId, status))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	reports := make([]model.Report, 0)
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "closing reports", func(tx *gorm.DB) error {
+		reports = reports[:0]
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetId, model.ReportStatusOpen).
+			Order("date_created, id").Find(&reports).Error
+		if err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			return customErrors.NewNotFoundError(fmt.Sprintf("%s %d has no open reports", targetType, targetId))
+		}
+
+		now := time.Now()
+		for i := range reports {
+			err := tx.Model(&reports[i]).Updates(map[string]interface{}{
+				"status":       status,
+				"note":         note,
+				"closed_by":    moderator,
+				"date_closed":  now,
+				"last_updated": now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			reports[i].Status = status
+			reports[i].Note = note
+			reports[i].ClosedBy = &moderator
+			reports[i].DateClosed = &now
+			reports[i].LastUpdated = now
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("closing reports", err)
+		return nil, database.TranslateError(err)
+	}
+	return reports, nil
+}
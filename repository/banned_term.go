@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+type BannedTermRepository interface {
+	// GetAll retrieves every term of the content filter, the ones of every character first
+	GetAll(ctx context.Context) ([]model.BannedTerm, error)
+
+	// Save stores a new term. The save fails with a ConflictError when the term is already banned for the same
+	// characters, and with a NotFoundError when its character doesn't exist
+	Save(ctx context.Context, termCmd model.BannedTermCommand) (model.BannedTerm, error)
+
+	// Delete a term. Deleting a term that doesn't exist does nothing
+	Delete(ctx context.Context, id int64) error
+}
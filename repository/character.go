@@ -1,23 +1,60 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/airabinovich/memequotes_back/model"
-	"github.com/gin-gonic/gin"
 )
 
 type CharacterRepository interface {
 	// Get a Character by id. Returns the character, whether it's found and an error
-	Get(c *gin.Context, id int64) (model.Character, bool, error)
+	Get(ctx context.Context, id int64) (model.Character, bool, error)
+
+	// GetByName finds the Character going by a name, either its own or an alias. Names are compared
+	// ignoring case and accents. Returns the character, whether it's found and an error
+	GetByName(ctx context.Context, name string) (model.Character, bool, error)
+
+	// GetBySlug finds a Character by its slug or by a slug it went by before a rename. A slug other than the
+	// character's own is a redirect. Returns the character, whether it's found and an error
+	GetBySlug(ctx context.Context, slug string) (model.Character, bool, error)
+
+	// GetAll retrieves all character in the repository, except the hidden ones
+	GetAll(ctx context.Context) ([]model.Character, error)
+
+	// Save stores a new character. The save fails with a ConflictError when a character already goes by its name
+	Save(ctx context.Context, chCmd model.CharacterCommand) (model.Character, error)
+
+	// Update a character. Returns the updated character, whether it's found and an error. A new name gives the
+	// character a new slug, and the old one keeps redirecting to it.
+	// The update fails with a PreconditionFailedError when the character's version is not allowed
+	Update(ctx context.Context, id int64, chCmd model.CharacterCommand, precondition model.Precondition) (model.Character, bool, error)
+
+	// SetHidden hides a character from the listing and from the clients below moderator, or shows it again.
+	// Returns the character, whether it's found and an error
+	SetHidden(ctx context.Context, id int64, hidden bool) (model.Character, bool, error)
+
+	// Delete a character and its aliases. If the character has phrases this will fail. Remove all phrases before.
+	// The delete fails with a PreconditionFailedError when the character's version is not allowed
+	Delete(ctx context.Context, id int64, precondition model.Precondition) error
+
+	// GetAliases retrieves the aliases of a character. Returns the aliases, whether the character is found and an error
+	GetAliases(ctx context.Context, id int64) ([]model.Alias, bool, error)
 
-	// GetAll retrieves all character in the repository
-	GetAll(c *gin.Context) ([]model.Character, error)
+	// AddAlias adds an alias to a character. Returns the alias, whether the character is found and an error.
+	// Adding fails with a ConflictError when a character already goes by the alias
+	AddAlias(ctx context.Context, id int64, alCmd model.AliasCommand) (model.Alias, bool, error)
 
-	// Save stores a new character
-	Save(c *gin.Context, chCmd model.CharacterCommand) (model.Character, error)
+	// DeleteAlias removes an alias from a character
+	DeleteAlias(ctx context.Context, id int64, aliasId int64) error
 
-	// Update a character. Returns the updated character, whether it's found and an error
-	Update(c *gin.Context, id int64, chCmd model.CharacterCommand) (model.Character, bool, error)
+	// PreviewMerge tells what merging the source character into the target would do, without changing them.
+	// Returns the plan, whether the target is found and an error. It fails with a NotFoundError when the
+	// source is not found
+	PreviewMerge(ctx context.Context, targetId int64, sourceId int64) (model.MergePlan, bool, error)
 
-	// Delete a character. If the character has phrases this will fail. Remove all phrases before
-	Delete(c *gin.Context, id int64) error
+	// Merge moves the phrases, aliases and dialogue lines of the source character to the target and removes the
+	// source. Its name becomes an alias of the target and its slug redirects to the target. Phrases the target
+	// already has are dropped, and the merge is recorded. Returns the plan carried out, whether the target is
+	// found and an error. The merge fails with a PreconditionFailedError when the target's version is not allowed
+	Merge(ctx context.Context, targetId int64, sourceId int64, precondition model.Precondition) (model.MergePlan, bool, error)
 }
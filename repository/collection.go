@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+// CollectionRepository stores the collections of phrases of the clients. Writes take the client making them,
+// and fail with a ForbiddenError when it doesn't own a public collection and as not found when it doesn't own
+// a private one
+type CollectionRepository interface {
+	// Get a Collection by id, with its entries in order. Returns the collection, whether it's found and an error
+	Get(ctx context.Context, id int64) (model.Collection, bool, error)
+
+	// GetByShareToken gets a Collection like Get, by the token it is shared with
+	GetByShareToken(ctx context.Context, token string) (model.Collection, bool, error)
+
+	// GetAllForOwner retrieves the collections of a client
+	GetAllForOwner(ctx context.Context, owner string) ([]model.Collection, error)
+
+	// GetPhrases retrieves the approved phrases of a collection in order
+	GetPhrases(ctx context.Context, id int64) ([]model.Phrase, error)
+
+	// Save stores a new collection of a client, with a new share token
+	Save(ctx context.Context, owner string, colCmd model.CollectionCommand) (model.Collection, error)
+
+	// Update renames a collection and changes its visibility. Returns the updated collection, whether it's
+	// found and an error. It fails with a PreconditionFailedError when the collection's version is not allowed
+	Update(ctx context.Context, id int64, owner string, colCmd model.CollectionCommand, precondition model.Precondition) (model.Collection, bool, error)
+
+	// Delete a collection and its entries. The delete fails with a PreconditionFailedError when the
+	// collection's version is not allowed
+	Delete(ctx context.Context, id int64, owner string, precondition model.Precondition) error
+
+	// AddPhrase puts an approved phrase in a collection. It fails like Update, with a NotFoundError when the
+	// phrase doesn't exist or isn't approved, and with a ConflictError when the collection already has it
+	AddPhrase(ctx context.Context, id int64, owner string, entryCmd model.CollectionEntryCommand, precondition model.Precondition) (model.Collection, bool, error)
+
+	// RemovePhrase takes a phrase out of a collection. It fails like Update, and with a NotFoundError when the
+	// collection doesn't have the phrase
+	RemovePhrase(ctx context.Context, id int64, owner string, phraseId int64, precondition model.Precondition) (model.Collection, bool, error)
+
+	// Reorder puts the phrases of a collection in a new order. It fails like Update, and with a
+	// ValidationError when the phrases are not the ones the collection has
+	Reorder(ctx context.Context, id int64, owner string, phraseIds []int64, precondition model.Precondition) (model.Collection, bool, error)
+}
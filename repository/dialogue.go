@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+type DialogueRepository interface {
+	// Get a Dialogue by id, with its lines in order. Returns the dialogue, whether it's found and an error
+	Get(ctx context.Context, id int64) (model.Dialogue, bool, error)
+
+	// GetAll retrieves all dialogues in the repository
+	GetAll(ctx context.Context) ([]model.Dialogue, error)
+
+	// GetAllForCharacter retrieves the dialogues a character has a line in. Returns the dialogues, whether the
+	// character is found and an error
+	GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Dialogue, bool, error)
+
+	// Save stores a new dialogue. The save fails with a NotFoundError when a character or phrase of its lines
+	// doesn't exist or a phrase isn't approved, and with a ValidationError when a phrase doesn't belong to the
+	// character of its line
+	Save(ctx context.Context, dlgCmd model.DialogueCommand) (model.Dialogue, error)
+
+	// Update replaces the title and lines of a dialogue. Returns the updated dialogue, whether it's found and an
+	// error. It fails like Save, and with a PreconditionFailedError when the dialogue's version is not allowed
+	Update(ctx context.Context, id int64, dlgCmd model.DialogueCommand, precondition model.Precondition) (model.Dialogue, bool, error)
+
+	// Delete a dialogue and its lines. The delete fails with a PreconditionFailedError when the dialogue's
+	// version is not allowed
+	Delete(ctx context.Context, id int64, precondition model.Precondition) error
+}
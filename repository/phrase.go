@@ -1,20 +1,66 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"github.com/airabinovich/memequotes_back/model"
-	"github.com/gin-gonic/gin"
 )
 
 type PhraseRepository interface {
 	// Get a phrase for a character
-	Get(c *gin.Context, characterId int64, id int64) (model.Phrase, bool, error)
+	Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error)
+
+	// GetByID finds a phrase by its id, whatever its character
+	GetByID(ctx context.Context, id int64) (model.Phrase, bool, error)
+
+	// GetBySlug finds a phrase of a character by its slug
+	GetBySlug(ctx context.Context, characterId int64, slug string) (model.Phrase, bool, error)
+
+	// GetAllForCharacter retrieves the approved phrases from a character
+	GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Phrase, bool, error)
+
+	// GetAll retrieves every phrase of every character, whatever their status
+	GetAll(ctx context.Context) ([]model.Phrase, error)
+
+	// Save stores a new phrase for a character, with the status and submitter of the command. The save fails with a DuplicateError when the character
+	// already has the same phrase after normalization, and with a NotFoundError when its source doesn't exist
+	Save(ctx context.Context, phCmd model.PhraseCommand) (model.Phrase, error)
+
+	// SetSource links a phrase of a character to the source it was said in, or unlinks it when the
+	// appearance is nil. Returns the updated phrase, whether it's found and an error. It fails with a
+	// NotFoundError when the source doesn't exist, and with a PreconditionFailedError when the phrase's
+	// version is not allowed
+	SetSource(ctx context.Context, characterId int64, id int64, appearance *model.AppearanceCommand, precondition model.Precondition) (model.Phrase, bool, error)
+
+	// SetRating gives a phrase of a character its content rating and warnings, replacing the ones it had. Returns
+	// the updated phrase, whether it's found and an error. It fails with a PreconditionFailedError when the
+	// phrase's version is not allowed
+	SetRating(ctx context.Context, characterId int64, id int64, ratingCmd model.RatingCommand, precondition model.Precondition) (model.Phrase, bool, error)
+
+	// DeleteAllForCharacter deletes every phrase of a character, whatever their status
+	DeleteAllForCharacter(ctx context.Context, characterId int64) error
+
+	// Delete a phrase for a character. The delete fails with a PreconditionFailedError when the phrase's
+	// version is not allowed
+	Delete(ctx context.Context, characterId int64, id int64, precondition model.Precondition) error
+
+	// Vote records the vote of a client for an approved phrase of a character, replacing the one it had, and keeps the
+	// phrase's votes and score up to date. A zero value removes the vote. Returns the phrase with its new votes,
+	// whether it's found and an error
+	Vote(ctx context.Context, characterId int64, id int64, voter string, value int) (model.Phrase, bool, error)
+
+	// GetTop ranks the approved phrases rated up to maxRating by the score of the votes they got since a time, or of
+	// all their votes when it's zero. Returns at most limit phrases, best first
+	GetTop(ctx context.Context, since time.Time, limit int, maxRating string) ([]model.RankedPhrase, error)
 
-	// GetAllForCharacter retrieves all phrases from a character
-	GetAllForCharacter(c *gin.Context, characterId int64) ([]model.Phrase, bool, error)
+	// GetByStatus retrieves the phrases with a status, the ones submitted first first
+	GetByStatus(ctx context.Context, status string) ([]model.Phrase, error)
 
-	// Save stores a new phrase for a character
-	Save(c *gin.Context, phCmd model.PhraseCommand) (model.Phrase, error)
+	// GetSubmissions retrieves the phrases a client submitted, whatever their status, newest first
+	GetSubmissions(ctx context.Context, submitter string) ([]model.Phrase, error)
 
-	// Delete a phrase for a character
-	Delete(c *gin.Context, characterId int64, id int64) error
+	// Moderate gives a status to phrases at once, with the moderator and the reason for it. Returns the
+	// moderated phrases. It fails with a NotFoundError, and moderates none, when a phrase doesn't exist
+	Moderate(ctx context.Context, ids []int64, status string, moderator string, reason *string) ([]model.Phrase, error)
 }
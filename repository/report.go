@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+type ReportRepository interface {
+	// Save files a report, or replaces the category and details of the open report its reporter already filed
+	// on the same content. Returns the report and how many open reports the content has. The save fails with a
+	// ConflictError when the reporter's report on the content was already closed by a moderator
+	Save(ctx context.Context, report model.Report) (model.Report, int, error)
+
+	// GetByStatus retrieves the reports with a status, grouped by the content reported and oldest first
+	GetByStatus(ctx context.Context, status string) ([]model.Report, error)
+
+	// GetOpen retrieves the open reports on a phrase or a character, oldest first
+	GetOpen(ctx context.Context, targetType string, targetId int64) ([]model.Report, error)
+
+	// Close gives a status to the open reports on a phrase or a character, with the moderator and the note for
+	// it. Returns the closed reports. It fails with a NotFoundError when the content has no open reports
+	Close(ctx context.Context, targetType string, targetId int64, status string, moderator string, note *string) ([]model.Report, error)
+}
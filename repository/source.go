@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+type SourceRepository interface {
+	// Get a Source by id. Returns the source, whether it's found and an error
+	Get(ctx context.Context, id int64) (model.Source, bool, error)
+
+	// GetByIds retrieves the sources with the ids. Ids that are not found are left out
+	GetByIds(ctx context.Context, ids []int64) ([]model.Source, error)
+
+	// GetAll retrieves all sources in the repository
+	GetAll(ctx context.Context) ([]model.Source, error)
+
+	// GetAllForCharacter retrieves the sources the phrases of a character were said in. Returns the sources,
+	// whether the character is found and an error
+	GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Source, bool, error)
+
+	// GetPhrases retrieves the approved phrases said in a source, ordered by their timestamp. Returns the phrases,
+	// whether the source is found and an error
+	GetPhrases(ctx context.Context, id int64) ([]model.Phrase, bool, error)
+
+	// Save stores a new source
+	Save(ctx context.Context, srcCmd model.SourceCommand) (model.Source, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/model"
+)
+
+type TrendingRepository interface {
+	// AddCounts adds events to the counts of their phrases and buckets
+	AddCounts(ctx context.Context, counts []model.PhraseCounts) error
+
+	// GetTrending ranks the phrases rated up to maxRating by their events since a time, the recent ones weighing
+	// more. Events lose half their weight every halfLife. Returns at most limit phrases, hottest first
+	GetTrending(ctx context.Context, since time.Time, halfLife time.Duration, limit int, maxRating string) ([]model.TrendingPhrase, error)
+
+	// Prune removes the counts of the buckets before a time
+	Prune(ctx context.Context, before time.Time) error
+}
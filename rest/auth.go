@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"github.com/airabinovich/memequotes_back/auth"
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is the header clients send their API key in
+const APIKeyHeader = "X-API-Key"
+
+// Authenticate identifies the client by its API key. The key's name becomes the client id and its role and
+// rating ceiling are added to the request context. Requests without a key are anonymous, and an unknown key is rejected
+func Authenticate(c *gin.Context) {
+	key := c.GetHeader(APIKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	apiKey, ok := config.Current().Auth.Lookup(key)
+	if !ok {
+		writeError(c, NewUnauthorized("invalid API key"))
+		c.Abort()
+		return
+	}
+
+	ctx := commonContext.RequestContext(c)
+	ctx = commonContext.WithClientID(ctx, "key:"+apiKey.Name)
+	ctx = commonContext.WithRole(ctx, apiKey.Role)
+	ctx = commonContext.WithMaxRating(ctx, apiKey.MaxRating)
+	commonContext.WithRequestContext(ctx, c)
+	c.Next()
+}
+
+// RequireRole restricts the routes to API keys with at least the given role. Anonymous requests get 401
+// and keys with a lesser role get 403
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current := commonContext.Role(commonContext.RequestContext(c))
+		if current == auth.RoleAnonymous {
+			writeError(c, NewUnauthorized("an API key is required"))
+			c.Abort()
+			return
+		}
+		if !auth.AtLeast(current, role) {
+			writeError(c, NewForbidden("the API key is not allowed to do this"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,32 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/airabinovich/memequotes_back/auth"
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	t.Log("Admin routes should need an API key with the admin role")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{
+		"auth.api_keys.ops.key":  "0ps",
+		"auth.api_keys.ops.role": "admin",
+		"auth.api_keys.app.key":  "4pp",
+	}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	r := testRouter()
+	r.Use(Authenticate)
+	r.GET("/admin", RequireRole(auth.RoleAdmin), Health)
+
+	assert.Equal(t, http.StatusUnauthorized, utils.PerformRequest(r, http.MethodGet, "/admin", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, utils.PerformRequest(r, http.MethodGet, "/admin", map[string]string{APIKeyHeader: "wrong"}).Code)
+	assert.Equal(t, http.StatusForbidden, utils.PerformRequest(r, http.MethodGet, "/admin", map[string]string{APIKeyHeader: "4pp"}).Code)
+	assert.Equal(t, http.StatusOK, utils.PerformRequest(r, http.MethodGet, "/admin", map[string]string{APIKeyHeader: "0ps"}).Code)
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/gin-gonic/gin"
+)
+
+// CacheControl sets the Cache-Control header of a route to the policy configured for it in cache_control
+func CacheControl(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy := config.Current().CacheControl.Policy(route); policy != "" {
+			c.Header("Cache-Control", policy)
+		}
+		c.Next()
+	}
+}
+
+// CachedJSON renders a successful response with ETag and Last-Modified validators, answering 304 Not
+// Modified when the client's copy is still fresh. lastModified is the latest update of the resources
+// in the body, and it's left out when zero. The ETag is computed from the body, so it also changes when
+// a resource is removed from a list
+func CachedJSON(c *gin.Context, lastModified time.Time, body interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	writeCached(c, entityTag(content), lastModified, content)
+	return nil
+}
+
+// VersionedJSON renders a single resource like CachedJSON, with its version as the ETag. Clients send
+// that ETag back in If-Match to update or delete the resource
+func VersionedJSON(c *gin.Context, version int64, lastModified time.Time, body interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	writeCached(c, VersionTag(version), lastModified, content)
+	return nil
+}
+
+func writeCached(c *gin.Context, etag string, lastModified time.Time, content []byte) {
+	c.Header("ETag", etag)
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+
+	if notModified(c.Request, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", content)
+}
+
+// LatestUpdate returns the most recent of the update times
+func LatestUpdate(updates ...time.Time) time.Time {
+	var latest time.Time
+	for _, update := range updates {
+		if update.After(latest) {
+			latest = update
+		}
+	}
+	return latest
+}
+
+func entityTag(content []byte) string {
+	sum := sha1.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// notModified evaluates the conditional GET headers. If-None-Match takes precedence over
+// If-Modified-Since, as RFC 7232 requires
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(since)
+}
+
+// etagMatches uses the weak comparison, so W/"x" matches "x"
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var cachedUpdate = time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC)
+
+func cachedRouter() *gin.Engine {
+	r := testRouter()
+	r.GET("/cached", func(c *gin.Context) {
+		ErrorWrapper(func(c *gin.Context) error {
+			return CachedJSON(c, cachedUpdate, map[string]string{"name": "Fort"})
+		}, c)
+	})
+	return r
+}
+
+func TestCachedJSONSetsValidators(t *testing.T) {
+	t.Log("A cacheable response should carry ETag and Last-Modified")
+
+	w := utils.PerformRequest(cachedRouter(), http.MethodGet, "/cached", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.Equal(t, "Mon, 01 Jun 2020 12:30:00 GMT", w.Header().Get("Last-Modified"))
+	assert.JSONEq(t, `{"name": "Fort"}`, w.Body.String())
+}
+
+func TestCachedJSONIfNoneMatch(t *testing.T) {
+	t.Log("A matching If-None-Match should return 304 without a body")
+
+	r := cachedRouter()
+	etag := utils.PerformRequest(r, http.MethodGet, "/cached", nil).Header().Get("ETag")
+
+	w := utils.PerformRequest(r, http.MethodGet, "/cached", map[string]string{"If-None-Match": `"other", W/` + etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	w = utils.PerformRequest(r, http.MethodGet, "/cached", map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCachedJSONIfModifiedSince(t *testing.T) {
+	t.Log("If-Modified-Since should return 304 unless the resource changed afterwards")
+
+	r := cachedRouter()
+
+	w := utils.PerformRequest(r, http.MethodGet, "/cached", map[string]string{"If-Modified-Since": "Mon, 01 Jun 2020 12:30:00 GMT"})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = utils.PerformRequest(r, http.MethodGet, "/cached", map[string]string{"If-Modified-Since": "Mon, 01 Jun 2020 12:29:59 GMT"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCachedJSONIfNoneMatchTakesPrecedence(t *testing.T) {
+	t.Log("If-Modified-Since should be ignored when If-None-Match is sent")
+
+	w := utils.PerformRequest(cachedRouter(), http.MethodGet, "/cached", map[string]string{
+		"If-None-Match":     `"other"`,
+		"If-Modified-Since": "Mon, 01 Jun 2020 12:30:00 GMT",
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCacheControlPolicies(t *testing.T) {
+	t.Log("Routes should get their configured Cache-Control policy or the default one")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{"cache_control.routes.characters": "public, max-age=60"}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	r := testRouter()
+	r.GET("/characters", CacheControl("characters"), Health)
+	r.GET("/character", CacheControl("character"), Health)
+
+	assert.Equal(t, "public, max-age=60", utils.PerformRequest(r, http.MethodGet, "/characters", nil).Header().Get("Cache-Control"))
+	assert.Equal(t, "no-cache", utils.PerformRequest(r, http.MethodGet, "/character", nil).Header().Get("Cache-Control"))
+}
+
+func TestLatestUpdate(t *testing.T) {
+	t.Log("The latest update of a list should be its most recent one")
+
+	assert.Equal(t, cachedUpdate, LatestUpdate(cachedUpdate.Add(-time.Hour), cachedUpdate, cachedUpdate.Add(-time.Minute)))
+	assert.True(t, LatestUpdate().IsZero())
+}
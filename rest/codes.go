@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stable, machine-readable error codes. Clients may rely on these values, so they must never be renamed.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInternal         = "internal_error"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeConflict         = "conflict"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "service_unavailable"
+
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+)
+
+// ErrorCodeInfo describes an entry in the error code catalog
+type ErrorCodeInfo struct {
+	Code   string `json:"code"`
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+}
+
+var codeCatalog = map[string]ErrorCodeInfo{
+	CodeBadRequest:           {Code: CodeBadRequest, Title: "Bad Request", Status: http.StatusBadRequest},
+	CodeValidationFailed:     {Code: CodeValidationFailed, Title: "Validation Failed", Status: http.StatusBadRequest},
+	CodeUnauthorized:         {Code: CodeUnauthorized, Title: "Unauthorized", Status: http.StatusUnauthorized},
+	CodeNotFound:             {Code: CodeNotFound, Title: "Resource Not Found", Status: http.StatusNotFound},
+	CodeMethodNotAllowed:     {Code: CodeMethodNotAllowed, Title: "Method Not Allowed", Status: http.StatusMethodNotAllowed},
+	CodeForbidden:            {Code: CodeForbidden, Title: "Forbidden", Status: http.StatusForbidden},
+	CodeConflict:             {Code: CodeConflict, Title: "Conflict", Status: http.StatusConflict},
+	CodePreconditionFailed:   {Code: CodePreconditionFailed, Title: "Precondition Failed", Status: http.StatusPreconditionFailed},
+	CodePreconditionRequired: {Code: CodePreconditionRequired, Title: "Precondition Required", Status: http.StatusPreconditionRequired},
+	CodeIdempotencyKeyReused: {Code: CodeIdempotencyKeyReused, Title: "Idempotency Key Reused", Status: http.StatusUnprocessableEntity},
+	CodeRateLimited:          {Code: CodeRateLimited, Title: "Too Many Requests", Status: http.StatusTooManyRequests},
+	CodeInternal:             {Code: CodeInternal, Title: "Internal Server Error", Status: http.StatusInternalServerError},
+	CodeUnavailable:          {Code: CodeUnavailable, Title: "Service Unavailable", Status: http.StatusServiceUnavailable},
+}
+
+// ErrorCodes returns the catalog of error codes the API may answer with, sorted by code
+func ErrorCodes() []ErrorCodeInfo {
+	codes := make([]ErrorCodeInfo, 0, len(codeCatalog))
+	for _, info := range codeCatalog {
+		info.Type = problemType(info.Code)
+		codes = append(codes, info)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// ErrorCatalog lists every error code the API may answer with
+func ErrorCatalog(c *gin.Context) {
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"results": ErrorCodes(),
+	})
+}
+
+// codeTitle returns the human readable title for an error code
+func codeTitle(code string, status int) string {
+	if info, ok := codeCatalog[code]; ok {
+		return info.Title
+	}
+	return http.StatusText(status)
+}
@@ -7,26 +7,27 @@ import (
 
 // NoRouteHandler handles requests for non registered routes
 func NoRouteHandler(c *gin.Context) {
-	ErrorWrapper(func(c *gin.Context) *APIError {
+	ErrorWrapper(func(c *gin.Context) error {
 		return NewResourceNotFound(fmt.Sprintf("Resource not found for %s.", c.Request.URL.Path))
 	}, c)
 }
 
 // MethodNotAllowedHandler handles requests for registered routes with invalid http methods on their requests
 func MethodNotAllowedHandler(c *gin.Context) {
-	ErrorWrapper(func(c *gin.Context) *APIError {
+	ErrorWrapper(func(c *gin.Context) error {
 		return NewMethodNotAllowed("Method not allowed - %s - %s ", c.Request.Method, c.Request.URL.Path)
 	}, c)
 }
 
-// WrapperFunc is the func type for the custom handlers.
-type WrapperFunc func(c *gin.Context) *APIError
+// WrapperFunc is the func type for the custom handlers. Handlers may return an *APIError or any error,
+// typed errors from the errors package are mapped to their status by FromError.
+type WrapperFunc func(c *gin.Context) error
 
 // ErrorWrapper if handlerFunc return a error,then response will be composed from error's information.
 func ErrorWrapper(handlerFunc WrapperFunc, c *gin.Context) {
 	err := handlerFunc(c)
 	if err != nil {
-		c.JSON(err.Status, err)
+		writeError(c, FromError(err))
 	}
 }
 
@@ -1,14 +1,18 @@
 package rest
 
-
 import (
+	"errors"
 	"net/http"
 	"strings"
+
+	customErrors "github.com/airabinovich/memequotes_back/errors"
 )
 
 const (
 	// BadRequestMessage is the default message when the input parameters on a request are wrong or it is malformed.
 	BadRequestMessage = "Invalid request parameters."
+	// ValidationFailedMessage is the default message when the request body doesn't pass validation.
+	ValidationFailedMessage = "The request body is not valid."
 	// ResourceNotFoundMessage is the default message when a requested resource is not available.
 	ResourceNotFoundMessage = "Resource not found."
 	// MethodNotAllowedMessage is the default message when a HTTP verb is forbidden on a resource.
@@ -17,13 +21,33 @@ const (
 	InternalServerErrorMessage = "Internal Server Error."
 	// UnauthorizedMessage is the default message when a request doesn't have the authorization
 	UnauthorizedMessage = "Unauthorized"
+	// ForbiddenMessage is the default message when the caller is not allowed to perform an operation
+	ForbiddenMessage = "Forbidden"
+	// ConflictMessage is the default message when a request clashes with the current state of a resource
+	ConflictMessage = "The resource is in conflict with the request."
+	// PreconditionFailedMessage is the default message when a write expects a version that is no longer current
+	PreconditionFailedMessage = "The resource was modified, fetch it again before changing it."
+	// PreconditionRequiredMessage is the default message when a write doesn't say which version it expects
+	PreconditionRequiredMessage = "The If-Match header is required."
+	// IdempotencyKeyReusedMessage is the default message when an Idempotency-Key is sent again with a different request
+	IdempotencyKeyReusedMessage = "The Idempotency-Key was already used with a different request."
+	// TooManyRequestsMessage is the default message when a client goes over the rate limit
+	TooManyRequestsMessage = "Too many requests, slow down."
+	// ServiceUnavailableMessage is the default message when a dependency can't be reached
+	ServiceUnavailableMessage = "Service temporarily unavailable."
 )
 
 // APIError represents the standard error structure for the HTTP responses.
 type APIError struct {
-	Status  int    `json:"status"`
-	Message string `json:"message"`
-	Err     string `json:"error"`
+	Status  int          `json:"status"`
+	Message string       `json:"message"`
+	Err     string       `json:"error"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// Error returns the message of the APIError, so handlers can return it as an error
+func (apiErr *APIError) Error() string {
+	return apiErr.Message
 }
 
 // newAPIError creates and initializes an APIError.
@@ -42,7 +66,7 @@ func NewBadRequest(messages ...string) *APIError {
 		message = strings.Join(messages, " - ")
 	}
 
-	return newAPIError(http.StatusBadRequest, message, "bad_request")
+	return newAPIError(http.StatusBadRequest, message, CodeBadRequest)
 }
 
 // NewResourceNotFound creates an API Error for an unexisting resource.
@@ -52,7 +76,7 @@ func NewResourceNotFound(messages ...string) *APIError {
 		message = strings.Join(messages, " - ")
 	}
 
-	return newAPIError(http.StatusNotFound, message, "not_found")
+	return newAPIError(http.StatusNotFound, message, CodeNotFound)
 }
 
 // NewMethodNotAllowed creates an API Error for a forbidden verb on a resource.
@@ -62,7 +86,7 @@ func NewMethodNotAllowed(messages ...string) *APIError {
 		message = strings.Join(messages, " - ")
 	}
 
-	return newAPIError(http.StatusMethodNotAllowed, message, "method_not_allowed")
+	return newAPIError(http.StatusMethodNotAllowed, message, CodeMethodNotAllowed)
 }
 
 // NewInternalServerError creates an API Error for an unexpected condition.
@@ -72,14 +96,125 @@ func NewInternalServerError(messages ...string) *APIError {
 		message = strings.Join(messages, " - ")
 	}
 
-	return newAPIError(http.StatusInternalServerError, message, "internal_error")
+	return newAPIError(http.StatusInternalServerError, message, CodeInternal)
 }
 
+// NewUnauthorized creates an API Error for a request without the required authorization.
 func NewUnauthorized(messages ...string) *APIError {
 	message := UnauthorizedMessage
 	if len(messages) > 0 {
 		message = strings.Join(messages, " - ")
 	}
 
-	return newAPIError(http.StatusUnauthorized, message, "unauthorized")
-}
\ No newline at end of file
+	return newAPIError(http.StatusUnauthorized, message, CodeUnauthorized)
+}
+
+// NewForbidden creates an API Error for a caller that is not allowed to perform an operation.
+func NewForbidden(messages ...string) *APIError {
+	message := ForbiddenMessage
+	if len(messages) > 0 {
+		message = strings.Join(messages, " - ")
+	}
+
+	return newAPIError(http.StatusForbidden, message, CodeForbidden)
+}
+
+// NewConflict creates an API Error for a request that clashes with the current state of a resource.
+func NewConflict(messages ...string) *APIError {
+	message := ConflictMessage
+	if len(messages) > 0 {
+		message = strings.Join(messages, " - ")
+	}
+
+	return newAPIError(http.StatusConflict, message, CodeConflict)
+}
+
+// NewPreconditionFailed creates an API Error for a write that expects a version that is no longer current.
+func NewPreconditionFailed(messages ...string) *APIError {
+	message := PreconditionFailedMessage
+	if len(messages) > 0 {
+		message = strings.Join(messages, " - ")
+	}
+
+	return newAPIError(http.StatusPreconditionFailed, message, CodePreconditionFailed)
+}
+
+// NewPreconditionRequired creates an API Error for a write that doesn't say which version it expects.
+func NewPreconditionRequired(messages ...string) *APIError {
+	message := PreconditionRequiredMessage
+	if len(messages) > 0 {
+		message = strings.Join(messages, " - ")
+	}
+
+	return newAPIError(http.StatusPreconditionRequired, message, CodePreconditionRequired)
+}
+
+// NewIdempotencyKeyReused creates an API Error for an Idempotency-Key sent again with a different request.
+func NewIdempotencyKeyReused(messages ...string) *APIError {
+	message := IdempotencyKeyReusedMessage
+	if len(messages) > 0 {
+		message = strings.Join(messages, " - ")
+	}
+
+	return newAPIError(http.StatusUnprocessableEntity, message, CodeIdempotencyKeyReused)
+}
+
+// NewTooManyRequests creates an API Error for a client going over the rate limit.
+func NewTooManyRequests(messages ...string) *APIError {
+	message := TooManyRequestsMessage
+	if len(messages) > 0 {
+		message = strings.Join(messages, " - ")
+	}
+
+	return newAPIError(http.StatusTooManyRequests, message, CodeRateLimited)
+}
+
+// NewServiceUnavailable creates an API Error for a dependency that can't be reached.
+func NewServiceUnavailable(messages ...string) *APIError {
+	message := ServiceUnavailableMessage
+	if len(messages) > 0 {
+		message = strings.Join(messages, " - ")
+	}
+
+	return newAPIError(http.StatusServiceUnavailable, message, CodeUnavailable)
+}
+
+// FromError maps an error to the APIError sent to the client. Typed errors from the errors package get their
+// own status, anything else is an internal error.
+func FromError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var (
+		notFound     customErrors.NotFoundError
+		conflict     customErrors.ConflictError
+		duplicate    customErrors.DuplicateError
+		precondition customErrors.PreconditionFailedError
+		unauthorized customErrors.UnauthorizedError
+		forbidden    customErrors.ForbiddenError
+		validation   customErrors.ValidationError
+		unavailable  customErrors.UnavailableError
+	)
+	switch {
+	case errors.As(err, &notFound):
+		return NewResourceNotFound(notFound.Message)
+	case errors.As(err, &conflict):
+		return NewConflict(conflict.Message)
+	case errors.As(err, &duplicate):
+		return NewConflict(duplicate.Message)
+	case errors.As(err, &precondition):
+		return NewPreconditionFailed(precondition.Message)
+	case errors.As(err, &unauthorized):
+		return NewUnauthorized(unauthorized.Message)
+	case errors.As(err, &forbidden):
+		return NewForbidden(forbidden.Message)
+	case errors.As(err, &validation):
+		return newValidationAPIError(validation)
+	case errors.As(err, &unavailable):
+		return NewServiceUnavailable(unavailable.Error())
+	default:
+		return NewInternalServerError(err.Error())
+	}
+}
@@ -1,6 +1,15 @@
 package rest
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/airabinovich/memequotes_back/config"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/middleware"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"testing"
@@ -34,4 +43,153 @@ func TestNewUnauthorized(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, err.Status)
 	assert.Equal(t, "some error", err.Message)
 	assert.Equal(t, "unauthorized", err.Err)
-}
\ No newline at end of file
+}
+
+func TestErrorWrapperLegacyFormat(t *testing.T) {
+	t.Log("ErrorWrapper should render the legacy error format by default")
+
+	r := testRouter()
+	r.GET("/fail", func(c *gin.Context) {
+		ErrorWrapper(func(c *gin.Context) error {
+			return NewResourceNotFound("character 1 not found")
+		}, c)
+	})
+
+	w := utils.PerformRequest(r, http.MethodGet, "/fail", nil)
+
+	actual := APIError{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&actual))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "character 1 not found", actual.Message)
+	assert.Equal(t, CodeNotFound, actual.Err)
+}
+
+func TestErrorWrapperProblemDetails(t *testing.T) {
+	t.Log("ErrorWrapper should render problem details when the client accepts them")
+
+	r := testRouter()
+	r.Use(middleware.RequestID)
+	r.GET("/fail", func(c *gin.Context) {
+		ErrorWrapper(func(c *gin.Context) error {
+			return NewResourceNotFound("character 1 not found")
+		}, c)
+	})
+
+	w := utils.PerformRequest(r, http.MethodGet, "/fail", map[string]string{"Accept": ProblemContentType})
+
+	actual := ProblemDetails{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&actual))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "/problems/not_found", actual.Type)
+	assert.Equal(t, "Resource Not Found", actual.Title)
+	assert.Equal(t, "character 1 not found", actual.Detail)
+	assert.Equal(t, CodeNotFound, actual.Code)
+	assert.NotEmpty(t, actual.Instance)
+	assert.NotEqual(t, "undefined-request_id", actual.Instance)
+}
+
+func TestErrorWrapperHidesInternalErrorsInProd(t *testing.T) {
+	t.Log("ErrorWrapper should not leak internal error details in prod")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{"environment": "prod"}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	r := testRouter()
+	r.GET("/fail", func(c *gin.Context) {
+		ErrorWrapper(func(c *gin.Context) error {
+			return NewInternalServerError("Error 1146: Table 'memequotes.characters' doesn't exist")
+		}, c)
+	})
+
+	w := utils.PerformRequest(r, http.MethodGet, "/fail", nil)
+
+	actual := APIError{}
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&actual))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, InternalServerErrorMessage, actual.Message)
+}
+
+func TestNewValidationErrorListsFields(t *testing.T) {
+	t.Log("NewValidationError should list every invalid field")
+
+	body := struct {
+		CharacterId int64  `json:"character_id" binding:"required"`
+		Content     string `json:"content" binding:"required"`
+	}{}
+	err := binding.Validator.ValidateStruct(&body)
+
+	apiErr := NewValidationError(err)
+
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+	assert.Equal(t, CodeValidationFailed, apiErr.Err)
+	assert.Equal(t, []FieldError{
+		{Field: "character_id", Rule: "required", Message: "character_id is required"},
+		{Field: "content", Rule: "required", Message: "content is required"},
+	}, apiErr.Errors)
+}
+
+func TestNewValidationErrorUsesJSONNames(t *testing.T) {
+	t.Log("NewValidationError should name the fields by their json tag, not by the struct field")
+
+	body := struct {
+		SourceId  int64  `json:"source" binding:"required"`
+		Timestamp string `json:"at,omitempty" binding:"required"`
+	}{}
+	err := binding.Validator.ValidateStruct(&body)
+
+	apiErr := NewValidationError(err)
+
+	assert.Equal(t, []FieldError{
+		{Field: "source", Rule: "required", Message: "source is required"},
+		{Field: "at", Rule: "required", Message: "at is required"},
+	}, apiErr.Errors)
+}
+
+func TestNewValidationErrorMalformedBody(t *testing.T) {
+	t.Log("NewValidationError should return a bad request for a malformed body")
+
+	apiErr := NewValidationError(errors.New("unexpected EOF"))
+
+	assert.Equal(t, http.StatusBadRequest, apiErr.Status)
+	assert.Equal(t, CodeBadRequest, apiErr.Err)
+	assert.Empty(t, apiErr.Errors)
+}
+
+func TestNewConflict(t *testing.T) {
+	t.Log("NewConflict should return a new conflict error")
+
+	err := NewConflict("some error")
+
+	assert.Equal(t, http.StatusConflict, err.Status)
+	assert.Equal(t, "some error", err.Message)
+	assert.Equal(t, "conflict", err.Err)
+}
+
+func TestFromErrorMapsDomainErrors(t *testing.T) {
+	t.Log("FromError should map each typed error to its status")
+
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{customErrors.NewNotFoundError("not found"), http.StatusNotFound, CodeNotFound},
+		{customErrors.NewConflictError("conflict"), http.StatusConflict, CodeConflict},
+		{customErrors.NewDuplicateError("duplicate", 12), http.StatusConflict, CodeConflict},
+		{customErrors.NewUnauthorizedError("unauthorized"), http.StatusUnauthorized, CodeUnauthorized},
+		{customErrors.NewForbiddenError("forbidden"), http.StatusForbidden, CodeForbidden},
+		{customErrors.NewValidationError("name", "too long"), http.StatusBadRequest, CodeValidationFailed},
+		{customErrors.NewUnavailableError("db down", errors.New("bad connection")), http.StatusServiceUnavailable, CodeUnavailable},
+		{fmt.Errorf("saving: %w", customErrors.NewConflictError("conflict")), http.StatusConflict, CodeConflict},
+		{NewBadRequest("bad"), http.StatusBadRequest, CodeBadRequest},
+		{errors.New("DB error"), http.StatusInternalServerError, CodeInternal},
+	}
+
+	for _, tc := range cases {
+		apiErr := FromError(tc.err)
+		assert.Equal(t, tc.status, apiErr.Status, tc.err.Error())
+		assert.Equal(t, tc.code, apiErr.Err, tc.err.Error())
+	}
+}
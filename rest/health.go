@@ -11,7 +11,7 @@ func Health(c *gin.Context) {
 	ErrorWrapper(health, c)
 }
 
-func health(c *gin.Context) *APIError {
+func health(c *gin.Context) error {
 	ctx := commonContext.RequestContext(c)
 	logger := commonContext.Logger(ctx)
 	logger.Trace("health-check")
@@ -0,0 +1,210 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader is the header clients send to make a POST safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a previous request with the same key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength  = 255
+	idempotencySweepInterval = time.Minute
+)
+
+// replayedHeaders are the response headers stored with the response, the rest are set again on every request
+var replayedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Location"}
+
+// idempotentResponse is the response to the first request with a key. It's in progress until done is set
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyStore keeps the responses by client and key. Keys are scoped to the client, so a client
+// can never get the response to someone else's request
+type idempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]*idempotentResponse
+	lastSweep time.Time
+}
+
+var idempotentResponses = newIdempotencyStore()
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{responses: make(map[string]*idempotentResponse)}
+}
+
+// begin returns a copy of the stored response for the key, or reserves the key for a new request when there is
+// none. reserved is false when the store is full, and the request should go on without being stored
+func (s *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte, settings config.Idempotency, now time.Time) (stored *idempotentResponse, reserved bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	if response, ok := s.responses[key]; ok && (!response.done || now.Before(response.expires)) {
+		stored := *response
+		return &stored, false
+	}
+	if len(s.responses) >= settings.MaxKeys {
+		return nil, false
+	}
+	s.responses[key] = &idempotentResponse{fingerprint: fingerprint}
+	return nil, true
+}
+
+// finish stores the response of a reserved key. Server errors are not stored, so the client can retry them
+func (s *idempotencyStore) finish(key string, status int, header http.Header, body []byte, settings config.Idempotency, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response, ok := s.responses[key]
+	if !ok {
+		return
+	}
+	if status >= http.StatusInternalServerError {
+		delete(s.responses, key)
+		return
+	}
+	response.done = true
+	response.status = status
+	response.header = header
+	response.body = body
+	response.expires = now.Add(settings.TTL)
+}
+
+// release frees a key whose request ended without a response, like after a panic
+func (s *idempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if response, ok := s.responses[key]; ok && !response.done {
+		delete(s.responses, key)
+	}
+}
+
+// sweep removes the expired responses
+func (s *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	for key, response := range s.responses {
+		if response.done && !now.Before(response.expires) {
+			delete(s.responses, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// recordingWriter keeps a copy of the body written to the client
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent makes a POST safe to retry when the client sends an Idempotency-Key. The first response for a
+// key is stored for idempotency.ttl, and requests with the same key and body get it back verbatim instead
+// of being processed again. The same key with a different request answers 422, and a duplicate that arrives
+// while the first request is still in progress answers 409. Requests without the header are not affected
+func Idempotent(c *gin.Context) {
+	idempotencyKey := c.GetHeader(IdempotencyKeyHeader)
+	if idempotencyKey == "" {
+		c.Next()
+		return
+	}
+
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		writeError(c, NewBadRequest(fmt.Sprintf("the %s header must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)))
+		c.Abort()
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		writeError(c, NewBadRequest("the request body could not be read"))
+		c.Abort()
+		return
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	settings := config.Current().Idempotency
+	key := commonContext.ClientID(ctx) + " " + idempotencyKey
+	fingerprint := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+
+	stored, reserved := idempotentResponses.begin(key, fingerprint, settings, time.Now())
+	switch {
+	case stored != nil && stored.fingerprint != fingerprint:
+		writeError(c, NewIdempotencyKeyReused())
+		c.Abort()
+		return
+	case stored != nil && !stored.done:
+		c.Header("Retry-After", "1")
+		writeError(c, NewConflict(fmt.Sprintf("a request with %s %s is still in progress", IdempotencyKeyHeader, idempotencyKey)))
+		c.Abort()
+		return
+	case stored != nil:
+		logger.Debug(fmt.Sprintf("Replaying the response for %s %s", IdempotencyKeyHeader, idempotencyKey))
+		replay(c, stored)
+		c.Abort()
+		return
+	case !reserved:
+		logger.Warn(fmt.Sprintf("Too many idempotency keys, processing %s %s without storing its response", IdempotencyKeyHeader, idempotencyKey))
+		c.Next()
+		return
+	}
+
+	defer idempotentResponses.release(key)
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+
+	header := make(http.Header)
+	for _, name := range replayedHeaders {
+		if value := writer.Header().Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	idempotentResponses.finish(key, writer.Status(), header, writer.body.Bytes(), settings, time.Now())
+}
+
+// replay writes a stored response
+func replay(c *gin.Context, stored *idempotentResponse) {
+	for name, values := range stored.header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Writer.WriteHeader(stored.status)
+	c.Writer.Write(stored.body)
+}
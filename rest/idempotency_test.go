@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func idempotentRouter(calls *int, status int) *gin.Engine {
+	r := testRouter()
+	r.POST("/character", Idempotent, func(c *gin.Context) {
+		*calls++
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.Header("Location", "/character/1")
+		c.Data(status, "application/json; charset=utf-8", body)
+	})
+	return r
+}
+
+func postIdempotent(r http.Handler, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/character", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotentReplaysFirstResponse(t *testing.T) {
+	t.Log("A request with a known Idempotency-Key should get the first response without being processed again")
+
+	idempotentResponses = newIdempotencyStore()
+	calls := 0
+	r := idempotentRouter(&calls, http.StatusCreated)
+
+	first := postIdempotent(r, "abc", `{"name":"Fort"}`)
+	second := postIdempotent(r, "abc", `{"name":"Fort"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "/character/1", second.Header().Get("Location"))
+	assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotentKeyWithDifferentBody(t *testing.T) {
+	t.Log("Reusing an Idempotency-Key with a different body should return 422")
+
+	idempotentResponses = newIdempotencyStore()
+	calls := 0
+	r := idempotentRouter(&calls, http.StatusCreated)
+
+	postIdempotent(r, "abc", `{"name":"Fort"}`)
+	w := postIdempotent(r, "abc", `{"name":"Ricardo"}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), CodeIdempotencyKeyReused)
+}
+
+func TestIdempotentDoesNotStoreServerErrors(t *testing.T) {
+	t.Log("A request that failed with a server error should be processed again on retry")
+
+	idempotentResponses = newIdempotencyStore()
+	calls := 0
+	r := idempotentRouter(&calls, http.StatusServiceUnavailable)
+
+	postIdempotent(r, "abc", `{"name":"Fort"}`)
+	postIdempotent(r, "abc", `{"name":"Fort"}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotentWithoutKey(t *testing.T) {
+	t.Log("Requests without an Idempotency-Key should always be processed")
+
+	idempotentResponses = newIdempotencyStore()
+	calls := 0
+	r := idempotentRouter(&calls, http.StatusCreated)
+
+	postIdempotent(r, "", `{"name":"Fort"}`)
+	postIdempotent(r, "", `{"name":"Fort"}`)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyStoreConcurrentDuplicate(t *testing.T) {
+	t.Log("A duplicate arriving while the first request is in progress should not reserve the key again")
+
+	s := newIdempotencyStore()
+	settings := config.Idempotency{TTL: time.Hour, MaxKeys: 10}
+	fingerprint := sha256.Sum256([]byte("body"))
+	now := time.Now()
+
+	stored, reserved := s.begin("client abc", fingerprint, settings, now)
+	assert.Nil(t, stored)
+	assert.True(t, reserved)
+
+	stored, reserved = s.begin("client abc", fingerprint, settings, now)
+	assert.False(t, reserved)
+	assert.False(t, stored.done)
+
+	s.finish("client abc", http.StatusCreated, http.Header{}, []byte("{}"), settings, now)
+	stored, _ = s.begin("client abc", fingerprint, settings, now)
+	assert.True(t, stored.done)
+
+	_, reserved = s.begin("client abc", fingerprint, settings, now.Add(2*time.Hour))
+	assert.True(t, reserved)
+}
+
+func TestIdempotentConcurrentDuplicateAnswersConflict(t *testing.T) {
+	t.Log("A duplicate arriving while the first request is in progress should return 409")
+
+	idempotentResponses = newIdempotencyStore()
+	started, release := make(chan struct{}), make(chan struct{})
+	r := testRouter()
+	r.POST("/character", Idempotent, func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusCreated)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postIdempotent(r, "abc", "{}") }()
+	<-started
+	w := postIdempotent(r, "abc", "{}")
+	close(release)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+}
@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/gin-gonic/gin"
+)
+
+// VersionTag is the ETag of a version of a resource
+func VersionTag(version int64) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// IfMatch reads the versions a write expects from the If-Match header. Without the header the write is
+// unconditional, unless concurrency.require_if_match is set. Weak tags never match, as RFC 7232 requires
+func IfMatch(c *gin.Context) (model.Precondition, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if config.Current().Concurrency.RequireIfMatch {
+			return model.Precondition{}, NewPreconditionRequired()
+		}
+		return model.Precondition{}, nil
+	}
+	if header == "*" {
+		return model.Precondition{}, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		if version, ok := parseVersionTag(strings.TrimSpace(tag)); ok {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return model.Precondition{}, NewPreconditionFailed()
+	}
+	return model.NewPrecondition(versions...), nil
+}
+
+func parseVersionTag(tag string) (int64, bool) {
+	if !strings.HasPrefix(tag, `"v`) || !strings.HasSuffix(tag, `"`) || len(tag) < 4 {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[2:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,60 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func ifMatch(header string) (model.Precondition, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPatch, "/character/1", nil)
+	if header != "" {
+		c.Request.Header.Set("If-Match", header)
+	}
+	return IfMatch(c)
+}
+
+func TestIfMatchVersions(t *testing.T) {
+	t.Log("If-Match should be read as the versions the write expects")
+
+	precondition, err := ifMatch(`"v3", "v5"`)
+	assert.NoError(t, err)
+	assert.Equal(t, model.NewPrecondition(3, 5), precondition)
+
+	precondition, err = ifMatch("")
+	assert.NoError(t, err)
+	assert.True(t, precondition.Unconditional())
+
+	precondition, err = ifMatch("*")
+	assert.NoError(t, err)
+	assert.True(t, precondition.Unconditional())
+}
+
+func TestIfMatchWithoutVersions(t *testing.T) {
+	t.Log("If-Match without any version tag should fail, weak tags never match")
+
+	_, err := ifMatch(`W/"v3"`)
+	assert.Equal(t, http.StatusPreconditionFailed, FromError(err).Status)
+
+	_, err = ifMatch(`"3f786850e387550fdab836ed7e6dc881de23001b"`)
+	assert.Equal(t, http.StatusPreconditionFailed, FromError(err).Status)
+}
+
+func TestIfMatchRequired(t *testing.T) {
+	t.Log("A write without If-Match should fail when it is required")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{"concurrency.require_if_match": "true"}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	_, err = ifMatch("")
+	apiErr := FromError(err)
+	assert.Equal(t, http.StatusPreconditionRequired, apiErr.Status)
+	assert.Equal(t, CodePreconditionRequired, apiErr.Err)
+}
@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type for RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails is the RFC 7807 representation of an APIError
+type ProblemDetails struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblemDetails creates the problem representation of an APIError. The instance is the request id.
+func NewProblemDetails(apiErr *APIError, instance string) ProblemDetails {
+	return ProblemDetails{
+		Type:     problemType(apiErr.Err),
+		Title:    codeTitle(apiErr.Err, apiErr.Status),
+		Status:   apiErr.Status,
+		Detail:   apiErr.Message,
+		Instance: instance,
+		Code:     apiErr.Err,
+		Errors:   apiErr.Errors,
+	}
+}
+
+// writeError renders an APIError, either in the legacy format or as problem details.
+// Internal error details are logged and, in production, hidden from the client.
+func writeError(c *gin.Context, apiErr *APIError) {
+	ctx := commonContext.RequestContext(c)
+
+	if apiErr.Status >= 500 && hideInternalErrors() {
+		commonContext.Logger(ctx).Error("internal error hidden from client", errors.New(apiErr.Message))
+		hidden := *apiErr
+		hidden.Message = InternalServerErrorMessage
+		apiErr = &hidden
+	}
+
+	if !wantsProblemDetails(c) {
+		c.JSON(apiErr.Status, apiErr)
+		return
+	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(apiErr.Status, NewProblemDetails(apiErr, commonContext.RequestID(ctx)))
+}
+
+// wantsProblemDetails checks whether the client asked for problem details or the service defaults to them
+func wantsProblemDetails(c *gin.Context) bool {
+	if strings.Contains(c.GetHeader("Accept"), ProblemContentType) {
+		return true
+	}
+	return config.Current().Errors.Format == "problem"
+}
+
+func hideInternalErrors() bool {
+	return config.Current().Environment == "prod"
+}
+
+func problemType(code string) string {
+	return config.Current().Errors.ProblemTypeBase + code
+}
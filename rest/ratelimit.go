@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/gin-gonic/gin"
+)
+
+// idleBucketTTL is how long an unused bucket is kept before it's swept
+const idleBucketTTL = 10 * time.Minute
+
+// bucket is a token bucket for a single client
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per client. The rate and burst are passed on every call, so a
+// configuration reload applies to the existing buckets too
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+var limiter = newRateLimiter()
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*bucket)}
+}
+
+// allow takes a token from the client's bucket, returning false when it's empty
+func (l *rateLimiter) allow(client string, limits config.RateLimit, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(limits.Burst), lastSeen: now}
+		l.buckets[client] = b
+	}
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(limits.Burst), b.tokens+elapsed*limits.RequestsPerSecond)
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep removes buckets of clients that haven't been seen for a while
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < idleBucketTTL {
+		return
+	}
+	for client, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleBucketTTL {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastSweep = now
+}
+
+// RateLimit answers 429 Too Many Requests to clients going over the configured rate
+func RateLimit(c *gin.Context) {
+	limits := config.Current().RateLimit
+	if !limits.Enabled {
+		c.Next()
+		return
+	}
+
+	if !limiter.allow(c.ClientIP(), limits, time.Now()) {
+		c.Header("Retry-After", "1")
+		ErrorWrapper(func(c *gin.Context) error {
+			return NewTooManyRequests()
+		}, c)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// FeatureGate hides the routes behind a feature flag, answering 404 while the feature is off
+func FeatureGate(feature string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Current().Features.Enabled(feature) {
+			NoRouteHandler(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterRefillsTokens(t *testing.T) {
+	t.Log("A client should be limited after its burst and allowed again once tokens refill")
+
+	l := newRateLimiter()
+	limits := config.RateLimit{Enabled: true, RequestsPerSecond: 1, Burst: 2}
+	now := time.Now()
+
+	assert.True(t, l.allow("1.1.1.1", limits, now))
+	assert.True(t, l.allow("1.1.1.1", limits, now))
+	assert.False(t, l.allow("1.1.1.1", limits, now))
+	assert.True(t, l.allow("2.2.2.2", limits, now))
+	assert.True(t, l.allow("1.1.1.1", limits, now.Add(time.Second)))
+}
+
+func TestRateLimitAnswersTooManyRequests(t *testing.T) {
+	t.Log("Going over the rate limit should return 429")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{
+		"rate_limit.enabled":             "true",
+		"rate_limit.requests_per_second": "0.001",
+		"rate_limit.burst":               "1",
+	}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+	limiter = newRateLimiter()
+
+	r := testRouter()
+	r.Use(RateLimit)
+	r.GET("/health", Health)
+
+	assert.Equal(t, http.StatusOK, utils.PerformRequest(r, http.MethodGet, "/health", nil).Code)
+	w := utils.PerformRequest(r, http.MethodGet, "/health", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+func TestFeatureGate(t *testing.T) {
+	t.Log("Routes behind a disabled feature should not be found")
+
+	r := testRouter()
+	r.GET("/health", FeatureGate("health"), Health)
+
+	assert.Equal(t, http.StatusNotFound, utils.PerformRequest(r, http.MethodGet, "/health", nil).Code)
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{"features.health": "true"}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	assert.Equal(t, http.StatusOK, utils.PerformRequest(r, http.MethodGet, "/health", nil).Code)
+}
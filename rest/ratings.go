@@ -0,0 +1,30 @@
+package rest
+
+import (
+	"fmt"
+
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxRating reads the strongest content rating a list of phrases may show from ?max_rating=. It's the ceiling
+// of the client's API key when the request doesn't say, and a request can't go above it. Anonymous requests
+// have the ceiling in auth.default_max_rating. The response varies with the API key, so shared caches are told
+func MaxRating(c *gin.Context) (string, error) {
+	c.Writer.Header().Add("Vary", APIKeyHeader)
+
+	ceiling := commonContext.MaxRating(commonContext.RequestContext(c))
+	if ceiling == "" {
+		ceiling = config.Current().Auth.DefaultMaxRating
+	}
+	requested := c.Query("max_rating")
+	if requested == "" {
+		return ceiling, nil
+	}
+	if !model.ValidRating(requested) {
+		return "", NewBadRequest(fmt.Sprintf("max_rating must be one of %v", model.Ratings))
+	}
+	return model.WeakerRating(requested, ceiling), nil
+}
@@ -0,0 +1,64 @@
+package rest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxRating(t *testing.T) {
+	t.Log("The rating ceiling should come from the API key, and a request should only lower it")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{
+		"auth.api_keys.kids.key":        "k1d5",
+		"auth.api_keys.kids.max_rating": "safe",
+		"auth.api_keys.app.key":         "4pp",
+		"auth.api_keys.app.max_rating":  "explicit",
+	}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	r := testRouter()
+	r.Use(Authenticate)
+	r.GET("/phrases", func(c *gin.Context) {
+		ErrorWrapper(func(c *gin.Context) error {
+			rating, err := MaxRating(c)
+			if err != nil {
+				return err
+			}
+			c.String(http.StatusOK, rating)
+			return nil
+		}, c)
+	})
+
+	for _, request := range []struct {
+		path     string
+		key      string
+		expected string
+	}{
+		{"/phrases", "", "mature"},
+		{"/phrases", "k1d5", "safe"},
+		{"/phrases?max_rating=explicit", "k1d5", "safe"},
+		{"/phrases", "4pp", "explicit"},
+		{"/phrases?max_rating=safe", "4pp", "safe"},
+	} {
+		w := utils.PerformRequest(r, http.MethodGet, request.path, map[string]string{APIKeyHeader: request.key})
+		assert.Equal(t, http.StatusOK, w.Code, request.path)
+		assert.Equal(t, request.expected, w.Body.String(), request.path+" "+request.key)
+		assert.Equal(t, APIKeyHeader, w.Header().Get("Vary"))
+	}
+
+	w := utils.PerformRequest(r, http.MethodGet, "/phrases?max_rating=pg13", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRatingsMatchConfig(t *testing.T) {
+	t.Log("The config should take the ratings an API key may have as its ceiling")
+
+	assert.Equal(t, model.Ratings, config.ContentRatings)
+}
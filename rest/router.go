@@ -7,8 +7,7 @@ import (
 )
 
 func CreateRouter() *gin.Engine {
-	c := config.Conf
-	env := c.GetString("environment", "dev")
+	env := config.Current().Environment
 	if env == "prod" || env == "beta" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -18,7 +17,11 @@ func CreateRouter() *gin.Engine {
 
 	router.Use(middleware.Hostname)
 	router.Use(middleware.RequestID)
+	router.Use(middleware.ClientID)
 	router.Use(middleware.Logger)
+	router.Use(middleware.CORS)
+	router.Use(Authenticate)
+	router.Use(RateLimit)
 
 	router.NoMethod(MethodNotAllowedHandler)
 	router.NoRoute(NoRouteHandler)
@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation errors name the fields as clients send them, by their json tag
+func init() {
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// FieldError describes why a single field of the request body is not valid
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// NewValidationError creates an API Error from the error returned by binding the request body.
+// Field level validation errors are listed one by one, any other error is reported as a malformed body.
+func NewValidationError(err error) *APIError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return NewBadRequest(err.Error())
+	}
+
+	apiErr := newAPIError(http.StatusBadRequest, ValidationFailedMessage, CodeValidationFailed)
+	apiErr.Errors = make([]FieldError, len(validationErrs))
+	for i, fieldErr := range validationErrs {
+		field := fieldErr.Field()
+		apiErr.Errors[i] = FieldError{
+			Field:   field,
+			Rule:    fieldErr.Tag(),
+			Message: fieldMessage(field, fieldErr),
+		}
+	}
+	return apiErr
+}
+
+// newValidationAPIError creates an API Error from a domain validation error
+func newValidationAPIError(err customErrors.ValidationError) *APIError {
+	apiErr := newAPIError(http.StatusBadRequest, err.Message, CodeValidationFailed)
+	if err.Field != "" {
+		apiErr.Errors = []FieldError{{Field: err.Field, Rule: "invalid", Message: err.Message}}
+	}
+	return apiErr
+}
+
+func fieldMessage(field string, fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s long", field, fieldErr.Param())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s long", field, fieldErr.Param())
+	default:
+		return fmt.Sprintf("%s does not satisfy %s", field, fieldErr.Tag())
+	}
+}
+
+// jsonFieldName is the name of a struct field in JSON bodies. Fields without a json tag keep their own name
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
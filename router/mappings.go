@@ -1,20 +1,102 @@
 package router
 
 import (
+	"github.com/airabinovich/memequotes_back/auth"
+	"github.com/airabinovich/memequotes_back/cache"
 	"github.com/airabinovich/memequotes_back/character"
+	"github.com/airabinovich/memequotes_back/collection"
+	"github.com/airabinovich/memequotes_back/dialogue"
+	"github.com/airabinovich/memequotes_back/filter"
 	"github.com/airabinovich/memequotes_back/phrase"
+	"github.com/airabinovich/memequotes_back/report"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/airabinovich/memequotes_back/source"
+	"github.com/airabinovich/memequotes_back/trending"
 	"github.com/gin-gonic/gin"
 )
 
 func mappings(router *gin.Engine) {
-	router.POST("character", character.SaveCharacter)
-	router.GET("characters", character.GetAllCharacters)
-	router.GET("character/:character-id", character.GetCharacter)
-	router.PATCH("character/:character-id", character.UpdateCharacter)
-	router.DELETE("character/:character-id", character.DeleteCharacter)
-
-	router.POST("character/:character-id/phrase", phrase.SaveNewPhrase)
-	router.GET("character/:character-id/phrases", phrase.GetAllPhrasesForCharacter)
-	router.GET("character/:character-id/phrase/:phrase-id", phrase.GetPhrase)
-	router.DELETE("character/:character-id/phrase/:phrase-id", phrase.DeletePhraseForCharacter)
+	router.GET("errors", rest.ErrorCatalog)
+
+	admin := router.Group("admin", rest.RequireRole(auth.RoleAdmin))
+	admin.GET("cache", cache.GetStats)
+	admin.DELETE("cache", cache.Flush)
+	admin.GET("duplicates", phrase.GetDuplicates)
+	admin.GET("banned-terms", filter.GetBannedTerms)
+	admin.POST("banned-term", filter.SaveBannedTerm)
+	admin.DELETE("banned-term/:term-id", filter.DeleteBannedTerm)
+
+	moderation := router.Group("moderation", rest.RequireRole(auth.RoleModerator))
+	moderation.GET("phrases", phrase.GetModerationQueue)
+	moderation.POST("phrases/approve", rest.Idempotent, phrase.ApprovePhrases)
+	moderation.POST("phrases/reject", rest.Idempotent, phrase.RejectPhrases)
+	moderation.GET("reports", report.GetReports)
+	moderation.POST("reports/resolve", rest.Idempotent, report.ResolveReports)
+	moderation.POST("reports/dismiss", rest.Idempotent, report.DismissReports)
+
+	router.POST("character", rest.Idempotent, character.SaveCharacter)
+	router.GET("characters", rest.CacheControl("characters"), character.GetAllCharacters)
+	router.GET("characters/by-name/:name", rest.CacheControl("character"), character.GetCharacterByName)
+
+	// Characters and phrases are found by id or by slug
+	byCharacter := router.Group("character/:character-id", character.ResolveCharacter)
+	byCharacter.GET("", rest.CacheControl("character"), character.GetCharacter)
+	byCharacter.PATCH("", character.UpdateCharacter)
+	byCharacter.DELETE("", character.DeleteCharacter)
+	byCharacter.POST("merge", rest.RequireRole(auth.RoleModerator), rest.Idempotent, character.MergeCharacter)
+
+	byCharacter.GET("aliases", character.GetAliases)
+	byCharacter.POST("alias", character.AddAlias)
+	byCharacter.DELETE("alias/:alias-id", character.DeleteAlias)
+
+	byCharacter.POST("phrase", rest.Idempotent, phrase.SaveNewPhrase)
+	byCharacter.GET("phrases", rest.CacheControl("phrases"), phrase.GetAllPhrasesForCharacter)
+	byCharacter.GET("phrase/:phrase-id", phrase.ResolvePhrase, rest.CacheControl("phrase"), phrase.GetPhrase)
+	byCharacter.DELETE("phrase/:phrase-id", phrase.ResolvePhrase, phrase.DeletePhraseForCharacter)
+	byCharacter.PUT("phrase/:phrase-id/source", phrase.ResolvePhrase, phrase.SetPhraseSource)
+	byCharacter.DELETE("phrase/:phrase-id/source", phrase.ResolvePhrase, phrase.DeletePhraseSource)
+	byCharacter.PUT("phrase/:phrase-id/rating", rest.RequireRole(auth.RoleModerator), phrase.ResolvePhrase, phrase.SetPhraseRating)
+	byCharacter.PUT("phrase/:phrase-id/vote", rest.RequireRole(auth.RoleUser), phrase.ResolvePhrase, phrase.VotePhrase)
+	byCharacter.DELETE("phrase/:phrase-id/vote", rest.RequireRole(auth.RoleUser), phrase.ResolvePhrase, phrase.UnvotePhrase)
+	byCharacter.POST("phrase/:phrase-id/share", rest.FeatureGate("trending"), phrase.ResolvePhrase, trending.SharePhrase)
+	byCharacter.POST("phrase/:phrase-id/report", rest.RequireRole(auth.RoleUser), phrase.ResolvePhrase, report.ReportPhrase)
+	byCharacter.POST("report", rest.RequireRole(auth.RoleUser), report.ReportCharacter)
+	byCharacter.GET("sources", rest.CacheControl("sources"), source.GetSourcesForCharacter)
+	byCharacter.GET("dialogues", rest.FeatureGate("dialogues"), rest.CacheControl("dialogues"), dialogue.GetDialoguesForCharacter)
+
+	// Phrases are reported by their id alone too
+	router.POST("phrase/:phrase-id/report", rest.RequireRole(auth.RoleUser), phrase.ResolvePhraseCharacter,
+		character.ResolveCharacter, report.ReportPhrase)
+
+	router.GET("phrases/top", rest.CacheControl("top"), phrase.GetTopPhrases)
+	router.GET("phrases/submissions", rest.RequireRole(auth.RoleUser), phrase.GetMySubmissions)
+	router.GET("phrases/trending", rest.FeatureGate("trending"), rest.CacheControl("trending"), trending.GetTrendingPhrases)
+
+	router.POST("source", rest.Idempotent, source.SaveSource)
+	router.GET("sources", rest.CacheControl("sources"), source.GetAllSources)
+	router.GET("source/:source-id", rest.CacheControl("source"), source.GetSource)
+	router.GET("source/:source-id/phrases", rest.CacheControl("phrases"), source.GetPhrasesForSource)
+
+	// Dialogues, collections and trending phrases are behind feature flags, so they can be turned off without a restart
+	dialogues := router.Group("", rest.FeatureGate("dialogues"))
+	dialogues.POST("dialogue", rest.Idempotent, dialogue.SaveDialogue)
+	dialogues.GET("dialogues", rest.CacheControl("dialogues"), dialogue.GetAllDialogues)
+	dialogues.GET("dialogue/:dialogue-id", rest.CacheControl("dialogue"), dialogue.GetDialogue)
+	dialogues.PATCH("dialogue/:dialogue-id", dialogue.UpdateDialogue)
+	dialogues.DELETE("dialogue/:dialogue-id", dialogue.DeleteDialogue)
+
+	// Collections belong to the API key that creates them. Private ones are not cached by shared caches, they
+	// get the default Cache-Control
+	collections := router.Group("", rest.FeatureGate("collections"))
+	collections.POST("collection", rest.RequireRole(auth.RoleUser), rest.Idempotent, collection.SaveCollection)
+	collections.GET("collections", rest.RequireRole(auth.RoleUser), collection.GetMyCollections)
+	collections.GET("collection/:collection-id", collection.GetCollection)
+	collections.PATCH("collection/:collection-id", rest.RequireRole(auth.RoleUser), collection.UpdateCollection)
+	collections.DELETE("collection/:collection-id", rest.RequireRole(auth.RoleUser), collection.DeleteCollection)
+	collections.GET("collection/:collection-id/phrases", collection.GetCollectionPhrases)
+	collections.POST("collection/:collection-id/phrases", rest.RequireRole(auth.RoleUser), collection.AddCollectionPhrase)
+	collections.PUT("collection/:collection-id/order", rest.RequireRole(auth.RoleUser), collection.ReorderCollection)
+	collections.DELETE("collection/:collection-id/phrase/:phrase-id", rest.RequireRole(auth.RoleUser), collection.RemoveCollectionPhrase)
+	collections.GET("shared/collection/:share-token", collection.GetSharedCollection)
+	collections.GET("shared/collection/:share-token/phrases", collection.GetSharedCollectionPhrases)
 }
@@ -0,0 +1,68 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCharacterByNameRoute(t *testing.T) {
+	t.Log("Lookups by name should have their own route, and other paths should not be found")
+
+	r := Route()
+
+	var handler string
+	for _, route := range r.Routes() {
+		if route.Method == http.MethodGet && route.Path == "/characters/by-name/:name" {
+			handler = route.Handler
+		}
+	}
+	assert.True(t, strings.HasSuffix(handler, "character.GetCharacterByName"), handler)
+
+	assert.Equal(t, http.StatusNotFound, utils.PerformRequest(r, http.MethodGet, "/unknown", nil).Code)
+}
+
+func TestCharacterByNameAlias(t *testing.T) {
+	t.Log("GET /character/by-name/:name should be served by the route of lookups by name")
+
+	var served string
+	r := gin.New()
+	r.GET("/characters/by-name/:name", func(c *gin.Context) {
+		served = c.Param("name")
+	})
+	r.GET("/character/:character-id", func(c *gin.Context) {})
+
+	w := utils.PerformRequest(Handler(r), http.MethodGet, "/character/by-name/comandante%20fort", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "comandante fort", served)
+}
+
+func TestFeatureFlagsGateRoutes(t *testing.T) {
+	t.Log("Dialogues, collections and trending phrases should be on by default, and not found once turned off")
+
+	_, err := config.Load(config.Sources{Overrides: map[string]string{"features.some_feature": "true"}})
+	assert.NoError(t, err)
+	for _, feature := range []string{"dialogues", "collections", "trending"} {
+		assert.True(t, config.Current().Features.Enabled(feature), feature)
+	}
+
+	_, err = config.Load(config.Sources{Overrides: map[string]string{
+		"features.dialogues":   "false",
+		"features.collections": "false",
+		"features.trending":    "false",
+	}})
+	assert.NoError(t, err)
+	defer config.Load(config.Sources{})
+
+	r := Route()
+	for _, path := range []string{"/dialogues", "/dialogue/5", "/collection/1",
+		"/shared/collection/q3Xl0m2fR1mYJx2c7Pz9mA", "/phrases/trending"} {
+		assert.Equal(t, http.StatusNotFound, utils.PerformRequest(r, http.MethodGet, path, nil).Code, path)
+	}
+}
@@ -1,13 +1,38 @@
 package router
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/airabinovich/memequotes_back/rest"
 	"github.com/gin-gonic/gin"
 )
 
+// pathAliases are paths gin can't register next to the routes that have a parameter in the same segment, like
+// character/by-name next to character/:character-id. They are served by the route they are an alias of
+var pathAliases = map[string]string{
+	"/character/by-name/": "/characters/by-name/",
+}
+
 // Route creates a new router
 func Route() *gin.Engine {
 	router := rest.CreateRouter()
 	mappings(router)
 	return router
 }
+
+// Handler serves the routes of the router, and the path aliases through the routes they are an alias of
+func Handler(router *gin.Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for alias, path := range pathAliases {
+			if strings.HasPrefix(req.URL.Path, alias) {
+				req.URL.Path = path + strings.TrimPrefix(req.URL.Path, alias)
+				if req.URL.RawPath != "" {
+					req.URL.RawPath = path + strings.TrimPrefix(req.URL.RawPath, alias)
+				}
+				break
+			}
+		}
+		router.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	commonContext "github.com/airabinovich/memequotes_back/context"
+)
+
+// certificateReloader serves a TLS certificate and reloads it when the files change on disk,
+// so renewed certificates are picked up without a restart
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+func newCertificateReloader(certFile string, keyFile string, interval time.Duration) (*certificateReloader, error) {
+	reloader := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// GetCertificate is meant to be used as tls.Config.GetCertificate. Files are checked for changes
+// at most once per interval; a certificate that fails to load keeps the previous one in use.
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	certificate := r.certificate
+	due := time.Since(r.lastCheck) >= r.interval
+	r.mu.RUnlock()
+
+	if due && r.changed() {
+		if err := r.load(); err != nil {
+			logger := commonContext.Logger(commonContext.AppContext(context.Background()))
+			logger.Error("reloading TLS certificate, keeping the previous one", err)
+		} else {
+			r.mu.RLock()
+			certificate = r.certificate
+			r.mu.RUnlock()
+		}
+	}
+	return certificate, nil
+}
+
+// changed tells whether the certificate or key files were modified since they were loaded
+func (r *certificateReloader) changed() bool {
+	r.mu.Lock()
+	r.lastCheck = time.Now()
+	certModTime, keyModTime := r.certModTime, r.keyModTime
+	r.mu.Unlock()
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(certModTime) || !keyInfo.ModTime().Equal(keyModTime)
+}
+
+func (r *certificateReloader) load() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading key pair %s %s: %w", r.certFile, r.keyFile, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = &certificate
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	r.lastCheck = time.Now()
+
+	logger := commonContext.Logger(commonContext.AppContext(context.Background()))
+	logger.Info(fmt.Sprintf("Loaded TLS certificate %s", r.certFile))
+	return nil
+}
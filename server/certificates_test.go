@@ -0,0 +1,84 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateReloaderReloadsChangedFiles(t *testing.T) {
+	t.Log("The certificate should be reloaded when the files change on disk")
+
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeCertificate(t, certFile, keyFile, "first")
+	reloader, err := newCertificateReloader(certFile, keyFile, 0)
+	assert.NoError(t, err)
+
+	first, err := reloader.GetCertificate(nil)
+	assert.NoError(t, err)
+
+	writeCertificate(t, certFile, keyFile, "second")
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, later, later))
+
+	second, err := reloader.GetCertificate(nil)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first.Certificate[0], second.Certificate[0])
+}
+
+func TestCertificateReloaderKeepsCertificateOnBrokenFiles(t *testing.T) {
+	t.Log("A certificate that can't be loaded should keep the previous one in use")
+
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeCertificate(t, certFile, keyFile, "first")
+	reloader, err := newCertificateReloader(certFile, keyFile, 0)
+	assert.NoError(t, err)
+	first, _ := reloader.GetCertificate(nil)
+
+	assert.NoError(t, ioutil.WriteFile(certFile, []byte("not a certificate"), 0600))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, later, later))
+
+	current, err := reloader.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, first, current)
+}
+
+func writeCertificate(t *testing.T, certFile string, keyFile string, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+)
+
+// Run serves the handler on the configured TCP port and, when configured, on a Unix domain socket.
+// It blocks until one of the listeners fails, or until SIGINT or SIGTERM is received and the requests
+// in flight are answered, for up to server.shutdown_timeout. A shutdown returns nil
+func Run(handler http.Handler) error {
+	return RunWithConfig(handler, config.Current().Server)
+}
+
+// RunWithConfig serves the handler with an explicit server configuration. See Run
+func RunWithConfig(handler http.Handler, opts config.ServerConfig) error {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	return serve(handler, opts, quit)
+}
+
+// serve is RunWithConfig, shutting down when a signal is received on quit
+func serve(handler http.Handler, opts config.ServerConfig, quit <-chan os.Signal) error {
+	ctx := commonContext.AppContext(context.Background())
+	logger := commonContext.Logger(ctx)
+
+	srv := newHTTPServer(handler, opts)
+
+	// The certificate is loaded before listening, so a bad one leaves no listener open
+	if opts.TLS.Enabled() {
+		certificates, err := newCertificateReloader(opts.TLS.Cert, opts.TLS.Key, opts.TLS.ReloadInterval)
+		if err != nil {
+			logger.Error("loading TLS certificate", err)
+			return err
+		}
+		srv.TLSConfig.GetCertificate = certificates.GetCertificate
+	}
+
+	tcpListener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		logger.Error("listening on tcp port", err)
+		return err
+	}
+
+	var unixListener net.Listener
+	if opts.UnixSocket != "" {
+		if unixListener, err = listenUnix(opts.UnixSocket); err != nil {
+			logger.Error("listening on unix socket", err)
+			tcpListener.Close()
+			return err
+		}
+	}
+
+	errs := make(chan error, 2)
+	if unixListener != nil {
+		logger.Info(fmt.Sprintf("Listening on unix socket %s", opts.UnixSocket))
+		go func() {
+			errs <- srv.Serve(unixListener)
+		}()
+	}
+	if opts.TLS.Enabled() {
+		logger.Info(fmt.Sprintf("Listening with TLS on %s", srv.Addr))
+		go func() {
+			errs <- srv.ServeTLS(tcpListener, "", "")
+		}()
+	} else {
+		logger.Info(fmt.Sprintf("Listening on %s", srv.Addr))
+		go func() {
+			errs <- srv.Serve(tcpListener)
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-quit:
+		logger.Info(fmt.Sprintf("%v received, shutting down", sig))
+		ctx, cancel := context.WithTimeout(ctx, opts.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("shutting down", err)
+			return err
+		}
+		return nil
+	}
+}
+
+func newHTTPServer(handler http.Handler, opts config.ServerConfig) *http.Server {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", opts.Port),
+		Handler:           handler,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		MaxHeaderBytes:    opts.MaxHeaderBytes,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+	if !opts.HTTP2 {
+		// A non-nil empty map keeps net/http from enabling HTTP/2 on TLS connections
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	return srv
+}
+
+// listenUnix listens on a Unix domain socket, removing a stale socket file left by a previous run
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPServerDisablesHTTP2(t *testing.T) {
+	t.Log("HTTP/2 should not be negotiated when disabled")
+
+	opts := config.Current().Server
+	assert.Nil(t, newHTTPServer(nil, opts).TLSNextProto)
+
+	opts.HTTP2 = false
+	assert.NotNil(t, newHTTPServer(nil, opts).TLSNextProto)
+}
+
+func TestRunServesOnUnixSocket(t *testing.T) {
+	t.Log("The handler should be reachable through the unix socket")
+
+	dir, err := ioutil.TempDir("", "socket")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "memequotes.sock")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	opts := config.Current().Server
+	opts.Port = 0
+	opts.UnixSocket = socket
+	go RunWithConfig(handler, opts)
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("http://unix/"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+}
+
+func TestRunWithBrokenCertificateListensOnNothing(t *testing.T) {
+	t.Log("A certificate that can't be loaded should fail before any listener is opened")
+
+	dir, err := ioutil.TempDir("", "socket")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "memequotes.sock")
+
+	opts := config.Current().Server
+	opts.Port = 0
+	opts.UnixSocket = socket
+	opts.TLS.Cert = filepath.Join(dir, "missing.pem")
+	opts.TLS.Key = filepath.Join(dir, "missing.key")
+
+	assert.Error(t, RunWithConfig(http.NotFoundHandler(), opts))
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServeShutsDownOnSignal(t *testing.T) {
+	t.Log("A signal should close the listeners and let the requests in flight be answered")
+
+	dir, err := ioutil.TempDir("", "socket")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "memequotes.sock")
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusTeapot)
+	})
+	opts := config.Current().Server
+	opts.Port = 0
+	opts.UnixSocket = socket
+	opts.ShutdownTimeout = 5 * time.Second
+	quit := make(chan os.Signal, 1)
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- serve(handler, opts, quit)
+	}()
+
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	responses := make(chan int, 1)
+	go func() {
+		for i := 0; i < 50; i++ {
+			if resp, err := client.Get("http://unix/"); err == nil {
+				responses <- resp.StatusCode
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		responses <- 0
+	}()
+
+	<-started
+	quit <- os.Interrupt
+
+	assert.Equal(t, http.StatusTeapot, <-responses)
+	assert.NoError(t, <-stopped)
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+}
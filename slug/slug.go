@@ -0,0 +1,92 @@
+package slug
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxSuffix is how many numbered variants of a slug are tried before giving up
+const maxSuffix = 1000
+
+// transliterations maps the accented latin letters, and the symbols that have a Spanish reading, to the
+// ascii letters they are written with
+var transliterations = map[rune]string{
+	'á': "a", 'à': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a", 'ā': "a",
+	'é': "e", 'è': "e", 'ê': "e", 'ë': "e", 'ē': "e",
+	'í': "i", 'ì': "i", 'î': "i", 'ï': "i", 'ī': "i",
+	'ó': "o", 'ò': "o", 'ô': "o", 'ö': "o", 'õ': "o", 'ø': "o", 'ō': "o",
+	'ú': "u", 'ù': "u", 'û': "u", 'ü': "u", 'ū': "u",
+	'ý': "y", 'ÿ': "y",
+	'ñ': "n", 'ç': "c", 'ß': "ss", 'æ': "ae", 'œ': "oe",
+	'&': " y ",
+}
+
+// Transliterate replaces the accented letters of a lower case text with the letters they are written with
+func Transliterate(text string) string {
+	var transliterated strings.Builder
+	for _, r := range text {
+		if replacement, ok := transliterations[r]; ok {
+			transliterated.WriteString(replacement)
+		} else {
+			transliterated.WriteRune(r)
+		}
+	}
+	return transliterated.String()
+}
+
+// Make builds a URL-safe slug out of a text: its words in lower case ascii letters and digits, joined by dashes.
+// Slugs longer than maxLength are cut at the last whole word that fits
+func Make(text string, maxLength int) string {
+	words := strings.FieldsFunc(Transliterate(strings.ToLower(text)), func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+
+	slug := ""
+	for _, word := range words {
+		next := word
+		if slug != "" {
+			next = slug + "-" + word
+		}
+		if len(next) > maxLength {
+			if slug == "" {
+				slug = word[:maxLength]
+			}
+			break
+		}
+		slug = next
+	}
+	return slug
+}
+
+// Unique returns the first of slug, slug-2, slug-3... that is not taken. An empty slug is replaced by the
+// fallback, and slugs made only of digits are never used, they would be read as ids
+func Unique(slug string, fallback string, taken func(slug string) (bool, error)) (string, error) {
+	if slug == "" {
+		slug = fallback
+	}
+	for suffix := 1; suffix <= maxSuffix; suffix++ {
+		candidate := slug
+		if suffix > 1 {
+			candidate = fmt.Sprintf("%s-%d", slug, suffix)
+		}
+		if IsID(candidate) {
+			continue
+		}
+		isTaken, err := taken(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !isTaken {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free slug for %s", slug)
+}
+
+// IsID tells whether a path parameter is a numeric id rather than a slug
+func IsID(param string) bool {
+	_, err := strconv.ParseInt(param, 10, 64)
+	return err == nil
+}
@@ -0,0 +1,59 @@
+package slug
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	t.Log("Slugs should be lower case ascii words joined by dashes")
+
+	assert.Equal(t, "tengo-un-sueno", Make("¡Tengo un SUEÑO!", 100))
+	assert.Equal(t, "pimpinela-y-los-rodriguez", Make("Pimpinela & los Rodríguez", 100))
+	assert.Equal(t, "", Make("?!...", 100))
+}
+
+func TestMakeCutsAtWords(t *testing.T) {
+	t.Log("Long slugs should be cut at the last whole word that fits")
+
+	assert.Equal(t, "tengo-un", Make("Tengo un sueño", 10))
+	assert.Equal(t, "miameeeee", Make("Miameeeeeeeee", 9))
+}
+
+func TestUnique(t *testing.T) {
+	t.Log("A taken slug should get the first free number appended")
+
+	taken := map[string]bool{"miame": true, "miame-2": true}
+	slug, err := Unique("miame", "phrase", func(candidate string) (bool, error) {
+		return taken[candidate], nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "miame-3", slug)
+}
+
+func TestUniqueNeverLooksLikeAnID(t *testing.T) {
+	t.Log("Empty slugs should use the fallback and numeric ones should get a number appended")
+
+	free := func(candidate string) (bool, error) { return false, nil }
+
+	slug, err := Unique("", "phrase", free)
+	assert.NoError(t, err)
+	assert.Equal(t, "phrase", slug)
+
+	slug, err = Unique("1810", "phrase", free)
+	assert.NoError(t, err)
+	assert.Equal(t, "1810-2", slug)
+}
+
+func TestUniqueError(t *testing.T) {
+	t.Log("Errors checking a slug should be returned")
+
+	_, err := Unique("miame", "phrase", func(candidate string) (bool, error) {
+		return false, errors.New("connection refused")
+	})
+
+	assert.Error(t, err)
+}
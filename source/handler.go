@@ -0,0 +1,175 @@
+package source
+
+import (
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var sourceRepository repository.SourceRepository
+
+func Initialize(srcRepo repository.SourceRepository) {
+	sourceRepository = srcRepo
+}
+
+// GetSource returns a source by its id
+func GetSource(c *gin.Context) {
+	rest.ErrorWrapper(getSource, c)
+}
+
+func getSource(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("source-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting source with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	source, found, err := sourceRepository.Get(ctx, id)
+	if err != nil {
+		logger.Error("get source by id", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("source %d not found", id))
+	}
+
+	return rest.CachedJSON(c, source.LastUpdated, model.SourceResultFromSource(source))
+}
+
+// GetAllSources returns all sources wrapped in a json object
+func GetAllSources(c *gin.Context) {
+	rest.ErrorWrapper(getAllSources, c)
+}
+
+func getAllSources(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	sources, err := sourceRepository.GetAll(ctx)
+	if err != nil {
+		logger.Error("get all sources", err)
+		return err
+	}
+	return sourcesJSON(c, sources)
+}
+
+// GetSourcesForCharacter returns the sources the phrases of a character were said in, wrapped in a json object
+func GetSourcesForCharacter(c *gin.Context) {
+	rest.ErrorWrapper(getSourcesForCharacter, c)
+}
+
+func getSourcesForCharacter(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric characterId", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	sources, found, err := sourceRepository.GetAllForCharacter(ctx, characterId)
+	if err != nil {
+		logger.Error("get sources of character", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("character %d not found", characterId))
+	}
+	return sourcesJSON(c, sources)
+}
+
+// GetPhrasesForSource returns the phrases said in a source wrapped in a json object, leaving out the ones rated
+// above ?max_rating=
+func GetPhrasesForSource(c *gin.Context) {
+	rest.ErrorWrapper(getPhrasesForSource, c)
+}
+
+func getPhrasesForSource(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	id, err := strconv.ParseInt(c.Param("source-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting source with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	phrases, found, err := sourceRepository.GetPhrases(ctx, id)
+	if err != nil {
+		logger.Error("get phrases of source", err)
+		return err
+	}
+	if !found {
+		return rest.NewResourceNotFound(fmt.Sprintf("source %d not found", id))
+	}
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+	phrases = model.PhrasesRatedUpTo(phrases, maxRating)
+
+	phraseResults := make([]model.PhraseResult, len(phrases))
+	var lastModified time.Time
+	for i, phrase := range phrases {
+		phraseResults[i] = model.PhraseResultFromPhrase(phrase)
+		lastModified = rest.LatestUpdate(lastModified, phrase.LastUpdated)
+	}
+
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
+		"results": phraseResults,
+	})
+}
+
+// SaveSource saves a new source
+func SaveSource(c *gin.Context) {
+	rest.ErrorWrapper(saveSource, c)
+}
+
+func saveSource(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	var srcCmd model.SourceCommand
+	if err := c.ShouldBindJSON(&srcCmd); err != nil {
+		logger.Error("creating source bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	srcCmd.Title = strings.TrimSpace(srcCmd.Title)
+	srcCmd.Episode = strings.TrimSpace(srcCmd.Episode)
+	if srcCmd.Title == "" {
+		return rest.NewBadRequest("title must not be empty")
+	}
+
+	source, err := sourceRepository.Save(ctx, srcCmd)
+	if err != nil {
+		logger.Error("error creating source", err)
+		return err
+	}
+
+	c.JSON(http.StatusCreated, model.SourceResultFromSource(source))
+	return nil
+}
+
+func sourcesJSON(c *gin.Context, sources []model.Source) error {
+	sourceResults := make([]model.SourceResult, len(sources))
+	var lastModified time.Time
+	for i, source := range sources {
+		sourceResults[i] = model.SourceResultFromSource(source)
+		lastModified = rest.LatestUpdate(lastModified, source.LastUpdated)
+	}
+
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
+		"results": sourceResults,
+	})
+}
@@ -0,0 +1,244 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var sourceMockRepo sourcesMockRepository
+
+func TestGetSourceFound(t *testing.T) {
+	t.Log("A found source should be returned with its air date")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	airDate := time.Date(2010, time.March, 14, 0, 0, 0, 0, time.UTC)
+	source := model.NewSource(3, model.SourceShow, "Fort Night", now, now)
+	source.Episode = "S01E04"
+	source.AirDate = &airDate
+	sourceMockRepo.On("Get", mock.Anything, int64(3)).Return(source, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/source/3", nil)
+
+	r := utils.TestRouter()
+	r.GET("/source/:source-id", GetSource)
+	r.ServeHTTP(w, req)
+
+	actualResult := model.SourceResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "S01E04", actualResult.Episode)
+	assert.Equal(t, "2010-03-14", actualResult.AirDate)
+}
+
+func TestGetSourceNotFound(t *testing.T) {
+	t.Log("A source that doesn't exist should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	sourceMockRepo.On("Get", mock.Anything, int64(3)).Return(model.Source{}, false, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/source/3", nil)
+
+	r := utils.TestRouter()
+	r.GET("/source/:source-id", GetSource)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSaveSourceCreated(t *testing.T) {
+	t.Log("Saving a source should return it with Created")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	srcCmd := model.NewSourceCommand(model.SourceInterview, "Intrusos")
+	srcCmd.AirDate = "2010-03-14"
+	sourceMockRepo.On("Save", mock.Anything, srcCmd).Return(model.NewSource(3, model.SourceInterview, "Intrusos", now, now), nil)
+
+	body, _ := json.Marshal(srcCmd)
+	req := httptest.NewRequest(http.MethodPost, "/source", bytes.NewBuffer(body))
+
+	r := utils.TestRouter()
+	r.POST("/source", SaveSource)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestSaveSourceInvalidShouldFail(t *testing.T) {
+	t.Log("Unknown kinds and malformed air dates should return Bad Request")
+
+	for _, srcCmd := range []model.SourceCommand{
+		model.NewSourceCommand("podcast", "Fort Night"),
+		{Kind: model.SourceShow, Title: "Fort Night", AirDate: "14/03/2010"},
+	} {
+		w := httptest.NewRecorder()
+
+		resetMocks()
+
+		body, _ := json.Marshal(srcCmd)
+		req := httptest.NewRequest(http.MethodPost, "/source", bytes.NewBuffer(body))
+
+		r := utils.TestRouter()
+		r.POST("/source", SaveSource)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		sourceMockRepo.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	}
+}
+
+func TestGetPhrasesForSource(t *testing.T) {
+	t.Log("The phrases said in a source should be returned")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	now := time.Now()
+	phrases := []model.Phrase{
+		model.NewPhrase(1, 1, nil, "miameeee", now, now),
+		model.NewPhrase(4, 2, nil, "Tengo un sueño", now, now),
+	}
+	sourceMockRepo.On("GetPhrases", mock.Anything, int64(3)).Return(phrases, true, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/source/3/phrases", nil)
+
+	r := utils.TestRouter()
+	r.GET("/source/:source-id/phrases", GetPhrasesForSource)
+	r.ServeHTTP(w, req)
+
+	actualResult := map[string][]model.PhraseResult{}
+	assert.NoError(t, json.NewDecoder(w.Result().Body).Decode(&actualResult))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, actualResult["results"], 2)
+}
+
+func TestGetSourcesForCharacterNotFound(t *testing.T) {
+	t.Log("Sources of a character that doesn't exist should return Not Found")
+
+	w := httptest.NewRecorder()
+
+	resetMocks()
+
+	sourceMockRepo.On("GetAllForCharacter", mock.Anything, int64(1)).Return([]model.Source{}, false, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/character/1/sources", nil)
+
+	r := utils.TestRouter()
+	r.GET("/character/:character-id/sources", GetSourcesForCharacter)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func resetMocks() {
+	sourceMockRepo = sourcesMockRepository{}
+	sourceRepository = &sourceMockRepo
+}
+
+type sourcesMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *sourcesMockRepository) Get(ctx context.Context, id int64) (model.Source, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	source, ok := args.Get(0).(model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return source, found, args.Error(2)
+}
+
+func (repoMock *sourcesMockRepository) GetByIds(ctx context.Context, ids []int64) ([]model.Source, error) {
+	args := repoMock.Called(ctx, ids)
+
+	sources, ok := args.Get(0).([]model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return sources, args.Error(1)
+}
+
+func (repoMock *sourcesMockRepository) GetAll(ctx context.Context) ([]model.Source, error) {
+	args := repoMock.Called(ctx)
+
+	sources, ok := args.Get(0).([]model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return sources, args.Error(1)
+}
+
+func (repoMock *sourcesMockRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Source, bool, error) {
+	args := repoMock.Called(ctx, characterId)
+
+	sources, ok := args.Get(0).([]model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return sources, found, args.Error(2)
+}
+
+func (repoMock *sourcesMockRepository) GetPhrases(ctx context.Context, id int64) ([]model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, id)
+
+	phrases, ok := args.Get(0).([]model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return phrases, found, args.Error(2)
+}
+
+func (repoMock *sourcesMockRepository) Save(ctx context.Context, srcCmd model.SourceCommand) (model.Source, error) {
+	args := repoMock.Called(ctx, srcCmd)
+
+	source, ok := args.Get(0).(model.Source)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return source, args.Error(1)
+}
@@ -0,0 +1,161 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
+	customErrors "github.com/airabinovich/memequotes_back/errors"
+	"github.com/airabinovich/memequotes_back/model"
+	"time"
+)
+
+type DBSourceRepository struct {
+	cluster *database.Cluster
+}
+
+func NewDBSourceRepository(cluster *database.Cluster) DBSourceRepository {
+	return DBSourceRepository{
+		cluster: cluster,
+	}
+}
+
+func (repo DBSourceRepository) Get(ctx context.Context, id int64) (model.Source, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Source with id %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	source := model.Source{}
+	notFound := false
+	err := database.RetryRead(ctx, "getting source", func() error {
+		result := db.Where("id = ?", id).Find(&source)
+		notFound = result.RecordNotFound()
+		if notFound {
+			return nil
+		}
+		return result.Error
+	})
+	if err != nil {
+		return model.Source{}, false, database.TranslateError(err)
+	}
+	return source, !notFound, nil
+}
+
+func (repo DBSourceRepository) GetByIds(ctx context.Context, ids []int64) ([]model.Source, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Sources with ids %v", ids))
+	if len(ids) == 0 {
+		return []model.Source{}, nil
+	}
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	sources := make([]model.Source, 0)
+	err := database.RetryRead(ctx, "getting sources by id", func() error {
+		return db.Where("id IN (?)", ids).Find(&sources).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return sources, nil
+}
+
+func (repo DBSourceRepository) GetAll(ctx context.Context) ([]model.Source, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug("Getting all Sources")
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	sources := make([]model.Source, 0)
+	err := database.RetryRead(ctx, "getting all sources", func() error {
+		return db.Order("id").Find(&sources).Error
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return sources, nil
+}
+
+func (repo DBSourceRepository) GetAllForCharacter(ctx context.Context, characterId int64) ([]model.Source, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Sources of character %d", characterId))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	sources := make([]model.Source, 0)
+	found := false
+	err := database.RetryRead(ctx, "getting sources of character", func() error {
+		result := db.Where("id = ?", characterId).Find(&model.Character{})
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		return db.Where("id IN (SELECT source_id FROM phrases WHERE character_id = ?)", characterId).
+			Order("air_date, id").Find(&sources).Error
+	})
+	if err != nil {
+		return nil, false, database.TranslateError(err)
+	}
+	return sources, found, nil
+}
+
+func (repo DBSourceRepository) GetPhrases(ctx context.Context, id int64) ([]model.Phrase, bool, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting Phrases said in Source %d", id))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	phrases := make([]model.Phrase, 0)
+	found := false
+	err := database.RetryRead(ctx, "getting phrases of source", func() error {
+		result := db.Where("id = ?", id).Find(&model.Source{})
+		found = !result.RecordNotFound()
+		if !found {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		return db.Joins(model.JoinVisibleCharacters).Where("source_id = ? AND status = ?", id, model.PhraseStatusApproved).
+			Order("source_timestamp, phrases.id").Find(&phrases).Error
+	})
+	if err != nil {
+		return nil, false, database.TranslateError(err)
+	}
+	return phrases, found, nil
+}
+
+func (repo DBSourceRepository) Save(ctx context.Context, srcCmd model.SourceCommand) (model.Source, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Creating Source %s", srcCmd.Title))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Writer(ctx)
+
+	now := time.Now()
+	source := model.NewSource(0, srcCmd.Kind, srcCmd.Title, now, now)
+	source.Episode = srcCmd.Episode
+	source.URL = srcCmd.URL
+	if srcCmd.AirDate != "" {
+		airDate, err := time.Parse(model.AirDateLayout, srcCmd.AirDate)
+		if err != nil {
+			return model.Source{}, customErrors.NewValidationError("air_date", "air_date must be a date like 2006-01-02")
+		}
+		source.AirDate = &airDate
+	}
+
+	if err := db.Create(&source).Error; err != nil {
+		logger.Error("creating source", err)
+		return model.Source{}, database.TranslateError(err)
+	}
+	return source, nil
+}
@@ -0,0 +1,168 @@
+package trending
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+)
+
+// bucketSize is how finely events are counted over time. Windows slide by a bucket at a time
+const bucketSize = 5 * time.Minute
+
+// pruneInterval is how often the counts older than the longest window are removed
+const pruneInterval = time.Hour
+
+type event struct {
+	phraseId int64
+	kind     string
+	at       time.Time
+}
+
+type countKey struct {
+	phraseId int64
+	bucket   time.Time
+}
+
+// Counter counts events of phrases off the request path. Events are queued without blocking, added up in
+// memory and written in batches, so a read doesn't cost a database write. Events are dropped when the queue
+// is full, and counts are lost when a write fails: trending phrases don't need exact counts
+type Counter struct {
+	repo      repository.TrendingRepository
+	events    chan event
+	interval  time.Duration
+	batchSize int
+	dropped   int64
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewCounter creates a Counter writing to the repository. It counts nothing until it's started
+func NewCounter(repo repository.TrendingRepository, settings config.Trending) *Counter {
+	return &Counter{
+		repo:      repo,
+		events:    make(chan event, settings.QueueSize),
+		interval:  settings.FlushInterval,
+		batchSize: settings.BatchSize,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start counts the events in the background until Stop
+func (c *Counter) Start() {
+	go c.run()
+}
+
+// Stop writes the pending counts and stops counting
+func (c *Counter) Stop() {
+	close(c.stop)
+	<-c.done
+}
+
+// Record queues an event of a phrase. Returns whether it's queued. A nil Counter counts nothing
+func (c *Counter) Record(phraseId int64, kind string) bool {
+	if c == nil {
+		return false
+	}
+	select {
+	case c.events <- event{phraseId: phraseId, kind: kind, at: time.Now()}:
+		return true
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+		return false
+	}
+}
+
+// Dropped is how many events were not counted because the queue was full
+func (c *Counter) Dropped() int64 {
+	return atomic.LoadInt64(&c.dropped)
+}
+
+func (c *Counter) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	pending := make(map[countKey]*model.PhraseCounts)
+	lastPrune := time.Time{}
+	for {
+		select {
+		case e := <-c.events:
+			pending = c.add(pending, e)
+		case <-ticker.C:
+			pending = c.flush(pending)
+			if time.Since(lastPrune) >= pruneInterval {
+				c.prune()
+				lastPrune = time.Now()
+			}
+		case <-c.stop:
+			for {
+				select {
+				case e := <-c.events:
+					pending = c.add(pending, e)
+				default:
+					c.flush(pending)
+					return
+				}
+			}
+		}
+	}
+}
+
+// add counts an event, and writes the pending counts when there's a batch of them
+func (c *Counter) add(pending map[countKey]*model.PhraseCounts, e event) map[countKey]*model.PhraseCounts {
+	key := countKey{phraseId: e.phraseId, bucket: e.at.Truncate(bucketSize)}
+	counts, ok := pending[key]
+	if !ok {
+		counts = &model.PhraseCounts{PhraseId: key.phraseId, Bucket: key.bucket}
+		pending[key] = counts
+	}
+	switch e.kind {
+	case model.EventView:
+		counts.Views++
+	case model.EventRender:
+		counts.Renders++
+	case model.EventShare:
+		counts.Shares++
+	}
+	if len(pending) >= c.batchSize {
+		return c.flush(pending)
+	}
+	return pending
+}
+
+// flush writes the pending counts, and returns an empty set of counts to go on with
+func (c *Counter) flush(pending map[countKey]*model.PhraseCounts) map[countKey]*model.PhraseCounts {
+	if len(pending) == 0 {
+		return pending
+	}
+	ctx := commonContext.AppContext(context.Background())
+	counts := make([]model.PhraseCounts, 0, len(pending))
+	for _, count := range pending {
+		counts = append(counts, *count)
+	}
+	if err := c.repo.AddCounts(ctx, counts); err != nil {
+		commonContext.Logger(ctx).Error(fmt.Sprintf("dropping the counts of %d phrases", len(counts)), err)
+	}
+	return make(map[countKey]*model.PhraseCounts)
+}
+
+// prune removes the counts no window reaches
+func (c *Counter) prune() {
+	var longest time.Duration
+	for _, window := range model.TrendingWindows {
+		if window > longest {
+			longest = window
+		}
+	}
+	ctx := commonContext.AppContext(context.Background())
+	if err := c.repo.Prune(ctx, time.Now().Add(-longest-bucketSize)); err != nil {
+		commonContext.Logger(ctx).Error("pruning phrase counts", err)
+	}
+}
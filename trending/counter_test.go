@@ -0,0 +1,104 @@
+package trending
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterAddsUpEvents(t *testing.T) {
+	t.Log("Events of a phrase should be added up and written when the counter stops")
+
+	repo := &countsRepository{}
+	counter := NewCounter(repo, config.Trending{FlushInterval: time.Hour, BatchSize: 100, QueueSize: 10})
+	counter.Start()
+
+	counter.Record(1, model.EventView)
+	counter.Record(1, model.EventView)
+	counter.Record(1, model.EventShare)
+	counter.Record(2, model.EventRender)
+	counter.Stop()
+
+	counts := repo.byPhrase()
+	assert.Equal(t, 1, repo.writes())
+	assert.Equal(t, int64(2), counts[1].Views)
+	assert.Equal(t, int64(1), counts[1].Shares)
+	assert.Equal(t, int64(1), counts[2].Renders)
+	assert.Equal(t, counts[1].Bucket, counts[1].Bucket.Truncate(bucketSize))
+}
+
+func TestCounterWritesFullBatches(t *testing.T) {
+	t.Log("Counts should be written as soon as a batch is full")
+
+	repo := &countsRepository{}
+	counter := NewCounter(repo, config.Trending{FlushInterval: time.Hour, BatchSize: 2, QueueSize: 10})
+	counter.Start()
+
+	counter.Record(1, model.EventView)
+	counter.Record(2, model.EventView)
+	counter.Record(3, model.EventView)
+	counter.Stop()
+
+	assert.Equal(t, 2, repo.writes())
+	assert.Len(t, repo.byPhrase(), 3)
+}
+
+func TestCounterDropsEventsWhenFull(t *testing.T) {
+	t.Log("Events past the queue size should be dropped instead of blocking")
+
+	counter := NewCounter(&countsRepository{}, config.Trending{FlushInterval: time.Hour, BatchSize: 100, QueueSize: 1})
+
+	assert.True(t, counter.Record(1, model.EventView))
+	assert.False(t, counter.Record(1, model.EventView))
+	assert.Equal(t, int64(1), counter.Dropped())
+	assert.False(t, (*Counter)(nil).Record(1, model.EventView))
+}
+
+// countsRepository keeps the counts written by a Counter
+type countsRepository struct {
+	mutex   sync.Mutex
+	batches [][]model.PhraseCounts
+}
+
+func (repo *countsRepository) AddCounts(ctx context.Context, counts []model.PhraseCounts) error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	repo.batches = append(repo.batches, counts)
+	return nil
+}
+
+func (repo *countsRepository) GetTrending(ctx context.Context, since time.Time, halfLife time.Duration, limit int, maxRating string) ([]model.TrendingPhrase, error) {
+	return nil, nil
+}
+
+func (repo *countsRepository) Prune(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+func (repo *countsRepository) writes() int {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	return len(repo.batches)
+}
+
+func (repo *countsRepository) byPhrase() map[int64]model.PhraseCounts {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	counts := make(map[int64]model.PhraseCounts)
+	for _, batch := range repo.batches {
+		for _, count := range batch {
+			total := counts[count.PhraseId]
+			total.PhraseId, total.Bucket = count.PhraseId, count.Bucket
+			total.Views += count.Views
+			total.Renders += count.Renders
+			total.Shares += count.Shares
+			counts[count.PhraseId] = total
+		}
+	}
+	return counts
+}
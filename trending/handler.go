@@ -0,0 +1,160 @@
+package trending
+
+import (
+	"errors"
+	"fmt"
+	"github.com/airabinovich/memequotes_back/auth"
+	"github.com/airabinovich/memequotes_back/cache"
+	"github.com/airabinovich/memequotes_back/config"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/rest"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How many trending phrases are returned when the request doesn't say, and at most
+const (
+	defaultTrendingLimit = 20
+	maxTrendingLimit     = 100
+)
+
+// halfLifeDivisor makes events lose half their weight every quarter of the window, so the phrases going up
+// now rank above the ones that were hot at its start
+const halfLifeDivisor = 4
+
+var trendingRepository repository.TrendingRepository
+var phraseRepository repository.PhraseRepository
+var counter *Counter
+var trendingCache *cache.Cache
+
+// Initialize sets up the trending phrases. The counter may be nil, and then nothing is counted
+func Initialize(trendRepo repository.TrendingRepository, phRepo repository.PhraseRepository, eventCounter *Counter) {
+	trendingRepository = trendRepo
+	phraseRepository = phRepo
+	counter = eventCounter
+	trendingCache = cache.New(len(model.TrendingWindows)*maxTrendingLimit, config.Current().Trending.CacheTTL)
+}
+
+// RecordView counts a read of a phrase, unless it's made by a bot or trending phrases are turned off
+func RecordView(c *gin.Context, phraseId int64) {
+	if !config.Current().Features.Enabled("trending") {
+		return
+	}
+	record(c, phraseId, model.EventView)
+}
+
+func record(c *gin.Context, phraseId int64, kind string) {
+	ctx := commonContext.RequestContext(c)
+	apiKeyName := ""
+	if commonContext.Role(ctx) != auth.RoleAnonymous {
+		apiKeyName = strings.TrimPrefix(commonContext.ClientID(ctx), "key:")
+	}
+	if config.Current().Trending.IsBot(c.Request.UserAgent(), apiKeyName) {
+		return
+	}
+	counter.Record(phraseId, kind)
+}
+
+// SharePhrase counts a phrase made into a meme and rendered or shared
+func SharePhrase(c *gin.Context) {
+	rest.ErrorWrapper(sharePhrase, c)
+}
+
+func sharePhrase(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	characterId, err := strconv.ParseInt(c.Param("character-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting character with non-numeric characterId", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("phrase-id"), 10, 64)
+	if err != nil {
+		logger.Error("getting phrase with non-numeric id", err)
+		return rest.NewBadRequest(err.Error())
+	}
+
+	// The body is optional, a share is assumed without one
+	shareCmd := model.ShareCommand{Kind: model.EventShare}
+	if err := c.ShouldBindJSON(&shareCmd); err != nil && !errors.Is(err, io.EOF) {
+		logger.Error("sharing phrase bad body format", err)
+		return rest.NewValidationError(err)
+	}
+	if shareCmd.Kind == "" {
+		shareCmd.Kind = model.EventShare
+	}
+
+	phrase, found, err := phraseRepository.Get(ctx, characterId, id)
+	if err != nil {
+		logger.Error("get phrase by id", err)
+		return err
+	}
+	if !found || phrase.Status != model.PhraseStatusApproved {
+		return rest.NewResourceNotFound("phrase not found")
+	}
+
+	record(c, id, shareCmd.Kind)
+	c.Status(http.StatusAccepted)
+	return nil
+}
+
+// GetTrendingPhrases returns the phrases with the most views and shares in a sliding window given by
+// ?window=hour|day|week, a day by default, wrapped in a json object
+func GetTrendingPhrases(c *gin.Context) {
+	rest.ErrorWrapper(getTrendingPhrases, c)
+}
+
+func getTrendingPhrases(c *gin.Context) error {
+	ctx := commonContext.RequestContext(c)
+	logger := commonContext.Logger(ctx)
+
+	windowName := c.DefaultQuery("window", "day")
+	window, ok := model.TrendingWindows[windowName]
+	if !ok {
+		return rest.NewBadRequest("window must be one of hour, day or week")
+	}
+	limit := defaultTrendingLimit
+	if c.Query("limit") != "" {
+		var err error
+		if limit, err = strconv.Atoi(c.Query("limit")); err != nil || limit < 1 || limit > maxTrendingLimit {
+			return rest.NewBadRequest(fmt.Sprintf("limit must be a number between 1 and %d", maxTrendingLimit))
+		}
+	}
+	maxRating, err := rest.MaxRating(c)
+	if err != nil {
+		return err
+	}
+
+	// Every instance computes the trending phrases at most once per window, limit and rating in the cache TTL
+	key := fmt.Sprintf("trending:%s:%d:%s", windowName, limit, maxRating)
+	var trending []model.TrendingPhrase
+	if cached, ok := trendingCache.Get(key); ok {
+		trending = cached.([]model.TrendingPhrase)
+	} else {
+		trending, err = trendingRepository.GetTrending(ctx, time.Now().Add(-window), window/halfLifeDivisor, limit, maxRating)
+		if err != nil {
+			logger.Error("get trending phrases", err)
+			return err
+		}
+		trendingCache.Set(key, trending)
+	}
+
+	trendingResults := make([]model.TrendingPhraseResult, len(trending))
+	var lastModified time.Time
+	for i, trendingPhrase := range trending {
+		trendingResults[i] = model.TrendingPhraseResultFromTrendingPhrase(trendingPhrase)
+		lastModified = rest.LatestUpdate(lastModified, trendingPhrase.Phrase.LastUpdated)
+	}
+
+	return rest.CachedJSON(c, lastModified, map[string]interface{}{
+		"results": trendingResults,
+	})
+}
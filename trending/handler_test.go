@@ -0,0 +1,161 @@
+package trending
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/airabinovich/memequotes_back/config"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/airabinovich/memequotes_back/repository"
+	"github.com/airabinovich/memequotes_back/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var trendingMockRepo trendingMockRepository
+var phraseMockRepo phrasesMockRepository
+
+func TestGetTrendingPhrasesIsCached(t *testing.T) {
+	t.Log("Trending phrases should be computed once per window and limit while they are cached")
+
+	resetMocks(nil)
+
+	now := time.Now()
+	trending := []model.TrendingPhrase{
+		{Phrase: model.NewPhrase(4, 1, nil, "miameeee", now, now), Views: 10, Shares: 2, Score: 20},
+	}
+	trendingMockRepo.On("GetTrending", mock.Anything, mock.MatchedBy(func(since time.Time) bool {
+		return since.Before(now.Add(-59*time.Minute)) && since.After(now.Add(-61*time.Minute))
+	}), 15*time.Minute, 20, model.RatingMature).Return(trending, nil).Once()
+
+	r := utils.TestRouter()
+	r.GET("/phrases/trending", GetTrendingPhrases)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/phrases/trending?window=hour", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"trending_score":20`)
+	}
+	trendingMockRepo.AssertExpectations(t)
+}
+
+func TestGetTrendingPhrasesBadParameters(t *testing.T) {
+	t.Log("An unknown window or a limit out of range should return Bad Request")
+
+	r := utils.TestRouter()
+	r.GET("/phrases/trending", GetTrendingPhrases)
+
+	for _, query := range []string{"window=month", "limit=0", "limit=101"} {
+		resetMocks(nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/phrases/trending?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestSharePhraseIsCounted(t *testing.T) {
+	t.Log("Rendering a phrase should be counted and return Accepted, unless it comes from a bot")
+
+	counter := NewCounter(&countsRepository{}, config.Trending{FlushInterval: time.Hour, BatchSize: 100, QueueSize: 10})
+	resetMocks(counter)
+
+	now := time.Now()
+	ph := model.NewPhrase(4, 1, nil, "miameeee", now, now)
+	ph.Status = model.PhraseStatusApproved
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(4)).Return(ph, true, nil)
+
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/phrase/:phrase-id/share", SharePhrase)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/character/1/phrase/4/share", bytes.NewBufferString(`{"kind": "render"}`))
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	if assert.Len(t, counter.events, 1) {
+		assert.Equal(t, model.EventRender, (<-counter.events).kind)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/character/1/phrase/4/share", nil)
+	req.Header.Set("User-Agent", "Googlebot/2.1")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, counter.events, 0)
+}
+
+func TestSharePhraseNotFound(t *testing.T) {
+	t.Log("Sharing a phrase that doesn't exist should return Not Found")
+
+	resetMocks(nil)
+
+	phraseMockRepo.On("Get", mock.Anything, int64(1), int64(9)).Return(model.Phrase{}, false, nil)
+
+	w := httptest.NewRecorder()
+	r := utils.TestRouter()
+	r.POST("/character/:character-id/phrase/:phrase-id/share", SharePhrase)
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/character/1/phrase/9/share", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func resetMocks(eventCounter *Counter) {
+	trendingMockRepo = trendingMockRepository{}
+	phraseMockRepo = phrasesMockRepository{}
+	Initialize(&trendingMockRepo, &phraseMockRepo, eventCounter)
+}
+
+type trendingMockRepository struct {
+	mock.Mock
+}
+
+func (repoMock *trendingMockRepository) AddCounts(ctx context.Context, counts []model.PhraseCounts) error {
+	args := repoMock.Called(ctx, counts)
+	return args.Error(0)
+}
+
+func (repoMock *trendingMockRepository) GetTrending(ctx context.Context, since time.Time, halfLife time.Duration, limit int, maxRating string) ([]model.TrendingPhrase, error) {
+	args := repoMock.Called(ctx, since, halfLife, limit, maxRating)
+
+	trending, ok := args.Get(0).([]model.TrendingPhrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return trending, args.Error(1)
+}
+
+func (repoMock *trendingMockRepository) Prune(ctx context.Context, before time.Time) error {
+	args := repoMock.Called(ctx, before)
+	return args.Error(0)
+}
+
+// phrasesMockRepository only reads phrases, the rest of the repository is not used by the trending phrases
+type phrasesMockRepository struct {
+	mock.Mock
+	repository.PhraseRepository
+}
+
+func (repoMock *phrasesMockRepository) Get(ctx context.Context, characterId int64, id int64) (model.Phrase, bool, error) {
+	args := repoMock.Called(ctx, characterId, id)
+
+	ph, ok := args.Get(0).(model.Phrase)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	found, ok := args.Get(1).(bool)
+	if !ok {
+		panic(errors.New("mock error"))
+	}
+
+	return ph, found, args.Error(2)
+}
@@ -0,0 +1,147 @@
+package trending
+
+import (
+	"context"
+	"fmt"
+	commonContext "github.com/airabinovich/memequotes_back/context"
+	"github.com/airabinovich/memequotes_back/database"
+	"github.com/airabinovich/memequotes_back/model"
+	"github.com/jinzhu/gorm"
+	"strings"
+	"time"
+)
+
+// How much each event adds to the trending score of a phrase. Making a meme out of a phrase says more than
+// reading it, and sharing the meme even more
+const (
+	viewWeight   = 1
+	renderWeight = 3
+	shareWeight  = 5
+)
+
+// maxRowsPerInsert keeps the statements writing the counts under the placeholder limit
+const maxRowsPerInsert = 500
+
+type DBTrendingRepository struct {
+	cluster *database.Cluster
+}
+
+func NewDBTrendingRepository(cluster *database.Cluster) DBTrendingRepository {
+	return DBTrendingRepository{
+		cluster: cluster,
+	}
+}
+
+func (repo DBTrendingRepository) AddCounts(ctx context.Context, counts []model.PhraseCounts) error {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Adding %d phrase counts", len(counts)))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	err := database.WithTransaction(ctx, repo.cluster.Writer(ctx), "adding phrase counts", func(tx *gorm.DB) error {
+		for start := 0; start < len(counts); start += maxRowsPerInsert {
+			end := start + maxRowsPerInsert
+			if end > len(counts) {
+				end = len(counts)
+			}
+			rows := make([]string, 0, end-start)
+			values := make([]interface{}, 0, 5*(end-start))
+			for _, count := range counts[start:end] {
+				rows = append(rows, "(?, ?, ?, ?, ?)")
+				values = append(values, count.PhraseId, count.Bucket, count.Views, count.Renders, count.Shares)
+			}
+			err := tx.Exec("INSERT INTO phrase_counters (phrase_id, bucket, views, renders, shares) VALUES "+strings.Join(rows, ", ")+
+				" ON DUPLICATE KEY UPDATE views = views + VALUES(views), renders = renders + VALUES(renders), shares = shares + VALUES(shares)",
+				values...).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("adding phrase counts", err)
+		return database.TranslateError(err)
+	}
+	return nil
+}
+
+func (repo DBTrendingRepository) GetTrending(ctx context.Context, since time.Time, halfLife time.Duration, limit int, maxRating string) ([]model.TrendingPhrase, error) {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Getting %d trending Phrases since %v", limit, since))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+	db := repo.cluster.Reader(ctx)
+
+	trending := make([]model.TrendingPhrase, 0)
+	err := database.RetryRead(ctx, "getting trending phrases", func() error {
+		trending = trending[:0]
+		var tallies []struct {
+			PhraseId int64
+			Views    int64
+			Renders  int64
+			Shares   int64
+			Score    float64
+		}
+		// Counts of removed phrases are left out by the join until they are pruned, like the ones of phrases
+		// that are no longer approved or whose character is hidden
+		err := db.Table("phrase_counters").
+			Select("phrase_id, SUM(views) AS views, SUM(renders) AS renders, SUM(shares) AS shares, "+
+				"SUM((views * ? + renders * ? + shares * ?) * POW(0.5, TIMESTAMPDIFF(SECOND, bucket, ?) / ?)) AS score",
+				viewWeight, renderWeight, shareWeight, time.Now(), halfLife.Seconds()).
+			Joins("JOIN phrases ON phrases.id = phrase_counters.phrase_id AND phrases.status = ? AND phrases.rating IN (?)",
+				model.PhraseStatusApproved, model.RatingsUpTo(maxRating)).
+			Joins(model.JoinVisibleCharacters).
+			Where("bucket >= ?", since).Group("phrase_id").Order("score DESC, phrase_id").Limit(limit).
+			Scan(&tallies).Error
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(tallies))
+		for i, tally := range tallies {
+			ids[i] = tally.PhraseId
+		}
+		phrases := make([]model.Phrase, 0)
+		if len(ids) > 0 {
+			if err := db.Where("id IN (?)", ids).Find(&phrases).Error; err != nil {
+				return err
+			}
+		}
+		byId := make(map[int64]model.Phrase, len(phrases))
+		for _, phrase := range phrases {
+			byId[phrase.ID] = phrase
+		}
+		for _, tally := range tallies {
+			phrase, ok := byId[tally.PhraseId]
+			if !ok {
+				continue
+			}
+			trending = append(trending, model.TrendingPhrase{
+				Phrase:  phrase,
+				Views:   tally.Views,
+				Renders: tally.Renders,
+				Shares:  tally.Shares,
+				Score:   tally.Score,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, database.TranslateError(err)
+	}
+	return trending, nil
+}
+
+func (repo DBTrendingRepository) Prune(ctx context.Context, before time.Time) error {
+	logger := commonContext.Logger(ctx)
+	logger.Debug(fmt.Sprintf("Pruning phrase counts before %v", before))
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	if err := repo.cluster.Writer(ctx).Where("bucket < ?", before).Delete(&model.PhraseCounts{}).Error; err != nil {
+		logger.Error("pruning phrase counts", err)
+		return database.TranslateError(err)
+	}
+	return nil
+}